@@ -0,0 +1,98 @@
+// Package claimcheck moves oversized payloads out of the event stream.
+//
+// With the claim-check pattern, a publisher stores a large payload in a Store and
+// publishes a reference to it instead; subscribers fetch the payload back before
+// calling the handler. Blobs are keyed by stream and publication time, so Sweep can
+// delete them once the stream has trimmed the entries that referenced them.
+package claimcheck
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrNotFound is returned when a blob does not exist, e.g. because it was swept.
+var ErrNotFound = errors.New("blob not found")
+
+// Store keeps payload blobs. Implementations exist for Redis (RedisStore) and the local
+// filesystem (FileStore); object stores such as S3 fit the same interface.
+// Implementations must be safe for concurrent use.
+type Store interface {
+	// Put stores data under key, replacing any previous blob.
+	Put(ctx context.Context, key string, data []byte) error
+
+	// Get returns the blob stored under key, or an error wrapping ErrNotFound.
+	Get(ctx context.Context, key string) ([]byte, error)
+
+	// Delete removes the blobs stored under keys. Missing blobs are ignored.
+	Delete(ctx context.Context, keys ...string) error
+
+	// List returns the keys starting with prefix.
+	List(ctx context.Context, prefix string) ([]string, error)
+}
+
+// Key returns the key of the payload of event id published to stream at publishedAt:
+// "<stream>/<unix ms>-<id>".
+func Key(stream string, publishedAt time.Time, id string) string {
+	return stream + "/" + strconv.FormatInt(publishedAt.UnixMilli(), 10) + "-" + id
+}
+
+// keyTime returns the publication time encoded in key.
+func keyTime(key string) (time.Time, bool) {
+	// The stream name may itself contain slashes; the last segment holds the time.
+	i := strings.LastIndex(key, "/")
+	if i < 0 {
+		return time.Time{}, false
+	}
+
+	ms, _, ok := strings.Cut(key[i+1:], "-")
+	if !ok {
+		return time.Time{}, false
+	}
+
+	n, err := strconv.ParseInt(ms, 10, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	return time.UnixMilli(n), true
+}
+
+// Sweep deletes the blobs of stream published before the given time and returns how
+// many it deleted. Callers pass the time of the oldest entry still in the stream,
+// minus a grace period for entries re-added by subscriber retries.
+func Sweep(ctx context.Context, store Store, stream string, before time.Time) (int, error) {
+	return SweepExcept(ctx, store, stream, before, nil)
+}
+
+// SweepExcept is like Sweep, but keeps the blobs whose key is in referenced, e.g. those
+// of dead-lettered events.
+func SweepExcept(
+	ctx context.Context, store Store, stream string, before time.Time, referenced map[string]bool,
+) (int, error) {
+	keys, err := store.List(ctx, stream+"/")
+	if err != nil {
+		return 0, fmt.Errorf("failed to list blobs of %s: %w", stream, err)
+	}
+
+	var expired []string
+	for _, key := range keys {
+		if publishedAt, ok := keyTime(key); ok && publishedAt.Before(before) && !referenced[key] {
+			expired = append(expired, key)
+		}
+	}
+
+	if len(expired) == 0 {
+		return 0, nil
+	}
+
+	if err := store.Delete(ctx, expired...); err != nil {
+		return 0, fmt.Errorf("failed to delete blobs of %s: %w", stream, err)
+	}
+
+	return len(expired), nil
+}
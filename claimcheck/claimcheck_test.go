@@ -0,0 +1,97 @@
+package claimcheck_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tclavelloux/promy-event-bus/claimcheck"
+)
+
+func newRedisStore(t *testing.T) claimcheck.Store {
+	t.Helper()
+
+	client := redis.NewClient(&redis.Options{Addr: "localhost:6379", DB: 1})
+	t.Cleanup(func() { client.Close() })
+
+	prefix := "test:blob:" + t.Name() + ":"
+	store := claimcheck.NewRedisStore(client, prefix, time.Hour)
+
+	keys, err := store.List(context.Background(), "")
+	require.NoError(t, err)
+	require.NoError(t, store.Delete(context.Background(), keys...))
+
+	return store
+}
+
+func newFileStore(t *testing.T) claimcheck.Store {
+	t.Helper()
+
+	store, err := claimcheck.NewFileStore(t.TempDir())
+	require.NoError(t, err)
+
+	return store
+}
+
+func TestStores(t *testing.T) {
+	for name, newStore := range map[string]func(*testing.T) claimcheck.Store{
+		"redis": newRedisStore,
+		"file":  newFileStore,
+	} {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			store := newStore(t)
+
+			key := claimcheck.Key("events:promotions", time.Now(), "evt-1")
+			require.NoError(t, store.Put(ctx, key, []byte("ocr text")))
+			require.NoError(t, store.Put(ctx, claimcheck.Key("events:users", time.Now(), "evt-2"), []byte("other")))
+
+			data, err := store.Get(ctx, key)
+			require.NoError(t, err)
+			assert.Equal(t, "ocr text", string(data))
+
+			keys, err := store.List(ctx, "events:promotions/")
+			require.NoError(t, err)
+			assert.Equal(t, []string{key}, keys)
+
+			require.NoError(t, store.Delete(ctx, key, "events:promotions/missing"))
+
+			_, err = store.Get(ctx, key)
+			assert.ErrorIs(t, err, claimcheck.ErrNotFound)
+		})
+	}
+}
+
+func TestFileStore_RejectsEscapingKeys(t *testing.T) {
+	store := newFileStore(t)
+
+	assert.Error(t, store.Put(context.Background(), "../outside", []byte("x")))
+}
+
+func TestSweep(t *testing.T) {
+	ctx := context.Background()
+	store := newFileStore(t)
+	now := time.Now()
+
+	old := claimcheck.Key("events:promotions", now.Add(-2*time.Hour), "old")
+	recent := claimcheck.Key("events:promotions", now, "recent")
+	otherStream := claimcheck.Key("events:users", now.Add(-2*time.Hour), "other")
+	for _, key := range []string{old, recent, otherStream} {
+		require.NoError(t, store.Put(ctx, key, []byte("blob")))
+	}
+
+	deleted, err := claimcheck.Sweep(ctx, store, "events:promotions", now.Add(-time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, 1, deleted)
+
+	_, err = store.Get(ctx, old)
+	assert.ErrorIs(t, err, claimcheck.ErrNotFound)
+
+	for _, key := range []string{recent, otherStream} {
+		_, err = store.Get(ctx, key)
+		assert.NoError(t, err)
+	}
+}
@@ -0,0 +1,121 @@
+package claimcheck
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// FileStore keeps blobs as files under a directory, e.g. a volume shared by the
+// producers and consumers of a stream. Key segments become directories.
+type FileStore struct {
+	dir string
+}
+
+// NewFileStore creates a store under dir, creating it if needed.
+func NewFileStore(dir string) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, fmt.Errorf("failed to create blob directory: %w", err)
+	}
+
+	return &FileStore{dir: dir}, nil
+}
+
+func (s *FileStore) path(key string) (string, error) {
+	if !filepath.IsLocal(filepath.FromSlash(key)) {
+		return "", fmt.Errorf("invalid blob key %q", key)
+	}
+
+	return filepath.Join(s.dir, filepath.FromSlash(key)), nil
+}
+
+// Put implements Store. The blob is written to a temporary file and renamed,
+// so readers never see a partial blob.
+func (s *FileStore) Put(_ context.Context, key string, data []byte) error {
+	name, err := s.path(key)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(name), 0o750); err != nil {
+		return err
+	}
+
+	tmp := name + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, name)
+}
+
+// Get implements Store.
+func (s *FileStore) Get(_ context.Context, key string) ([]byte, error) {
+	name, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, key)
+	}
+
+	return data, err
+}
+
+// Delete implements Store.
+func (s *FileStore) Delete(_ context.Context, keys ...string) error {
+	for _, key := range keys {
+		name, err := s.path(key)
+		if err != nil {
+			return err
+		}
+
+		if err := os.Remove(name); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// List implements Store.
+func (s *FileStore) List(_ context.Context, prefix string) ([]string, error) {
+	// Only walk the directory holding the prefix.
+	root := path.Dir(prefix + "x")
+
+	var keys []string
+
+	err := filepath.WalkDir(filepath.Join(s.dir, filepath.FromSlash(root)), func(name string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+
+			return err
+		}
+
+		if entry.IsDir() || strings.HasSuffix(name, ".tmp") {
+			return nil
+		}
+
+		rel, err := filepath.Rel(s.dir, name)
+		if err != nil {
+			return err
+		}
+
+		if key := filepath.ToSlash(rel); strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+
+		return nil
+	})
+
+	return keys, err
+}
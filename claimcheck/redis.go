@@ -0,0 +1,103 @@
+package claimcheck
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// DefaultRedisPrefix is the key prefix of RedisStore blobs.
+const DefaultRedisPrefix = "eventbus:blob:"
+
+// RedisStore keeps blobs in Redis string keys, typically on an instance separate from the
+// streams (e.g., one with eviction enabled or cheaper memory).
+type RedisStore struct {
+	client redis.UniversalClient
+	prefix string
+	ttl    time.Duration
+}
+
+// NewRedisStore creates a store with keys prefix+key (DefaultRedisPrefix if prefix is empty).
+// If ttl is positive, blobs also expire on their own after ttl, as a safety net for
+// blobs that Sweep never reaches; it must exceed the stream retention.
+func NewRedisStore(client redis.UniversalClient, prefix string, ttl time.Duration) *RedisStore {
+	if prefix == "" {
+		prefix = DefaultRedisPrefix
+	}
+
+	return &RedisStore{client: client, prefix: prefix, ttl: ttl}
+}
+
+// Put implements Store.
+func (s *RedisStore) Put(ctx context.Context, key string, data []byte) error {
+	return s.client.Set(ctx, s.prefix+key, data, s.ttl).Err()
+}
+
+// Get implements Store.
+func (s *RedisStore) Get(ctx context.Context, key string) ([]byte, error) {
+	data, err := s.client.Get(ctx, s.prefix+key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, key)
+	}
+
+	return data, err
+}
+
+// Delete implements Store.
+func (s *RedisStore) Delete(ctx context.Context, keys ...string) error {
+	// One DEL per key keeps the command valid on Redis Cluster, where keys span slots.
+	for _, key := range keys {
+		if err := s.client.Del(ctx, s.prefix+key).Err(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// List implements Store with SCAN, on every master with Redis Cluster.
+func (s *RedisStore) List(ctx context.Context, prefix string) ([]string, error) {
+	cluster, ok := s.client.(*redis.ClusterClient)
+	if !ok {
+		return s.scan(ctx, s.client, prefix)
+	}
+
+	var (
+		mu   sync.Mutex
+		keys []string
+	)
+
+	err := cluster.ForEachMaster(ctx, func(ctx context.Context, node *redis.Client) error {
+		nodeKeys, err := s.scan(ctx, node, prefix)
+
+		mu.Lock()
+		keys = append(keys, nodeKeys...)
+		mu.Unlock()
+
+		return err
+	})
+
+	return keys, err
+}
+
+// scan returns the keys of client starting with prefix.
+func (s *RedisStore) scan(ctx context.Context, client redis.Cmdable, prefix string) ([]string, error) {
+	var keys []string
+
+	iter := client.Scan(ctx, 0, escapeGlob(s.prefix+prefix)+"*", 1000).Iterator()
+	for iter.Next(ctx) {
+		keys = append(keys, strings.TrimPrefix(iter.Val(), s.prefix))
+	}
+
+	return keys, iter.Err()
+}
+
+// escapeGlob escapes the characters that SCAN MATCH patterns interpret.
+func escapeGlob(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `*`, `\*`, `?`, `\?`, `[`, `\[`, `]`, `\]`).Replace(s)
+}
@@ -0,0 +1,251 @@
+// Package cloudevents maps bus events to CloudEvents 1.0 (https://cloudevents.io) and back,
+// for exchanging events with systems outside the platform.
+//
+// Event is a CloudEvent in the JSON event format ("structured mode"). New builds one from
+// an eventbus.Event; Parse reads one written by another system. The Redis binding of the
+// bus (redis.WithCloudEvents, redis.MessageToCloudEvent) is built on this package.
+package cloudevents
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"strings"
+	"time"
+
+	"github.com/tclavelloux/promy-event-bus/encryption"
+	eventbus "github.com/tclavelloux/promy-event-bus/eventbus"
+)
+
+const (
+	// SpecVersion is the CloudEvents version implemented by this package.
+	SpecVersion = "1.0"
+
+	// ContentType is the media type of a CloudEvent in the JSON event format.
+	ContentType = "application/cloudevents+json"
+
+	// VersionExtension is the extension attribute holding the schema version of the
+	// event (see eventbus.EventVersion).
+	VersionExtension = "eventversion"
+
+	jsonContentType = "application/json"
+)
+
+// ErrInvalidEvent is returned for documents that are not valid CloudEvents.
+var ErrInvalidEvent = errors.New("invalid CloudEvent")
+
+// SourceCarrier is implemented by events that know their producer, such as eventbus.BaseEvent.
+type SourceCarrier interface {
+	EventSource() string
+}
+
+// Event is a CloudEvent. Data holds the event data as encoded by DataContentType:
+// JSON data is embedded as is in the JSON format, other data is base64-encoded.
+type Event struct {
+	SpecVersion     string
+	ID              string
+	Source          string
+	Type            string
+	Time            time.Time
+	Subject         string
+	DataContentType string
+	Data            []byte
+
+	// Extensions are extension context attributes, e.g. "correlationid".
+	Extensions map[string]string
+}
+
+// New maps event to a CloudEvent with JSON data. The source is the event's own
+// (see SourceCarrier) or defaultSource if it has none. Event headers and the schema
+// version become extensions.
+func New(event eventbus.Event, defaultSource string) (*Event, error) {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal data: %w", err)
+	}
+
+	return NewWithData(event, defaultSource, jsonContentType, data)
+}
+
+// NewWithData is like New for data already encoded as dataContentType, e.g. by a codec.
+func NewWithData(event eventbus.Event, defaultSource, dataContentType string, data []byte) (*Event, error) {
+	source := defaultSource
+	if carrier, ok := event.(SourceCarrier); ok && carrier.EventSource() != "" {
+		source = carrier.EventSource()
+	}
+
+	ce := &Event{
+		SpecVersion:     SpecVersion,
+		ID:              event.EventID(),
+		Source:          source,
+		Type:            event.EventType(),
+		Time:            event.EventTime(),
+		DataContentType: dataContentType,
+		Data:            data,
+		Extensions:      ExtensionsFromHeaders(eventbus.EventHeaders(event)),
+	}
+	ce.SetVersion(eventbus.EventVersion(event))
+
+	return ce, ce.Validate()
+}
+
+// Parse reads a CloudEvent in the JSON event format and validates it.
+func Parse(data []byte) (*Event, error) {
+	var ce Event
+	if err := json.Unmarshal(data, &ce); err != nil {
+		if errors.Is(err, ErrInvalidEvent) {
+			return nil, err
+		}
+
+		return nil, fmt.Errorf("%w: %w", ErrInvalidEvent, err)
+	}
+
+	return &ce, ce.Validate()
+}
+
+// Validate checks the required context attributes.
+func (e *Event) Validate() error {
+	switch {
+	case e.SpecVersion != SpecVersion:
+		return fmt.Errorf("%w: unsupported specversion %q", ErrInvalidEvent, e.SpecVersion)
+	case e.ID == "":
+		return fmt.Errorf("%w: id is required", ErrInvalidEvent)
+	case e.Source == "":
+		return fmt.Errorf("%w: source is required", ErrInvalidEvent)
+	case e.Type == "":
+		return fmt.Errorf("%w: type is required", ErrInvalidEvent)
+	}
+
+	return nil
+}
+
+// HasJSONData reports whether Data is JSON: DataContentType is absent, application/json
+// or a +json media type.
+func (e *Event) HasJSONData() bool {
+	return IsJSON(e.DataContentType)
+}
+
+// IsJSON reports whether contentType denotes JSON. An empty content type does.
+func IsJSON(contentType string) bool {
+	if contentType == "" {
+		return true
+	}
+
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+
+	return mediaType == jsonContentType || strings.HasSuffix(mediaType, "+json")
+}
+
+// Version returns the event schema version recorded in the VersionExtension attribute,
+// or eventbus.DefaultEventVersion for CloudEvents that do not record one.
+func (e *Event) Version() string {
+	if version := e.Extensions[VersionExtension]; version != "" {
+		return version
+	}
+
+	return eventbus.DefaultEventVersion
+}
+
+// SetVersion records the event schema version in the VersionExtension attribute.
+func (e *Event) SetVersion(version string) {
+	if e.Extensions == nil {
+		e.Extensions = make(map[string]string, 1)
+	}
+
+	e.Extensions[VersionExtension] = version
+}
+
+// Headers returns the extensions other than the version as event headers.
+func (e *Event) Headers() eventbus.Headers {
+	return HeadersFromExtensions(e.Extensions)
+}
+
+// ToEnvelope converts a CloudEvent with JSON data to an envelope that can be
+// published on the bus, e.g. to forward an event received from a partner.
+func (e *Event) ToEnvelope() (*eventbus.Envelope, error) {
+	if !e.HasJSONData() {
+		return nil, fmt.Errorf("%w: data of content type %q cannot be published as JSON",
+			ErrInvalidEvent, e.DataContentType)
+	}
+
+	envelope := &eventbus.Envelope{
+		ID:      e.ID,
+		Type:    e.Type,
+		Version: e.Version(),
+		Time:    e.Time,
+		Header:  e.Headers(),
+		Payload: e.Data,
+	}
+	if envelope.Time.IsZero() {
+		envelope.Time = time.Now().UTC()
+	}
+
+	return envelope, envelope.Validate()
+}
+
+// knownHeaders are the bus headers restored under their own name from extensions.
+// Extension names only allow lowercase letters and digits.
+var knownHeaders = []string{
+	eventbus.HeaderCorrelationID,
+	eventbus.HeaderCausationID,
+	encryption.HeaderKeyID,
+	encryption.HeaderDataKey,
+	encryption.HeaderFields,
+}
+
+// ExtensionsFromHeaders maps headers to extension attributes: names are lowercased and
+// stripped of characters other than letters and digits (correlation_id -> correlationid).
+func ExtensionsFromHeaders(headers eventbus.Headers) map[string]string {
+	if len(headers) == 0 {
+		return nil
+	}
+
+	extensions := make(map[string]string, len(headers))
+	for key, value := range headers {
+		if name := extensionName(key); name != "" {
+			extensions[name] = value
+		}
+	}
+
+	return extensions
+}
+
+// HeadersFromExtensions reverses ExtensionsFromHeaders for the headers of the bus;
+// other extensions keep their name, except VersionExtension which is not a header.
+func HeadersFromExtensions(extensions map[string]string) eventbus.Headers {
+	headers := make(eventbus.Headers, len(extensions))
+	for name, value := range extensions {
+		if name != VersionExtension {
+			headers[name] = value
+		}
+	}
+
+	if len(headers) == 0 {
+		return nil
+	}
+
+	for _, key := range knownHeaders {
+		name := extensionName(key)
+		if value, ok := headers[name]; ok && name != key {
+			delete(headers, name)
+			headers[key] = value
+		}
+	}
+
+	return headers
+}
+
+func extensionName(key string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(key) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			b.WriteRune(r)
+		}
+	}
+
+	return b.String()
+}
@@ -0,0 +1,152 @@
+package cloudevents_test
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tclavelloux/promy-event-bus/cloudevents"
+	eventbus "github.com/tclavelloux/promy-event-bus/eventbus"
+	"github.com/tclavelloux/promy-event-bus/testutil"
+)
+
+type headerEvent struct {
+	*testutil.TestEvent
+	headers eventbus.Headers
+}
+
+func (e headerEvent) Headers() eventbus.Headers { return e.headers }
+
+func TestNew_MapsAttributes(t *testing.T) {
+	event := headerEvent{
+		TestEvent: testutil.NewTestEvent("user.registered", map[string]any{"user_id": "u-1"}),
+		headers:   eventbus.Headers{eventbus.HeaderCorrelationID: "corr-1"},
+	}
+	event.Source = "promy-user"
+
+	ce, err := cloudevents.New(event, "https://promy.fr")
+	require.NoError(t, err)
+
+	data, err := json.Marshal(ce)
+	require.NoError(t, err)
+
+	var doc map[string]any
+	require.NoError(t, json.Unmarshal(data, &doc))
+	assert.Equal(t, "1.0", doc["specversion"])
+	assert.Equal(t, event.ID, doc["id"])
+	assert.Equal(t, "promy-user", doc["source"])
+	assert.Equal(t, "user.registered", doc["type"])
+	assert.Equal(t, event.CreatedAt.Format(time.RFC3339Nano), doc["time"])
+	assert.Equal(t, "application/json", doc["datacontenttype"])
+	assert.Equal(t, "corr-1", doc["correlationid"])
+	assert.Equal(t, "1.0", doc["eventversion"])
+	assert.Equal(t, "u-1", doc["data"].(map[string]any)["user_id"])
+}
+
+func TestNew_DefaultSource(t *testing.T) {
+	event := testutil.NewTestEvent("user.registered", nil)
+	event.Source = ""
+
+	ce, err := cloudevents.New(event, "https://promy.fr")
+	require.NoError(t, err)
+	assert.Equal(t, "https://promy.fr", ce.Source)
+}
+
+func TestParse(t *testing.T) {
+	ce, err := cloudevents.Parse([]byte(`{
+		"specversion": "1.0",
+		"id": "A234-1234-1234",
+		"source": "https://retailer.example/stores/42",
+		"type": "com.retailer.promotion.published",
+		"time": "2026-10-18T17:31:00Z",
+		"datacontenttype": "application/json",
+		"correlationid": "corr-1",
+		"eventversion": "2.0",
+		"priority": 3,
+		"data": {"sku": "123"}
+	}`))
+	require.NoError(t, err)
+
+	assert.Equal(t, "A234-1234-1234", ce.ID)
+	assert.Equal(t, "https://retailer.example/stores/42", ce.Source)
+	assert.Equal(t, time.Date(2026, 10, 18, 17, 31, 0, 0, time.UTC), ce.Time)
+	assert.JSONEq(t, `{"sku":"123"}`, string(ce.Data))
+	assert.Equal(t, "3", ce.Extensions["priority"])
+	assert.Equal(t, "corr-1", ce.Headers().CorrelationID())
+	assert.NotContains(t, ce.Headers(), cloudevents.VersionExtension)
+	assert.Equal(t, "2.0", ce.Version())
+
+	envelope, err := ce.ToEnvelope()
+	require.NoError(t, err)
+	assert.Equal(t, "com.retailer.promotion.published", envelope.EventType())
+	assert.Equal(t, "2.0", eventbus.EventVersion(envelope))
+	assert.Equal(t, "corr-1", envelope.Headers().CorrelationID())
+	assert.JSONEq(t, `{"sku":"123"}`, envelope.Data())
+}
+
+func TestParse_Invalid(t *testing.T) {
+	for name, doc := range map[string]string{
+		"not json":            `nope`,
+		"wrong specversion":   `{"specversion":"0.3","id":"1","source":"s","type":"t"}`,
+		"missing id":          `{"specversion":"1.0","source":"s","type":"t"}`,
+		"missing source":      `{"specversion":"1.0","id":"1","type":"t"}`,
+		"data and base64":     `{"specversion":"1.0","id":"1","source":"s","type":"t","data":{},"data_base64":""}`,
+		"non-string id":       `{"specversion":"1.0","id":1,"source":"s","type":"t"}`,
+		"unparsable time":     `{"specversion":"1.0","id":"1","source":"s","type":"t","time":"yesterday"}`,
+		"invalid data_base64": `{"specversion":"1.0","id":"1","source":"s","type":"t","data_base64":"%%%"}`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := cloudevents.Parse([]byte(doc))
+			assert.ErrorIs(t, err, cloudevents.ErrInvalidEvent)
+		})
+	}
+}
+
+func TestEvent_BinaryDataRoundTrip(t *testing.T) {
+	ce := &cloudevents.Event{
+		SpecVersion:     cloudevents.SpecVersion,
+		ID:              "1",
+		Source:          "promy-user",
+		Type:            "user.location.updated",
+		DataContentType: "application/msgpack",
+		Data:            []byte{0x82, 0xa3, 0x6c, 0x61, 0x74},
+	}
+
+	data, err := json.Marshal(ce)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"data_base64"`)
+
+	parsed, err := cloudevents.Parse(data)
+	require.NoError(t, err)
+	assert.Equal(t, ce.Data, parsed.Data)
+	assert.False(t, parsed.HasJSONData())
+
+	_, err = parsed.ToEnvelope()
+	assert.ErrorIs(t, err, cloudevents.ErrInvalidEvent)
+}
+
+func TestExtensionsFromHeaders_RoundTrip(t *testing.T) {
+	headers := eventbus.Headers{
+		eventbus.HeaderCorrelationID: "corr-1",
+		eventbus.HeaderCausationID:   "cause-1",
+		"traceparent":                "00-abc-def-01",
+	}
+
+	extensions := cloudevents.ExtensionsFromHeaders(headers)
+	assert.Equal(t, map[string]string{
+		"correlationid": "corr-1",
+		"causationid":   "cause-1",
+		"traceparent":   "00-abc-def-01",
+	}, extensions)
+	assert.Equal(t, headers, cloudevents.HeadersFromExtensions(extensions))
+}
+
+func TestIsJSON(t *testing.T) {
+	assert.True(t, cloudevents.IsJSON(""))
+	assert.True(t, cloudevents.IsJSON("application/json; charset=utf-8"))
+	assert.True(t, cloudevents.IsJSON("application/vnd.retailer+json"))
+	assert.False(t, cloudevents.IsJSON("application/msgpack"))
+	assert.False(t, cloudevents.IsJSON("text/plain"))
+}
@@ -0,0 +1,147 @@
+package cloudevents
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Context attribute names of the JSON event format.
+const (
+	attrSpecVersion     = "specversion"
+	attrID              = "id"
+	attrSource          = "source"
+	attrType            = "type"
+	attrTime            = "time"
+	attrSubject         = "subject"
+	attrDataContentType = "datacontenttype"
+	attrData            = "data"
+	attrDataBase64      = "data_base64"
+)
+
+// MarshalJSON encodes the event in the JSON event format.
+func (e *Event) MarshalJSON() ([]byte, error) {
+	doc := make(map[string]any, len(e.Extensions)+8)
+	for name, value := range e.Extensions {
+		doc[name] = value
+	}
+
+	doc[attrSpecVersion] = e.SpecVersion
+	doc[attrID] = e.ID
+	doc[attrSource] = e.Source
+	doc[attrType] = e.Type
+
+	if !e.Time.IsZero() {
+		doc[attrTime] = e.Time.Format(time.RFC3339Nano)
+	}
+	if e.Subject != "" {
+		doc[attrSubject] = e.Subject
+	}
+	if e.DataContentType != "" {
+		doc[attrDataContentType] = e.DataContentType
+	}
+
+	if e.Data != nil {
+		if e.HasJSONData() && json.Valid(e.Data) {
+			doc[attrData] = json.RawMessage(e.Data)
+		} else {
+			doc[attrDataBase64] = base64.StdEncoding.EncodeToString(e.Data)
+		}
+	}
+
+	return json.Marshal(doc)
+}
+
+// UnmarshalJSON decodes an event in the JSON event format.
+// Attributes that are not context attributes of the specification become extensions.
+func (e *Event) UnmarshalJSON(data []byte) error {
+	var doc map[string]json.RawMessage
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidEvent, err)
+	}
+
+	*e = Event{}
+
+	for name, target := range map[string]*string{
+		attrSpecVersion:     &e.SpecVersion,
+		attrID:              &e.ID,
+		attrSource:          &e.Source,
+		attrType:            &e.Type,
+		attrSubject:         &e.Subject,
+		attrDataContentType: &e.DataContentType,
+	} {
+		if raw, ok := doc[name]; ok {
+			if err := json.Unmarshal(raw, target); err != nil {
+				return fmt.Errorf("%w: %s must be a string", ErrInvalidEvent, name)
+			}
+
+			delete(doc, name)
+		}
+	}
+
+	if raw, ok := doc[attrTime]; ok {
+		if err := json.Unmarshal(raw, &e.Time); err != nil {
+			return fmt.Errorf("%w: time must be an RFC 3339 timestamp", ErrInvalidEvent)
+		}
+
+		delete(doc, attrTime)
+	}
+
+	if err := e.unmarshalData(doc); err != nil {
+		return err
+	}
+
+	for name, raw := range doc {
+		if e.Extensions == nil {
+			e.Extensions = make(map[string]string, len(doc))
+		}
+
+		var value string
+		if err := json.Unmarshal(raw, &value); err != nil {
+			// Integer and boolean extensions are kept in their JSON form.
+			value = string(raw)
+		}
+
+		e.Extensions[name] = value
+	}
+
+	return nil
+}
+
+// unmarshalData reads data or data_base64 and removes them from doc.
+func (e *Event) unmarshalData(doc map[string]json.RawMessage) error {
+	raw, hasData := doc[attrData]
+	encoded, hasBase64 := doc[attrDataBase64]
+	delete(doc, attrData)
+	delete(doc, attrDataBase64)
+
+	switch {
+	case hasData && hasBase64:
+		return fmt.Errorf("%w: data and data_base64 are exclusive", ErrInvalidEvent)
+	case hasBase64:
+		var value string
+		if err := json.Unmarshal(encoded, &value); err != nil {
+			return fmt.Errorf("%w: data_base64 must be a string", ErrInvalidEvent)
+		}
+
+		decoded, err := base64.StdEncoding.DecodeString(value)
+		if err != nil {
+			return fmt.Errorf("%w: data_base64: %w", ErrInvalidEvent, err)
+		}
+
+		e.Data = decoded
+	case hasData && e.HasJSONData():
+		e.Data = raw
+	case hasData:
+		// Non-JSON data, such as text, is carried as a JSON string.
+		var value string
+		if err := json.Unmarshal(raw, &value); err != nil {
+			e.Data = raw
+		} else {
+			e.Data = []byte(value)
+		}
+	}
+
+	return nil
+}
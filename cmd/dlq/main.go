@@ -2,27 +2,20 @@ package main
 
 import (
 	"context"
-	"encoding/json"
+	"encoding/base64"
 	"flag"
 	"fmt"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/redis/go-redis/v9"
+	"github.com/tclavelloux/promy-event-bus/claimcheck"
+	"github.com/tclavelloux/promy-event-bus/dlq"
+	"github.com/tclavelloux/promy-event-bus/encryption"
 	"github.com/tclavelloux/promy-event-bus/streams"
 )
 
-type dlqPayload struct {
-	OriginalStream    string    `json:"original_stream"`
-	OriginalEventID   string    `json:"original_event_id"`
-	OriginalEventType string    `json:"original_event_type"`
-	OriginalPayload   string    `json:"original_payload"`
-	FailureReason     string    `json:"failure_reason"`
-	FailedAt          time.Time `json:"failed_at"`
-	FailedService     string    `json:"failed_service"`
-	AttemptsExhausted int       `json:"attempts_exhausted"`
-}
-
 type replayOpts struct {
 	stream string
 	typ    string
@@ -45,6 +38,8 @@ func main() {
 	switch subcommand {
 	case "inspect":
 		err = runInspect(os.Args[2:])
+	case "show":
+		err = runShow(os.Args[2:])
 	case "replay":
 		err = runReplay(os.Args[2:])
 	default:
@@ -64,9 +59,17 @@ func printUsage() {
 	fmt.Println()
 	fmt.Println("Subcommands:")
 	fmt.Println("  inspect   Show DLQ statistics")
+	fmt.Println("  show      Print DLQ entries with their payload (encrypted fields stay encrypted without -key)")
 	fmt.Println("  replay    Re-publish DLQ entries to their original streams")
+	fmt.Println()
+	fmt.Println(replayUpcastNote)
 }
 
+// replayUpcastNote warns that the binary knows no upcaster of any service.
+const replayUpcastNote = `replay publishes entries with the schema version they failed with, without upcasting:
+this binary registers no upcasters. Services whose handlers expect a newer version should
+replay with dlq.Replay and their own upcasters instead.`
+
 func redisDefault() string {
 	if v := os.Getenv("REDIS_URL"); v != "" {
 		return v
@@ -93,12 +96,28 @@ func newRedisClient(dsn string) (*redis.Client, error) {
 	return client, nil
 }
 
+// addBlobDirFlag registers the flag locating claim-checked DLQ payloads.
+func addBlobDirFlag(fs *flag.FlagSet) *string {
+	return fs.String("blob-dir", "", "Directory of claim-checked payloads (default: Redis keys "+claimcheck.DefaultRedisPrefix+"*)")
+}
+
+// newBlobStore returns the store of claim-checked payloads: the directory if set,
+// or the default Redis store next to the DLQ.
+func newBlobStore(client *redis.Client, dir string) (claimcheck.Store, error) {
+	if dir != "" {
+		return claimcheck.NewFileStore(dir)
+	}
+
+	return claimcheck.NewRedisStore(client, "", 0), nil
+}
+
 // --- inspect ---
 
 func runInspect(args []string) error {
 	fs := flag.NewFlagSet("inspect", flag.ExitOnError)
 	redisDSN := fs.String("redis", redisDefault(), "Redis DSN")
 	limit := fs.Int64("limit", 10000, "Max entries to scan")
+	blobDir := addBlobDirFlag(fs)
 	_ = fs.Parse(args)
 
 	client, err := newRedisClient(*redisDSN)
@@ -125,12 +144,17 @@ func runInspect(args []string) error {
 		return fmt.Errorf("reading DLQ entries: %w", err)
 	}
 
-	printInspectReport(msgs)
+	blobs, err := newBlobStore(client, *blobDir)
+	if err != nil {
+		return err
+	}
+
+	printInspectReport(ctx, msgs, blobs)
 
 	return nil
 }
 
-func printInspectReport(msgs []redis.XMessage) {
+func printInspectReport(ctx context.Context, msgs []redis.XMessage, blobs claimcheck.Store) {
 	type stats struct {
 		count  int
 		oldest time.Time
@@ -141,7 +165,7 @@ func printInspectReport(msgs []redis.XMessage) {
 	byService := make(map[string]int)
 
 	for _, msg := range msgs {
-		entry, err := parseDLQMessage(msg)
+		entry, err := dlq.Parse(ctx, msg, blobs)
 		if err != nil {
 			continue
 		}
@@ -182,6 +206,112 @@ func printInspectReport(msgs []redis.XMessage) {
 	}
 }
 
+// --- show ---
+
+// keyFlag collects -key id=base64 flags into encryption keys.
+type keyFlag map[string][]byte
+
+func (k keyFlag) String() string {
+	ids := make([]string, 0, len(k))
+	for id := range k {
+		ids = append(ids, id)
+	}
+
+	return strings.Join(ids, ",")
+}
+
+func (k keyFlag) Set(value string) error {
+	id, encoded, ok := strings.Cut(value, "=")
+	if !ok || id == "" {
+		return fmt.Errorf("expected id=base64key, got %q", value)
+	}
+
+	key, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return fmt.Errorf("key %q is not valid base64: %w", id, err)
+	}
+
+	k[id] = key
+
+	return nil
+}
+
+func runShow(args []string) error {
+	fs := flag.NewFlagSet("show", flag.ExitOnError)
+	opts := replayOpts{}
+	keys := keyFlag{}
+	redisDSN := fs.String("redis", redisDefault(), "Redis DSN")
+	fs.StringVar(&opts.stream, "stream", "", "Filter by original stream")
+	fs.StringVar(&opts.typ, "type", "", "Filter by original event type")
+	fs.StringVar(&opts.id, "id", "", "Show a single entry by DLQ message ID")
+	fs.Int64Var(&opts.limit, "limit", 100, "Max entries to scan")
+	fs.Var(keys, "key", "Encryption key as id=base64key to decrypt payloads (repeatable)")
+	blobDir := addBlobDirFlag(fs)
+	_ = fs.Parse(args)
+
+	opts.all = opts.stream == "" && opts.typ == "" && opts.id == ""
+
+	var provider encryption.KeyProvider
+	for id := range keys {
+		// The current key is only used to encrypt; any of them will do.
+		static, err := encryption.NewStaticKeys(id, keys)
+		if err != nil {
+			return err
+		}
+
+		provider = static
+
+		break
+	}
+
+	client, err := newRedisClient(*redisDSN)
+	if err != nil {
+		return err
+	}
+	defer client.Close() //nolint:errcheck // best-effort cleanup
+
+	ctx := context.Background()
+
+	msgs, err := fetchDLQMessages(ctx, client, opts)
+	if err != nil {
+		return err
+	}
+
+	blobs, err := newBlobStore(client, *blobDir)
+	if err != nil {
+		return err
+	}
+
+	for _, msg := range msgs {
+		entry, err := dlq.Parse(ctx, msg, blobs)
+		if err != nil || !matchesFilter(entry, opts) {
+			continue
+		}
+
+		printEntry(ctx, msg.ID, entry, provider)
+	}
+
+	return nil
+}
+
+func printEntry(ctx context.Context, msgID string, entry *dlq.Entry, provider encryption.KeyProvider) {
+	fmt.Printf("%s | stream=%s type=%s version=%s id=%s service=%s reason=%q\n",
+		msgID, entry.OriginalStream, entry.OriginalEventType, entry.Version(), entry.OriginalEventID,
+		entry.FailedService, entry.FailureReason)
+
+	payload := entry.OriginalPayload
+	if provider != nil && encryption.IsEncrypted(entry.OriginalHeaders) {
+		decrypted, err := encryption.Decrypt(ctx, provider, []byte(payload), entry.OriginalHeaders)
+		if err != nil {
+			fmt.Printf("  (cannot decrypt: %v)\n", err)
+		} else {
+			payload = string(decrypted)
+		}
+	}
+
+	fmt.Printf("  payload: %s\n", payload)
+}
+
 // --- replay ---
 
 func runReplay(args []string) error {
@@ -194,6 +324,14 @@ func runReplay(args []string) error {
 	fs.BoolVar(&opts.all, "all", false, "Replay all entries")
 	fs.BoolVar(&opts.dryRun, "dry-run", false, "List without replaying")
 	fs.Int64Var(&opts.limit, "limit", 1000, "Max entries to process")
+	blobDir := addBlobDirFlag(fs)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: dlq replay [flags]")
+		fmt.Fprintln(fs.Output())
+		fmt.Fprintln(fs.Output(), replayUpcastNote)
+		fmt.Fprintln(fs.Output())
+		fs.PrintDefaults()
+	}
 	_ = fs.Parse(args)
 
 	if !opts.all && opts.stream == "" && opts.typ == "" && opts.id == "" {
@@ -216,7 +354,12 @@ func runReplay(args []string) error {
 		return err
 	}
 
-	replayed, skipped, failed := processReplayMessages(ctx, client, msgs, opts)
+	blobs, err := newBlobStore(client, *blobDir)
+	if err != nil {
+		return err
+	}
+
+	replayed, skipped, failed := processReplayMessages(ctx, client, blobs, msgs, opts)
 
 	label := "Replayed"
 	if opts.dryRun {
@@ -247,9 +390,11 @@ func fetchDLQMessages(ctx context.Context, client *redis.Client, opts replayOpts
 	return msgs, nil
 }
 
-func processReplayMessages(ctx context.Context, client *redis.Client, msgs []redis.XMessage, opts replayOpts) (replayed, skipped, failed int) {
+func processReplayMessages(
+	ctx context.Context, client *redis.Client, blobs claimcheck.Store, msgs []redis.XMessage, opts replayOpts,
+) (replayed, skipped, failed int) {
 	for _, msg := range msgs {
-		entry, err := parseDLQMessage(msg)
+		entry, err := dlq.Parse(ctx, msg, blobs)
 		if err != nil {
 			skipped++
 
@@ -270,7 +415,8 @@ func processReplayMessages(ctx context.Context, client *redis.Client, msgs []red
 			continue
 		}
 
-		if err := replayEntry(ctx, client, msg.ID, entry); err != nil {
+		// No upcasters are registered in this binary (see replayUpcastNote)
+		if err := dlq.Replay(ctx, client, msg.ID, entry, nil); err != nil {
 			fmt.Fprintf(os.Stderr, "  FAIL %s: %v\n", msg.ID, err)
 			failed++
 
@@ -283,7 +429,7 @@ func processReplayMessages(ctx context.Context, client *redis.Client, msgs []red
 	return replayed, skipped, failed
 }
 
-func matchesFilter(entry *dlqPayload, opts replayOpts) bool {
+func matchesFilter(entry *dlq.Entry, opts replayOpts) bool {
 	if opts.all {
 		return true
 	}
@@ -303,55 +449,8 @@ func matchesFilter(entry *dlqPayload, opts replayOpts) bool {
 	return true
 }
 
-func replayEntry(ctx context.Context, client *redis.Client, msgID string, entry *dlqPayload) error {
-	metadata := map[string]any{
-		"id":        entry.OriginalEventID,
-		"type":      entry.OriginalEventType,
-		"timestamp": time.Now().UTC().Format(time.RFC3339),
-		"version":   "1.0",
-		"attempt":   1,
-	}
-
-	metadataJSON, err := json.Marshal(metadata)
-	if err != nil {
-		return fmt.Errorf("marshal metadata: %w", err)
-	}
-
-	_, err = client.XAdd(ctx, &redis.XAddArgs{
-		Stream: entry.OriginalStream,
-		Values: map[string]any{
-			"metadata": string(metadataJSON),
-			"payload":  entry.OriginalPayload,
-		},
-	}).Result()
-	if err != nil {
-		return fmt.Errorf("XADD to %s: %w", entry.OriginalStream, err)
-	}
-
-	_, err = client.XDel(ctx, streams.StreamDLQ, msgID).Result()
-	if err != nil {
-		return fmt.Errorf("XDEL %s from DLQ: %w", msgID, err)
-	}
-
-	return nil
-}
-
 // --- helpers ---
 
-func parseDLQMessage(msg redis.XMessage) (*dlqPayload, error) {
-	payloadStr, ok := msg.Values["payload"].(string)
-	if !ok {
-		return nil, fmt.Errorf("missing payload field in message %s", msg.ID)
-	}
-
-	var entry dlqPayload
-	if err := json.Unmarshal([]byte(payloadStr), &entry); err != nil {
-		return nil, fmt.Errorf("unmarshal payload in message %s: %w", msg.ID, err)
-	}
-
-	return &entry, nil
-}
-
 func formatDuration(d time.Duration) string {
 	if d < time.Minute {
 		return "<1m"
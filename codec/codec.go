@@ -0,0 +1,76 @@
+// Package codec encodes event payloads for the wire.
+//
+// Payloads are JSON by default. A Codec produces another representation, such as
+// MessagePack or Protobuf, and its content type is stored with each message so that
+// subscribers can pick the matching codec and convert the payload back to JSON:
+// Event.Data always returns JSON, whatever the wire format.
+package codec
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	eventbus "github.com/tclavelloux/promy-event-bus/eventbus"
+)
+
+// Content types of the built-in codecs.
+const (
+	ContentTypeJSON     = "application/json"
+	ContentTypeMsgPack  = "application/msgpack"
+	ContentTypeProtobuf = "application/protobuf"
+)
+
+var (
+	// ErrUnknownContentType is returned when no codec is known for a content type.
+	ErrUnknownContentType = errors.New("unknown content type")
+
+	// ErrUnsupportedEvent is returned when a codec cannot encode an event.
+	ErrUnsupportedEvent = errors.New("event not supported by codec")
+)
+
+// Codec converts event payloads to and from a wire format.
+// Implementations must be safe for concurrent use.
+type Codec interface {
+	// ContentType identifies the wire format in message metadata (e.g., "application/json").
+	ContentType() string
+
+	// Marshal encodes the payload of event.
+	Marshal(event eventbus.Event) ([]byte, error)
+
+	// ToJSON converts a payload produced by Marshal for an event of eventType to JSON.
+	ToJSON(eventType string, data []byte) ([]byte, error)
+}
+
+// JSON is the default codec: payloads are the JSON encoding of the event.
+var JSON Codec = jsonCodec{}
+
+type jsonCodec struct{}
+
+func (jsonCodec) ContentType() string { return ContentTypeJSON }
+
+func (jsonCodec) Marshal(event eventbus.Event) ([]byte, error) {
+	return json.Marshal(event)
+}
+
+func (jsonCodec) ToJSON(_ string, data []byte) ([]byte, error) {
+	if !json.Valid(data) {
+		return nil, errors.New("invalid JSON payload")
+	}
+
+	return data, nil
+}
+
+// Builtin returns the built-in codec that needs no configuration for contentType:
+// JSON (also for an empty content type, as written before codecs existed) or MsgPack.
+// Protobuf codecs need their message types and are never built in.
+func Builtin(contentType string) (Codec, error) {
+	switch contentType {
+	case "", ContentTypeJSON:
+		return JSON, nil
+	case ContentTypeMsgPack:
+		return MsgPack, nil
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnknownContentType, contentType)
+	}
+}
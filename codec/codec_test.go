@@ -0,0 +1,90 @@
+package codec_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tclavelloux/promy-event-bus/codec"
+	"github.com/tclavelloux/promy-event-bus/testutil"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+func TestCodecs_RoundTripToJSON(t *testing.T) {
+	event := testutil.NewTestEvent("user.location.updated", map[string]any{
+		"user_id": "u-1",
+		"lat":     48.8566,
+		"lng":     2.3522,
+		"zones":   []any{"paris", float64(75)},
+		"precise": true,
+	})
+	want, err := json.Marshal(event)
+	require.NoError(t, err)
+
+	for _, c := range []codec.Codec{codec.JSON, codec.MsgPack} {
+		t.Run(c.ContentType(), func(t *testing.T) {
+			data, err := c.Marshal(event)
+			require.NoError(t, err)
+
+			got, err := c.ToJSON(event.EventType(), data)
+			require.NoError(t, err)
+			assert.JSONEq(t, string(want), string(got))
+		})
+	}
+}
+
+func TestMsgPack_IsCompact(t *testing.T) {
+	event := testutil.NewTestEvent("user.location.updated", map[string]any{"lat": 48.8566, "lng": 2.3522, "accuracy": 12})
+
+	jsonData, err := codec.JSON.Marshal(event)
+	require.NoError(t, err)
+	msgpackData, err := codec.MsgPack.Marshal(event)
+	require.NoError(t, err)
+
+	assert.Less(t, len(msgpackData), len(jsonData))
+}
+
+type locationEvent struct {
+	*testutil.TestEvent
+}
+
+func (e locationEvent) ToProto() proto.Message {
+	message, _ := structpb.NewStruct(e.Payload)
+
+	return message
+}
+
+func TestProtobuf(t *testing.T) {
+	pb := codec.NewProtobuf(map[string]proto.Message{"user.location.updated": &structpb.Struct{}})
+	event := locationEvent{testutil.NewTestEvent("user.location.updated", map[string]any{"user_id": "u-1", "lat": 48.8566})}
+
+	data, err := pb.Marshal(event)
+	require.NoError(t, err)
+
+	got, err := pb.ToJSON("user.location.updated", data)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"user_id":"u-1","lat":48.8566}`, string(got))
+
+	_, err = pb.ToJSON("user.registered", data)
+	assert.ErrorIs(t, err, codec.ErrUnknownMessage)
+
+	_, err = pb.Marshal(testutil.NewTestEvent("user.registered", nil))
+	assert.ErrorIs(t, err, codec.ErrUnsupportedEvent)
+}
+
+func TestBuiltin(t *testing.T) {
+	for contentType, want := range map[string]codec.Codec{
+		"":                       codec.JSON,
+		codec.ContentTypeJSON:    codec.JSON,
+		codec.ContentTypeMsgPack: codec.MsgPack,
+	} {
+		got, err := codec.Builtin(contentType)
+		require.NoError(t, err)
+		assert.Equal(t, want, got)
+	}
+
+	_, err := codec.Builtin(codec.ContentTypeProtobuf)
+	assert.ErrorIs(t, err, codec.ErrUnknownContentType)
+}
@@ -0,0 +1,73 @@
+package codec
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	eventbus "github.com/tclavelloux/promy-event-bus/eventbus"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// MsgPack encodes payloads as MessagePack. The document is the same as with JSON,
+// so events need no extra tags: field names, custom MarshalJSON methods and
+// omitempty behave identically, only the encoding is more compact.
+var MsgPack Codec = msgpackCodec{}
+
+type msgpackCodec struct{}
+
+func (msgpackCodec) ContentType() string { return ContentTypeMsgPack }
+
+func (msgpackCodec) Marshal(event eventbus.Event) ([]byte, error) {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return nil, err
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	var doc any
+	if err := decoder.Decode(&doc); err != nil {
+		return nil, err
+	}
+
+	return msgpack.Marshal(compactNumbers(doc))
+}
+
+func (msgpackCodec) ToJSON(_ string, data []byte) ([]byte, error) {
+	var doc any
+	if err := msgpack.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("invalid MessagePack payload: %w", err)
+	}
+
+	return json.Marshal(doc)
+}
+
+// compactNumbers replaces the json.Number values of doc with integers where possible
+// and floats otherwise, so that MessagePack uses its compact numeric types.
+func compactNumbers(doc any) any {
+	switch v := doc.(type) {
+	case map[string]any:
+		for key, value := range v {
+			v[key] = compactNumbers(value)
+		}
+	case []any:
+		for i, value := range v {
+			v[i] = compactNumbers(value)
+		}
+	case json.Number:
+		if n, err := v.Int64(); err == nil {
+			return n
+		}
+
+		if f, err := v.Float64(); err == nil {
+			return f
+		}
+
+		return v.String()
+	}
+
+	return doc
+}
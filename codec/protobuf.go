@@ -0,0 +1,71 @@
+package codec
+
+import (
+	"errors"
+	"fmt"
+
+	eventbus "github.com/tclavelloux/promy-event-bus/eventbus"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// ErrUnknownMessage is returned when a Protobuf codec has no message type for an event type.
+var ErrUnknownMessage = errors.New("no protobuf message for event type")
+
+// ProtoEvent is implemented by events that can be encoded with a Protobuf codec.
+type ProtoEvent interface {
+	// ToProto returns the payload of the event as a Protobuf message.
+	ToProto() proto.Message
+}
+
+// Protobuf encodes payloads as Protobuf messages.
+type Protobuf struct {
+	messages map[string]protoreflect.MessageType
+	json     protojson.MarshalOptions
+}
+
+// NewProtobuf returns a codec for events implementing ProtoEvent. messages maps each event
+// type to a message of the type its payloads use, e.g.
+// {"user.location.updated": &userpb.LocationUpdated{}}; it is needed to decode payloads.
+// Decoded payloads use the field names of the .proto files, which should therefore be snake_case.
+func NewProtobuf(messages map[string]proto.Message) *Protobuf {
+	types := make(map[string]protoreflect.MessageType, len(messages))
+	for eventType, message := range messages {
+		types[eventType] = message.ProtoReflect().Type()
+	}
+
+	return &Protobuf{
+		messages: types,
+		json:     protojson.MarshalOptions{UseProtoNames: true},
+	}
+}
+
+// ContentType returns "application/protobuf".
+func (p *Protobuf) ContentType() string { return ContentTypeProtobuf }
+
+// Marshal encodes the message returned by event.ToProto.
+func (p *Protobuf) Marshal(event eventbus.Event) ([]byte, error) {
+	protoEvent, ok := event.(ProtoEvent)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s does not implement codec.ProtoEvent", ErrUnsupportedEvent, event.EventType())
+	}
+
+	return proto.Marshal(protoEvent.ToProto())
+}
+
+// ToJSON decodes data into the message type registered for eventType and returns it as JSON.
+func (p *Protobuf) ToJSON(eventType string, data []byte) ([]byte, error) {
+	messageType, ok := p.messages[eventType]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownMessage, eventType)
+	}
+
+	message := messageType.New().Interface()
+	if err := proto.Unmarshal(data, message); err != nil {
+		return nil, fmt.Errorf("invalid Protobuf payload: %w", err)
+	}
+
+	return p.json.Marshal(message)
+}
@@ -0,0 +1,204 @@
+// Package dlq reads and replays the entries of the dead-letter queue (streams.StreamDLQ).
+//
+// cmd/dlq is built on it. Services replay with their own upcasters, so that entries of
+// an older schema version are upgraded before they are published again:
+//
+//	entry, err := dlq.Parse(ctx, msg, claimcheck.NewRedisStore(client, "", 0))
+//	...
+//	err = dlq.Replay(ctx, client, msg.ID, entry, upcasters)
+package dlq
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/tclavelloux/promy-event-bus/claimcheck"
+	"github.com/tclavelloux/promy-event-bus/cloudevents"
+	"github.com/tclavelloux/promy-event-bus/codec"
+	"github.com/tclavelloux/promy-event-bus/encryption"
+	eventbus "github.com/tclavelloux/promy-event-bus/eventbus"
+	eventbusredis "github.com/tclavelloux/promy-event-bus/redis"
+	"github.com/tclavelloux/promy-event-bus/streams"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Entry is a DLQ entry as written by eventbus.NewDLQEntry.
+type Entry struct {
+	OriginalStream    string            `json:"original_stream"`
+	OriginalEventID   string            `json:"original_event_id"`
+	OriginalEventType string            `json:"original_event_type"`
+	OriginalVersion   string            `json:"original_version,omitempty"`
+	OriginalPayload   string            `json:"original_payload"`
+	OriginalHeaders   map[string]string `json:"original_headers,omitempty"`
+	FailureReason     string            `json:"failure_reason"`
+	FailedAt          time.Time         `json:"failed_at"`
+	FailedService     string            `json:"failed_service"`
+	AttemptsExhausted int               `json:"attempts_exhausted"`
+
+	// The remaining fields are set for payloads the subscriber could not fetch or decode;
+	// see eventbus.DLQEntry.
+	OriginalClaimCheck    string `json:"original_claim_check,omitempty"`
+	OriginalPayloadBase64 bool   `json:"original_payload_base64,omitempty"`
+	OriginalEncoding      string `json:"original_encoding,omitempty"`
+	OriginalContentType   string `json:"original_content_type,omitempty"`
+}
+
+// Version returns the schema version of the original event. Entries written before
+// versions were recorded hold events of the default version.
+func (e *Entry) Version() string {
+	if e.OriginalVersion == "" {
+		return eventbus.DefaultEventVersion
+	}
+
+	return e.OriginalVersion
+}
+
+// Parse decodes the entry of the DLQ message msg. The DLQ publisher may claim-check or
+// compress payloads, use another codec or wrap them in CloudEvents; all are recorded in
+// the metadata. Claim-checked payloads are fetched from blobs.
+func Parse(ctx context.Context, msg redis.XMessage, blobs claimcheck.Store) (*Entry, error) {
+	payloadStr, ok := msg.Values["payload"].(string)
+	if !ok {
+		return nil, fmt.Errorf("missing payload field in message %s", msg.ID)
+	}
+
+	var metadata struct {
+		Encoding    string `json:"encoding"`
+		ContentType string `json:"content_type"`
+		ClaimCheck  string `json:"claim_check"`
+	}
+	if metadataStr, ok := msg.Values["metadata"].(string); ok {
+		_ = json.Unmarshal([]byte(metadataStr), &metadata)
+	}
+
+	if metadata.ClaimCheck != "" {
+		blob, err := blobs.Get(ctx, metadata.ClaimCheck)
+		if err != nil {
+			return nil, fmt.Errorf("fetch claim-checked payload of message %s: %w", msg.ID, err)
+		}
+
+		payloadStr = string(blob)
+	}
+
+	payloadStr, err := eventbusredis.DecodePayload(metadata.Encoding, payloadStr)
+	if err != nil {
+		return nil, fmt.Errorf("decode payload in message %s: %w", msg.ID, err)
+	}
+
+	if metadata.ContentType == cloudevents.ContentType {
+		ce, err := cloudevents.Parse([]byte(payloadStr))
+		if err != nil {
+			return nil, fmt.Errorf("decode payload in message %s: %w", msg.ID, err)
+		}
+
+		payloadStr, metadata.ContentType = string(ce.Data), ce.DataContentType
+		if ce.HasJSONData() {
+			metadata.ContentType = codec.ContentTypeJSON
+		}
+	}
+
+	payloadCodec, err := codec.Builtin(metadata.ContentType)
+	if err != nil {
+		return nil, fmt.Errorf("decode payload in message %s: %w", msg.ID, err)
+	}
+
+	payloadJSON, err := payloadCodec.ToJSON("", []byte(payloadStr))
+	if err != nil {
+		return nil, fmt.Errorf("decode payload in message %s: %w", msg.ID, err)
+	}
+
+	var entry Entry
+	if err := json.Unmarshal(payloadJSON, &entry); err != nil {
+		return nil, fmt.Errorf("unmarshal payload in message %s: %w", msg.ID, err)
+	}
+
+	return &entry, nil
+}
+
+// Replay publishes the original event of entry again to its stream, then deletes the DLQ
+// message msgID. At-least-once semantics apply: the event is published twice if the
+// deletion fails.
+//
+// The payload is upgraded by upcasters first, or by eventbus.DefaultUpcasters if nil.
+// Encrypted payloads and payloads the subscriber could not fetch or decode are replayed
+// as stored, with their claim check, encoding and content type; subscribers upcast them
+// after decoding and decryption.
+func Replay(ctx context.Context, client redis.Cmdable, msgID string, entry *Entry, upcasters *eventbus.Upcasters) error {
+	if upcasters == nil {
+		upcasters = eventbus.DefaultUpcasters()
+	}
+
+	payload, version, err := upcast(entry, upcasters)
+	if err != nil {
+		return err
+	}
+
+	metadata := map[string]any{
+		"id":        entry.OriginalEventID,
+		"type":      entry.OriginalEventType,
+		"timestamp": time.Now().UTC().Format(time.RFC3339),
+		"version":   version,
+		"attempt":   1,
+	}
+	if len(entry.OriginalHeaders) > 0 {
+		metadata["headers"] = entry.OriginalHeaders
+	}
+	if entry.OriginalClaimCheck != "" {
+		metadata["claim_check"] = entry.OriginalClaimCheck
+	}
+	if entry.OriginalEncoding != "" {
+		metadata["encoding"] = entry.OriginalEncoding
+	}
+	if entry.OriginalContentType != "" {
+		metadata["content_type"] = entry.OriginalContentType
+	}
+
+	metadataJSON, err := json.Marshal(metadata)
+	if err != nil {
+		return fmt.Errorf("marshal metadata: %w", err)
+	}
+
+	_, err = client.XAdd(ctx, &redis.XAddArgs{
+		Stream: entry.OriginalStream,
+		Values: map[string]any{
+			"metadata": string(metadataJSON),
+			"payload":  payload,
+		},
+	}).Result()
+	if err != nil {
+		return fmt.Errorf("XADD to %s: %w", entry.OriginalStream, err)
+	}
+
+	_, err = client.XDel(ctx, streams.StreamDLQ, msgID).Result()
+	if err != nil {
+		return fmt.Errorf("XDEL %s from DLQ: %w", msgID, err)
+	}
+
+	return nil
+}
+
+// upcast returns the payload and version of entry upgraded by upcasters.
+func upcast(entry *Entry, upcasters *eventbus.Upcasters) (string, string, error) {
+	if entry.OriginalPayloadBase64 {
+		payload, err := base64.StdEncoding.DecodeString(entry.OriginalPayload)
+		if err != nil {
+			return "", "", fmt.Errorf("decode original payload: %w", err)
+		}
+
+		return string(payload), entry.Version(), nil
+	}
+	if encryption.IsEncrypted(entry.OriginalHeaders) || entry.OriginalClaimCheck != "" {
+		return entry.OriginalPayload, entry.Version(), nil
+	}
+
+	payload, version, err := upcasters.Upcast(entry.OriginalEventType, entry.Version(), []byte(entry.OriginalPayload))
+	if err != nil {
+		return "", "", err
+	}
+
+	return string(payload), version, nil
+}
@@ -0,0 +1,118 @@
+//nolint:all // Test file
+package dlq_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/tclavelloux/promy-event-bus/claimcheck"
+	"github.com/tclavelloux/promy-event-bus/dlq"
+	eventbus "github.com/tclavelloux/promy-event-bus/eventbus"
+	"github.com/tclavelloux/promy-event-bus/streams"
+	"github.com/tclavelloux/promy-event-bus/testutil"
+
+	goredis "github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReplay_Upcasts(t *testing.T) {
+	const stream = "events:test-dlq-replay"
+
+	ctx := context.Background()
+	client := goredis.NewClient(&goredis.Options{Addr: "localhost:6379", DB: 1})
+	defer client.Close()
+	require.NoError(t, client.Del(ctx, stream).Err())
+
+	event := testutil.NewTestEvent("promotion.created", map[string]any{"amount": 10})
+	entry := eventbus.NewDLQEntry(stream, event, errors.New("handler failed"), "test-service", 3)
+	msgID, err := client.XAdd(ctx, &goredis.XAddArgs{
+		Stream: streams.StreamDLQ,
+		Values: map[string]any{"metadata": `{"type":"dlq.promotion.created"}`, "payload": entry.Data()},
+	}).Result()
+	require.NoError(t, err)
+	t.Cleanup(func() { client.XDel(ctx, streams.StreamDLQ, msgID) })
+
+	msgs, err := client.XRange(ctx, streams.StreamDLQ, msgID, msgID).Result()
+	require.NoError(t, err)
+	require.Len(t, msgs, 1)
+
+	parsed, err := dlq.Parse(ctx, msgs[0], claimcheck.NewRedisStore(client, "", 0))
+	require.NoError(t, err)
+	assert.Equal(t, stream, parsed.OriginalStream)
+	assert.Equal(t, "1.0", parsed.Version())
+
+	upcasters := eventbus.NewUpcasters()
+	upcasters.Register("promotion.created", "1.0", "2.0", func(raw []byte) ([]byte, error) {
+		var p map[string]any
+		if err := json.Unmarshal(raw, &p); err != nil {
+			return nil, err
+		}
+		p["currency"] = "EUR"
+		return json.Marshal(p)
+	})
+
+	require.NoError(t, dlq.Replay(ctx, client, msgID, parsed, upcasters))
+
+	replayed, err := client.XRange(ctx, stream, "-", "+").Result()
+	require.NoError(t, err)
+	require.Len(t, replayed, 1)
+
+	var metadata struct {
+		ID      string `json:"id"`
+		Version string `json:"version"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(replayed[0].Values["metadata"].(string)), &metadata))
+	assert.Equal(t, event.ID, metadata.ID)
+	assert.Equal(t, "2.0", metadata.Version)
+	assert.Contains(t, replayed[0].Values["payload"], `"currency":"EUR"`)
+
+	remaining, err := client.XRange(ctx, streams.StreamDLQ, msgID, msgID).Result()
+	require.NoError(t, err)
+	assert.Empty(t, remaining, "the DLQ entry is deleted")
+}
+
+func TestReplay_ClaimChecked(t *testing.T) {
+	const stream = "events:test-dlq-replay-claimcheck"
+
+	ctx := context.Background()
+	client := goredis.NewClient(&goredis.Options{Addr: "localhost:6379", DB: 1})
+	defer client.Close()
+	require.NoError(t, client.Del(ctx, stream).Err())
+
+	key := claimcheck.Key(stream, time.Now(), "evt-claim-checked")
+	entry := &dlq.Entry{
+		OriginalStream:     stream,
+		OriginalEventID:    "evt-claim-checked",
+		OriginalEventType:  "promotion.created",
+		OriginalVersion:    "1.0",
+		OriginalClaimCheck: key,
+		OriginalEncoding:   "gzip",
+	}
+	msgID, err := client.XAdd(ctx, &goredis.XAddArgs{
+		Stream: streams.StreamDLQ,
+		Values: map[string]any{"metadata": `{"type":"dlq.promotion.created"}`, "payload": "{}"},
+	}).Result()
+	require.NoError(t, err)
+	t.Cleanup(func() { client.XDel(ctx, streams.StreamDLQ, msgID) })
+
+	require.NoError(t, dlq.Replay(ctx, client, msgID, entry, nil))
+
+	replayed, err := client.XRange(ctx, stream, "-", "+").Result()
+	require.NoError(t, err)
+	require.Len(t, replayed, 1)
+
+	var metadata struct {
+		Version    string `json:"version"`
+		ClaimCheck string `json:"claim_check"`
+		Encoding   string `json:"encoding"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(replayed[0].Values["metadata"].(string)), &metadata))
+	assert.Equal(t, "1.0", metadata.Version)
+	assert.Equal(t, key, metadata.ClaimCheck)
+	assert.Equal(t, "gzip", metadata.Encoding)
+	assert.Empty(t, replayed[0].Values["payload"])
+}
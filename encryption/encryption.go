@@ -0,0 +1,111 @@
+// Package encryption encrypts selected payload fields of events, such as personal data,
+// before they are stored in a stream.
+//
+// It uses envelope encryption: every message gets a random data key that encrypts its
+// fields with AES-256-GCM, and the data key itself is encrypted with a key encryption key
+// from a KeyProvider. The ID of that key, the wrapped data key and the list of encrypted
+// fields travel in message headers, so keys can be rotated while older messages, including
+// DLQ entries, remain readable as long as the provider still knows their key.
+package encryption
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	eventbus "github.com/tclavelloux/promy-event-bus/eventbus"
+)
+
+// KeySize is the size in bytes of key encryption keys (AES-256).
+const KeySize = 32
+
+// Header keys describing how a message was encrypted.
+const (
+	// HeaderKeyID is the ID of the key encryption key that wrapped the data key.
+	HeaderKeyID = "encryption_key_id"
+
+	// HeaderDataKey is the data key of the message, wrapped and base64-encoded.
+	HeaderDataKey = "encryption_data_key"
+
+	// HeaderFields lists the encrypted fields, comma-separated.
+	HeaderFields = "encryption_fields"
+)
+
+var (
+	// ErrUnknownKey is returned when a key provider does not know a key ID.
+	ErrUnknownKey = errors.New("unknown encryption key")
+
+	// ErrInvalidKey is returned for keys that are not KeySize bytes long.
+	ErrInvalidKey = errors.New("invalid encryption key")
+
+	// ErrDecrypt is returned when a message cannot be decrypted, e.g. because it was
+	// tampered with or its headers are incomplete.
+	ErrDecrypt = errors.New("decryption failed")
+)
+
+// KeyProvider supplies key encryption keys, e.g. from a KMS or a secret manager.
+// Implementations must be safe for concurrent use.
+type KeyProvider interface {
+	// CurrentKey returns the key used to encrypt new messages and its ID.
+	CurrentKey(ctx context.Context) (id string, key []byte, err error)
+
+	// Key returns the key with the given ID, current or retired.
+	// It returns an error wrapping ErrUnknownKey if the ID is not known.
+	Key(ctx context.Context, id string) ([]byte, error)
+}
+
+// StaticKeys is a KeyProvider over a fixed set of keys, e.g. loaded from environment
+// variables at startup. To rotate, add a new key and make it current; keep retired keys
+// for as long as messages encrypted with them may be read.
+type StaticKeys struct {
+	current string
+	keys    map[string][]byte
+}
+
+// NewStaticKeys creates a provider that encrypts with keys[current] and decrypts with any of keys.
+func NewStaticKeys(current string, keys map[string][]byte) (*StaticKeys, error) {
+	copied := make(map[string][]byte, len(keys))
+	for id, key := range keys {
+		if len(key) != KeySize {
+			return nil, fmt.Errorf("%w: key %q is %d bytes, want %d", ErrInvalidKey, id, len(key), KeySize)
+		}
+
+		copied[id] = append([]byte(nil), key...)
+	}
+
+	if _, ok := copied[current]; !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownKey, current)
+	}
+
+	return &StaticKeys{current: current, keys: copied}, nil
+}
+
+// CurrentKey returns the current key.
+func (s *StaticKeys) CurrentKey(_ context.Context) (string, []byte, error) {
+	return s.current, s.keys[s.current], nil
+}
+
+// Key returns the key with the given ID.
+func (s *StaticKeys) Key(_ context.Context, id string) ([]byte, error) {
+	key, ok := s.keys[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownKey, id)
+	}
+
+	return key, nil
+}
+
+// IsEncrypted reports whether headers describe an encrypted message.
+func IsEncrypted(headers eventbus.Headers) bool {
+	return headers[HeaderKeyID] != ""
+}
+
+// StripHeaders returns a copy of headers without the encryption headers.
+func StripHeaders(headers eventbus.Headers) eventbus.Headers {
+	stripped := headers.Clone()
+	delete(stripped, HeaderKeyID)
+	delete(stripped, HeaderDataKey)
+	delete(stripped, HeaderFields)
+
+	return stripped
+}
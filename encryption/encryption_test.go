@@ -0,0 +1,107 @@
+package encryption_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tclavelloux/promy-event-bus/encryption"
+)
+
+func testKeys(t *testing.T, current string, ids ...string) *encryption.StaticKeys {
+	t.Helper()
+
+	keys := make(map[string][]byte, len(ids))
+	for i, id := range ids {
+		keys[id] = bytes.Repeat([]byte{byte(i + 1)}, encryption.KeySize)
+	}
+
+	provider, err := encryption.NewStaticKeys(current, keys)
+	require.NoError(t, err)
+
+	return provider
+}
+
+func TestEncrypt_RoundTrip(t *testing.T) {
+	ctx := context.Background()
+	keys := testKeys(t, "k1", "k1")
+	payload := []byte(`{"user_id":"u-1","email":"thomas@example.com","contact":{"phone":"+33600000000"}}`)
+
+	encrypted, headers, err := encryption.Encrypt(ctx, keys, payload, []string{"email", "contact.phone", "missing"})
+	require.NoError(t, err)
+
+	assert.NotContains(t, string(encrypted), "thomas@example.com")
+	assert.NotContains(t, string(encrypted), "+33600000000")
+	assert.Contains(t, string(encrypted), `"user_id":"u-1"`)
+	assert.Equal(t, "k1", headers[encryption.HeaderKeyID])
+	assert.Equal(t, "email,contact.phone", headers[encryption.HeaderFields])
+	assert.True(t, encryption.IsEncrypted(headers))
+
+	decrypted, err := encryption.Decrypt(ctx, keys, encrypted, headers)
+	require.NoError(t, err)
+	assert.JSONEq(t, string(payload), string(decrypted))
+}
+
+func TestEncrypt_NoFieldPresent(t *testing.T) {
+	payload := []byte(`{"user_id":"u-1"}`)
+
+	encrypted, headers, err := encryption.Encrypt(context.Background(), testKeys(t, "k1", "k1"), payload, []string{"email"})
+	require.NoError(t, err)
+
+	assert.Equal(t, payload, encrypted)
+	assert.Nil(t, headers)
+}
+
+func TestDecrypt_AfterRotation(t *testing.T) {
+	ctx := context.Background()
+	payload := []byte(`{"email":"thomas@example.com"}`)
+
+	encrypted, headers, err := encryption.Encrypt(ctx, testKeys(t, "k1", "k1"), payload, []string{"email"})
+	require.NoError(t, err)
+
+	// k2 is now current, k1 is kept to read older messages
+	decrypted, err := encryption.Decrypt(ctx, testKeys(t, "k2", "k1", "k2"), encrypted, headers)
+	require.NoError(t, err)
+	assert.JSONEq(t, string(payload), string(decrypted))
+
+	_, err = encryption.Decrypt(ctx, testKeys(t, "k2", "k2"), encrypted, headers)
+	assert.ErrorIs(t, err, encryption.ErrUnknownKey)
+}
+
+func TestDecrypt_Tampered(t *testing.T) {
+	ctx := context.Background()
+	keys := testKeys(t, "k1", "k1")
+
+	encrypted, headers, err := encryption.Encrypt(ctx, keys, []byte(`{"email":"a@example.com","name":"b"}`), []string{"email"})
+	require.NoError(t, err)
+
+	// Moving a ciphertext to another field is detected
+	var doc map[string]string
+	require.NoError(t, json.Unmarshal(encrypted, &doc))
+	doc["name"], doc["email"] = doc["email"], "x"
+	headers[encryption.HeaderFields] = "name"
+	moved, err := json.Marshal(doc)
+	require.NoError(t, err)
+
+	_, err = encryption.Decrypt(ctx, keys, moved, headers)
+	assert.ErrorIs(t, err, encryption.ErrDecrypt)
+}
+
+func TestDecrypt_NotEncrypted(t *testing.T) {
+	payload := []byte(`{"email":"thomas@example.com"}`)
+
+	decrypted, err := encryption.Decrypt(context.Background(), testKeys(t, "k1", "k1"), payload, nil)
+	require.NoError(t, err)
+	assert.Equal(t, payload, decrypted)
+}
+
+func TestNewStaticKeys_Invalid(t *testing.T) {
+	_, err := encryption.NewStaticKeys("k1", map[string][]byte{"k1": []byte("short")})
+	assert.ErrorIs(t, err, encryption.ErrInvalidKey)
+
+	_, err = encryption.NewStaticKeys("k2", map[string][]byte{"k1": bytes.Repeat([]byte{1}, encryption.KeySize)})
+	assert.ErrorIs(t, err, encryption.ErrUnknownKey)
+}
@@ -0,0 +1,225 @@
+package encryption
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	eventbus "github.com/tclavelloux/promy-event-bus/eventbus"
+)
+
+// Encrypt encrypts fields of the JSON object payload with a new data key wrapped by the
+// current key of keys. Fields are top-level keys or dotted paths into nested objects
+// (e.g., "contact.email"); each encrypted value is replaced by a base64 string.
+//
+// It returns the encrypted payload and the headers to store with it. Fields absent from
+// payload are skipped; if none is present, payload is returned unchanged with nil headers.
+func Encrypt(
+	ctx context.Context, keys KeyProvider, payload []byte, fields []string,
+) ([]byte, eventbus.Headers, error) {
+	if len(fields) == 0 {
+		return payload, nil, nil
+	}
+
+	var doc map[string]json.RawMessage
+	if err := json.Unmarshal(payload, &doc); err != nil {
+		return nil, nil, fmt.Errorf("payload is not a JSON object: %w", err)
+	}
+
+	dataKey := make([]byte, KeySize)
+	if _, err := rand.Read(dataKey); err != nil {
+		return nil, nil, err
+	}
+
+	aead, err := newAEAD(dataKey)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var encrypted []string
+	for _, field := range fields {
+		found, err := transform(doc, strings.Split(field, "."), func(value json.RawMessage) (json.RawMessage, error) {
+			sealed, err := seal(aead, value, field)
+			if err != nil {
+				return nil, err
+			}
+
+			return json.Marshal(sealed)
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+		if found {
+			encrypted = append(encrypted, field)
+		}
+	}
+
+	if len(encrypted) == 0 {
+		return payload, nil, nil
+	}
+
+	keyID, key, err := keys.CurrentKey(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	kek, err := newAEAD(key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	wrapped, err := seal(kek, dataKey, keyID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	out, err := json.Marshal(doc)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return out, eventbus.Headers{
+		HeaderKeyID:   keyID,
+		HeaderDataKey: wrapped,
+		HeaderFields:  strings.Join(encrypted, ","),
+	}, nil
+}
+
+// Decrypt restores the fields of payload encrypted by Encrypt, as described by headers.
+// A payload whose headers do not mark it as encrypted is returned unchanged.
+// Errors other than those of keys wrap ErrDecrypt.
+func Decrypt(ctx context.Context, keys KeyProvider, payload []byte, headers eventbus.Headers) ([]byte, error) {
+	if !IsEncrypted(headers) {
+		return payload, nil
+	}
+
+	key, err := keys.Key(ctx, headers[HeaderKeyID])
+	if err != nil {
+		return nil, err
+	}
+
+	kek, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+
+	dataKey, err := open(kek, headers[HeaderDataKey], headers[HeaderKeyID])
+	if err != nil {
+		return nil, fmt.Errorf("%w: data key: %w", ErrDecrypt, err)
+	}
+
+	aead, err := newAEAD(dataKey)
+	if err != nil {
+		return nil, fmt.Errorf("%w: data key: %w", ErrDecrypt, err)
+	}
+
+	var doc map[string]json.RawMessage
+	if err := json.Unmarshal(payload, &doc); err != nil {
+		return nil, fmt.Errorf("%w: payload is not a JSON object: %w", ErrDecrypt, err)
+	}
+
+	for _, field := range strings.Split(headers[HeaderFields], ",") {
+		found, err := transform(doc, strings.Split(field, "."), func(value json.RawMessage) (json.RawMessage, error) {
+			var sealed string
+			if err := json.Unmarshal(value, &sealed); err != nil {
+				return nil, err
+			}
+
+			return open(aead, sealed, field)
+		})
+		if err != nil {
+			return nil, fmt.Errorf("%w: field %q: %w", ErrDecrypt, field, err)
+		}
+		if !found {
+			return nil, fmt.Errorf("%w: field %q is missing", ErrDecrypt, field)
+		}
+	}
+
+	return json.Marshal(doc)
+}
+
+// transform replaces the value at path in doc with fn(value).
+// It reports false if the path does not lead to a value.
+func transform(
+	doc map[string]json.RawMessage, path []string, fn func(json.RawMessage) (json.RawMessage, error),
+) (bool, error) {
+	value, ok := doc[path[0]]
+	if !ok {
+		return false, nil
+	}
+
+	if len(path) == 1 {
+		replaced, err := fn(value)
+		if err != nil {
+			return false, err
+		}
+
+		doc[path[0]] = replaced
+
+		return true, nil
+	}
+
+	var nested map[string]json.RawMessage
+	if err := json.Unmarshal(value, &nested); err != nil || nested == nil {
+		return false, nil //nolint:nilerr // not an object: the path does not exist
+	}
+
+	found, err := transform(nested, path[1:], fn)
+	if !found || err != nil {
+		return found, err
+	}
+
+	replaced, err := json.Marshal(nested)
+	if err != nil {
+		return false, err
+	}
+
+	doc[path[0]] = replaced
+
+	return true, nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	if len(key) != KeySize {
+		return nil, fmt.Errorf("%w: %d bytes, want %d", ErrInvalidKey, len(key), KeySize)
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
+
+// seal encrypts plaintext bound to ad and returns base64(nonce || ciphertext).
+func seal(aead cipher.AEAD, plaintext []byte, ad string) (string, error) {
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+
+	return base64.StdEncoding.EncodeToString(aead.Seal(nonce, nonce, plaintext, []byte(ad))), nil
+}
+
+// open reverses seal.
+func open(aead cipher.AEAD, sealed, ad string) ([]byte, error) {
+	data, err := base64.StdEncoding.DecodeString(sealed)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(data) < aead.NonceSize() {
+		return nil, errors.New("ciphertext too short")
+	}
+
+	nonce, ciphertext := data[:aead.NonceSize()], data[aead.NonceSize():]
+
+	return aead.Open(nil, nonce, ciphertext, []byte(ad))
+}
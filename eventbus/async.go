@@ -0,0 +1,373 @@
+package eventbus
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"sync"
+	"time"
+)
+
+// ErrEventDropped is passed to AsyncConfig.OnError for events discarded because the queue was full,
+// and returned by AsyncPublisher.Publish under OverflowDropNewest.
+var ErrEventDropped = errors.New("event dropped: publish queue full")
+
+// OverflowPolicy decides what AsyncPublisher does when its queue is full.
+type OverflowPolicy int
+
+const (
+	// OverflowBlock makes Publish wait for room in the queue or for its context to be done.
+	OverflowBlock OverflowPolicy = iota
+
+	// OverflowDropNewest rejects the event being published with ErrEventDropped.
+	OverflowDropNewest
+
+	// OverflowDropOldest discards the oldest queued events to make room.
+	OverflowDropOldest
+)
+
+// Default AsyncPublisher settings.
+const (
+	defaultAsyncQueueSize      = 1024
+	defaultAsyncBatchSize      = 100
+	defaultAsyncFlushInterval  = 100 * time.Millisecond
+	defaultAsyncPublishTimeout = 5 * time.Second
+)
+
+// AsyncConfig configures an AsyncPublisher.
+type AsyncConfig struct {
+	// QueueSize is the number of Publish or PublishBatch calls that can wait to be published.
+	// Default: 1024
+	QueueSize int
+
+	// BatchSize is the number of events per stream sent in one PublishBatch call. A
+	// PublishBatch call of more events is sent on its own.
+	// Default: 100
+	BatchSize int
+
+	// FlushInterval is how long queued events may wait for a batch to fill up.
+	// Default: 100ms
+	FlushInterval time.Duration
+
+	// PublishTimeout bounds each PublishBatch call of the wrapped publisher.
+	// Default: 5s
+	PublishTimeout time.Duration
+
+	// Overflow decides what happens when the queue is full.
+	// Default: OverflowBlock
+	Overflow OverflowPolicy
+
+	// OnError is called with the events that failed to publish or were dropped: from the
+	// background publisher, or from the goroutine calling Publish for events dropped by
+	// OverflowDropOldest. It must not block. If nil, errors are discarded.
+	OnError func(stream string, events []Event, err error)
+}
+
+// AsyncPublisher publishes events in the background through a wrapped EventPublisher.
+// Publish only validates and queues the event; a single background worker groups queued
+// events per stream into PublishBatch calls. Failures are reported to AsyncConfig.OnError.
+//
+// Use it for Tier 2 (best-effort) events only: a queued event is lost if the process dies.
+//
+// Headers of the publishing context (see WithHeaders) are captured when the event is queued
+// and passed to the wrapped publisher through the context of its PublishBatch call, so
+// events queued with different headers are published in separate calls. The events
+// themselves reach the wrapped publisher unchanged. The wrapped publisher is called with
+// a background context otherwise, so trace spans of the caller are not parents of the
+// publish spans.
+type AsyncPublisher struct {
+	publisher EventPublisher
+	config    AsyncConfig
+
+	queue   chan asyncItem
+	flushes chan chan struct{}
+	closing chan struct{}
+	done    chan struct{}
+
+	// mu guards closed; Publish holds it for reading while queuing.
+	mu     sync.RWMutex
+	closed bool
+}
+
+type asyncItem struct {
+	stream  string
+	events  []Event
+	headers Headers
+}
+
+// NewAsyncPublisher starts an AsyncPublisher publishing through publisher.
+func NewAsyncPublisher(publisher EventPublisher, config AsyncConfig) *AsyncPublisher {
+	if config.QueueSize <= 0 {
+		config.QueueSize = defaultAsyncQueueSize
+	}
+	if config.BatchSize <= 0 {
+		config.BatchSize = defaultAsyncBatchSize
+	}
+	if config.FlushInterval <= 0 {
+		config.FlushInterval = defaultAsyncFlushInterval
+	}
+	if config.PublishTimeout <= 0 {
+		config.PublishTimeout = defaultAsyncPublishTimeout
+	}
+
+	p := &AsyncPublisher{
+		publisher: publisher,
+		config:    config,
+		queue:     make(chan asyncItem, config.QueueSize),
+		flushes:   make(chan chan struct{}),
+		closing:   make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+
+	go p.run()
+
+	return p
+}
+
+// Publish validates event and queues it for publishing.
+// A nil error means the event was queued, not that it was published.
+func (p *AsyncPublisher) Publish(ctx context.Context, stream string, event Event) error {
+	return p.PublishBatch(ctx, stream, []Event{event})
+}
+
+// PublishBatch validates events and queues them for publishing.
+// The events stay together and reach the wrapped publisher in a single PublishBatch call.
+func (p *AsyncPublisher) PublishBatch(ctx context.Context, stream string, events []Event) error {
+	if len(events) == 0 {
+		return nil
+	}
+
+	for _, event := range events {
+		if err := validate(event); err != nil {
+			return err
+		}
+	}
+
+	item := asyncItem{stream: stream, events: append([]Event(nil), events...), headers: HeadersFromContext(ctx)}
+
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	if p.closed {
+		return ErrConnectionClosed
+	}
+
+	return p.enqueue(ctx, item)
+}
+
+func (p *AsyncPublisher) enqueue(ctx context.Context, item asyncItem) error {
+	switch p.config.Overflow {
+	case OverflowDropNewest:
+		select {
+		case p.queue <- item:
+			return nil
+		default:
+			return ErrEventDropped
+		}
+	case OverflowDropOldest:
+		for {
+			select {
+			case p.queue <- item:
+				return nil
+			default:
+			}
+
+			select {
+			case dropped := <-p.queue:
+				p.reportError(dropped.stream, dropped.events, ErrEventDropped)
+			default:
+			}
+		}
+	default:
+		select {
+		case p.queue <- item:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// Flush publishes every event queued before the call and waits for it to complete.
+// Failures are reported to AsyncConfig.OnError as usual.
+func (p *AsyncPublisher) Flush(ctx context.Context) error {
+	flushed := make(chan struct{})
+
+	select {
+	case p.flushes <- flushed:
+	case <-p.done:
+		return ErrConnectionClosed
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	select {
+	case <-flushed:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Close stops accepting events, publishes every queued event and stops the background worker.
+// It does not close the wrapped publisher, which may be shared with synchronous callers.
+func (p *AsyncPublisher) Close() error {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+
+		return nil
+	}
+	p.closed = true
+	p.mu.Unlock()
+
+	close(p.closing)
+	<-p.done
+
+	return nil
+}
+
+// Health checks the health of the wrapped publisher.
+func (p *AsyncPublisher) Health(ctx context.Context) error {
+	return p.publisher.Health(ctx)
+}
+
+// run is the background worker: it batches queued events per stream and publishes them.
+func (p *AsyncPublisher) run() {
+	defer close(p.done)
+
+	ticker := time.NewTicker(p.config.FlushInterval)
+	defer ticker.Stop()
+
+	b := newAsyncBatcher()
+
+	for {
+		select {
+		case item := <-p.queue:
+			if b.add(item) >= p.config.BatchSize {
+				p.publish(b.take(item.stream))
+			}
+		case <-ticker.C:
+			p.publishAll(b)
+		case flushed := <-p.flushes:
+			p.drainQueue(b)
+			p.publishAll(b)
+			close(flushed)
+		case <-p.closing:
+			p.drainQueue(b)
+			p.publishAll(b)
+
+			return
+		}
+	}
+}
+
+// drainQueue moves every queued item to b without blocking.
+func (p *AsyncPublisher) drainQueue(b *asyncBatcher) {
+	for {
+		select {
+		case item := <-p.queue:
+			b.add(item)
+		default:
+			return
+		}
+	}
+}
+
+func (p *AsyncPublisher) publishAll(b *asyncBatcher) {
+	for _, stream := range b.streams() {
+		p.publish(b.take(stream))
+	}
+}
+
+// publish publishes the queued items of one stream. Consecutive items queued with the same
+// context headers are published together, up to BatchSize events; an item is never split.
+func (p *AsyncPublisher) publish(items []asyncItem) {
+	for len(items) > 0 {
+		events := append([]Event(nil), items[0].events...)
+		n := 1
+		for n < len(items) && maps.Equal(items[n].headers, items[0].headers) &&
+			len(events)+len(items[n].events) <= p.config.BatchSize {
+			events = append(events, items[n].events...)
+			n++
+		}
+
+		p.publishBatch(items[0].stream, events, items[0].headers)
+		items = items[n:]
+	}
+}
+
+func (p *AsyncPublisher) publishBatch(stream string, events []Event, headers Headers) {
+	ctx, cancel := context.WithTimeout(context.Background(), p.config.PublishTimeout)
+	defer cancel()
+
+	if len(headers) > 0 {
+		ctx = WithHeaders(ctx, headers)
+	}
+
+	if err := p.publisher.PublishBatch(ctx, stream, events); err != nil {
+		p.reportError(stream, events, err)
+	}
+}
+
+func (p *AsyncPublisher) reportError(stream string, events []Event, err error) {
+	if p.config.OnError == nil {
+		return
+	}
+
+	p.config.OnError(stream, events, err)
+}
+
+// asyncBatcher accumulates queued items per stream, in arrival order.
+type asyncBatcher struct {
+	order  []string
+	items  map[string][]asyncItem
+	events map[string]int
+}
+
+func newAsyncBatcher() *asyncBatcher {
+	return &asyncBatcher{items: make(map[string][]asyncItem), events: make(map[string]int)}
+}
+
+// add appends item and returns the number of events pending for its stream.
+func (b *asyncBatcher) add(item asyncItem) int {
+	if _, ok := b.items[item.stream]; !ok {
+		b.order = append(b.order, item.stream)
+	}
+
+	b.items[item.stream] = append(b.items[item.stream], item)
+	b.events[item.stream] += len(item.events)
+
+	return b.events[item.stream]
+}
+
+// take removes and returns the items pending for stream.
+func (b *asyncBatcher) take(stream string) []asyncItem {
+	items := b.items[stream]
+	delete(b.items, stream)
+	delete(b.events, stream)
+
+	for i, s := range b.order {
+		if s == stream {
+			b.order = append(b.order[:i], b.order[i+1:]...)
+
+			break
+		}
+	}
+
+	return items
+}
+
+// streams returns the streams with pending events, in arrival order.
+func (b *asyncBatcher) streams() []string {
+	return append([]string(nil), b.order...)
+}
+
+// validate checks struct tags, then event business rules, like publishers do.
+func validate(event Event) error {
+	if err := ValidateStruct(event); err != nil {
+		return err
+	}
+
+	return event.Validate()
+}
@@ -0,0 +1,290 @@
+package eventbus_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tclavelloux/promy-event-bus/eventbus"
+	"github.com/tclavelloux/promy-event-bus/testutil"
+)
+
+// batchRecorder is an EventPublisher recording each PublishBatch call.
+// When gate is set, calls signal entered and block until gate is closed.
+type batchRecorder struct {
+	mu      sync.Mutex
+	batches [][]eventbus.Event
+	headers []eventbus.Headers
+	err     error
+	gate    chan struct{}
+	entered chan struct{}
+}
+
+func (r *batchRecorder) Publish(ctx context.Context, stream string, event eventbus.Event) error {
+	return r.PublishBatch(ctx, stream, []eventbus.Event{event})
+}
+
+func (r *batchRecorder) PublishBatch(ctx context.Context, _ string, events []eventbus.Event) error {
+	if r.gate != nil {
+		select {
+		case r.entered <- struct{}{}:
+		default:
+		}
+		<-r.gate
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.batches = append(r.batches, events)
+	for _, event := range events {
+		r.headers = append(r.headers, eventbus.OutgoingHeaders(ctx, event))
+	}
+
+	return r.err
+}
+
+func (r *batchRecorder) Close() error                 { return nil }
+func (r *batchRecorder) Health(context.Context) error { return nil }
+
+func (r *batchRecorder) published() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	n := 0
+	for _, batch := range r.batches {
+		n += len(batch)
+	}
+
+	return n
+}
+
+func newAsyncTestEvent() *testutil.TestEvent {
+	return testutil.NewTestEvent("promotion.viewed", map[string]any{"promotion_id": "promo-1"})
+}
+
+func TestAsyncPublisher_BatchesAndFlushes(t *testing.T) {
+	recorder := &batchRecorder{}
+	publisher := eventbus.NewAsyncPublisher(recorder, eventbus.AsyncConfig{
+		BatchSize:     10,
+		FlushInterval: time.Hour,
+	})
+	defer publisher.Close()
+
+	ctx := context.Background()
+	for i := 0; i < 25; i++ {
+		require.NoError(t, publisher.Publish(ctx, "events:promotions", newAsyncTestEvent()))
+	}
+
+	require.NoError(t, publisher.Flush(ctx))
+
+	assert.Equal(t, 25, recorder.published())
+	require.Len(t, recorder.batches, 3)
+	assert.Len(t, recorder.batches[0], 10)
+	assert.Len(t, recorder.batches[1], 10)
+	assert.Len(t, recorder.batches[2], 5)
+}
+
+func TestAsyncPublisher_KeepsBatchesTogether(t *testing.T) {
+	recorder := &batchRecorder{}
+	publisher := eventbus.NewAsyncPublisher(recorder, eventbus.AsyncConfig{
+		BatchSize:     4,
+		FlushInterval: time.Hour,
+	})
+	defer publisher.Close()
+
+	batch := func(n int) []eventbus.Event {
+		events := make([]eventbus.Event, n)
+		for i := range events {
+			events[i] = newAsyncTestEvent()
+		}
+		return events
+	}
+
+	ctx := context.Background()
+	for i := 0; i < 3; i++ {
+		require.NoError(t, publisher.Publish(ctx, "events:promotions", newAsyncTestEvent()))
+	}
+	require.NoError(t, publisher.PublishBatch(ctx, "events:promotions", batch(3)))
+	require.NoError(t, publisher.PublishBatch(ctx, "events:promotions", batch(5)))
+	require.NoError(t, publisher.Flush(ctx))
+
+	require.Len(t, recorder.batches, 3)
+	assert.Len(t, recorder.batches[0], 3)
+	assert.Len(t, recorder.batches[1], 3, "a batch is not split across calls")
+	assert.Len(t, recorder.batches[2], 5, "a batch above BatchSize is published on its own")
+}
+
+func TestAsyncPublisher_CapturesContextHeaders(t *testing.T) {
+	recorder := &batchRecorder{}
+	publisher := eventbus.NewAsyncPublisher(recorder, eventbus.AsyncConfig{})
+	defer publisher.Close()
+
+	ctx := eventbus.WithCorrelationID(context.Background(), "corr-1")
+	require.NoError(t, publisher.Publish(ctx, "events:promotions", newAsyncTestEvent()))
+	require.NoError(t, publisher.Flush(context.Background()))
+
+	require.Len(t, recorder.headers, 1)
+	assert.Equal(t, "corr-1", recorder.headers[0].CorrelationID())
+}
+
+func TestAsyncPublisher_SeparatesContextHeaders(t *testing.T) {
+	recorder := &batchRecorder{}
+	publisher := eventbus.NewAsyncPublisher(recorder, eventbus.AsyncConfig{FlushInterval: time.Hour})
+	defer publisher.Close()
+
+	ctx1 := eventbus.WithCorrelationID(context.Background(), "corr-1")
+	ctx2 := eventbus.WithCorrelationID(context.Background(), "corr-2")
+	require.NoError(t, publisher.Publish(ctx1, "events:promotions", newAsyncTestEvent()))
+	require.NoError(t, publisher.Publish(ctx1, "events:promotions", newAsyncTestEvent()))
+	require.NoError(t, publisher.Publish(ctx2, "events:promotions", newAsyncTestEvent()))
+	require.NoError(t, publisher.Flush(context.Background()))
+
+	require.Len(t, recorder.batches, 2)
+	assert.Len(t, recorder.batches[0], 2)
+	assert.Len(t, recorder.batches[1], 1)
+	assert.Equal(t, "corr-1", recorder.headers[1].CorrelationID())
+	assert.Equal(t, "corr-2", recorder.headers[2].CorrelationID())
+}
+
+func TestAsyncPublisher_KeepsEventInterfaces(t *testing.T) {
+	recorder := &batchRecorder{}
+	publisher := eventbus.NewAsyncPublisher(recorder, eventbus.AsyncConfig{})
+	defer publisher.Close()
+
+	event := newAsyncTestEvent()
+	event.Version = "2.0"
+	event.Key = "promo-42"
+	require.NoError(t, publisher.Publish(context.Background(), "events:promotions", event))
+	require.NoError(t, publisher.Flush(context.Background()))
+
+	require.Len(t, recorder.batches, 1)
+	published := recorder.batches[0][0]
+	assert.Same(t, event, published)
+	assert.Equal(t, "2.0", eventbus.EventVersion(published))
+	assert.Equal(t, "promo-42", eventbus.PartitionKey(published))
+}
+
+func TestAsyncPublisher_RejectsInvalidEvent(t *testing.T) {
+	publisher := eventbus.NewAsyncPublisher(&batchRecorder{}, eventbus.AsyncConfig{})
+	defer publisher.Close()
+
+	err := publisher.Publish(context.Background(), "events:dlq", &eventbus.DLQEntry{})
+	assert.ErrorIs(t, err, eventbus.ErrInvalidEvent)
+}
+
+func TestAsyncPublisher_ReportsErrors(t *testing.T) {
+	publishErr := errors.New("redis down")
+
+	var (
+		mu     sync.Mutex
+		failed []eventbus.Event
+	)
+	publisher := eventbus.NewAsyncPublisher(&batchRecorder{err: publishErr}, eventbus.AsyncConfig{
+		OnError: func(_ string, events []eventbus.Event, err error) {
+			assert.ErrorIs(t, err, publishErr)
+
+			mu.Lock()
+			defer mu.Unlock()
+			failed = append(failed, events...)
+		},
+	})
+	defer publisher.Close()
+
+	event := newAsyncTestEvent()
+	require.NoError(t, publisher.Publish(context.Background(), "events:promotions", event))
+	require.NoError(t, publisher.Flush(context.Background()))
+
+	mu.Lock()
+	defer mu.Unlock()
+	require.Len(t, failed, 1)
+	assert.Same(t, event, failed[0])
+}
+
+func TestAsyncPublisher_Overflow(t *testing.T) {
+	// The first event is taken by the worker, which then blocks on the gate;
+	// the queue (size 1) holds the second one.
+	fill := func(t *testing.T, publisher *eventbus.AsyncPublisher, recorder *batchRecorder) {
+		t.Helper()
+
+		require.NoError(t, publisher.Publish(context.Background(), "events:promotions", newAsyncTestEvent()))
+		<-recorder.entered
+		require.NoError(t, publisher.Publish(context.Background(), "events:promotions", newAsyncTestEvent()))
+	}
+
+	newPublisher := func(policy eventbus.OverflowPolicy, onError func(string, []eventbus.Event, error)) (*eventbus.AsyncPublisher, *batchRecorder) {
+		recorder := &batchRecorder{gate: make(chan struct{}), entered: make(chan struct{}, 1)}
+		publisher := eventbus.NewAsyncPublisher(recorder, eventbus.AsyncConfig{
+			QueueSize: 1,
+			BatchSize: 1,
+			Overflow:  policy,
+			OnError:   onError,
+		})
+
+		return publisher, recorder
+	}
+
+	t.Run("drop newest rejects the event", func(t *testing.T) {
+		publisher, recorder := newPublisher(eventbus.OverflowDropNewest, nil)
+		fill(t, publisher, recorder)
+
+		err := publisher.Publish(context.Background(), "events:promotions", newAsyncTestEvent())
+		assert.ErrorIs(t, err, eventbus.ErrEventDropped)
+
+		close(recorder.gate)
+		require.NoError(t, publisher.Close())
+		assert.Equal(t, 2, recorder.published())
+	})
+
+	t.Run("drop oldest evicts a queued event", func(t *testing.T) {
+		var dropped []eventbus.Event
+		publisher, recorder := newPublisher(eventbus.OverflowDropOldest, func(_ string, events []eventbus.Event, err error) {
+			if errors.Is(err, eventbus.ErrEventDropped) {
+				dropped = append(dropped, events...)
+			}
+		})
+		fill(t, publisher, recorder)
+
+		newest := newAsyncTestEvent()
+		require.NoError(t, publisher.Publish(context.Background(), "events:promotions", newest))
+		require.Len(t, dropped, 1)
+
+		close(recorder.gate)
+		require.NoError(t, publisher.Close())
+		assert.Equal(t, 2, recorder.published())
+		assert.Equal(t, newest.EventID(), recorder.batches[1][0].EventID())
+	})
+
+	t.Run("block waits for the context", func(t *testing.T) {
+		publisher, recorder := newPublisher(eventbus.OverflowBlock, nil)
+		fill(t, publisher, recorder)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+
+		err := publisher.Publish(ctx, "events:promotions", newAsyncTestEvent())
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+		close(recorder.gate)
+		require.NoError(t, publisher.Close())
+	})
+}
+
+func TestAsyncPublisher_CloseDrainsQueue(t *testing.T) {
+	recorder := &batchRecorder{}
+	publisher := eventbus.NewAsyncPublisher(recorder, eventbus.AsyncConfig{FlushInterval: time.Hour})
+
+	for i := 0; i < 5; i++ {
+		require.NoError(t, publisher.Publish(context.Background(), "events:promotions", newAsyncTestEvent()))
+	}
+
+	require.NoError(t, publisher.Close())
+	assert.Equal(t, 5, recorder.published())
+
+	err := publisher.Publish(context.Background(), "events:promotions", newAsyncTestEvent())
+	assert.ErrorIs(t, err, eventbus.ErrConnectionClosed)
+}
@@ -0,0 +1,110 @@
+package eventbus
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// Backend builds the publishers and subscribers of an event bus implementation.
+// Backends register under the Config.Type that selects them, like database/sql drivers,
+// usually from the init function of their package (e.g., package redis registers "redis").
+type Backend interface {
+	// NewPublisher creates a publisher from config.
+	NewPublisher(config Config) (EventPublisher, error)
+
+	// NewSubscriber creates a subscriber from config.
+	NewSubscriber(config Config) (EventSubscriber, error)
+}
+
+var (
+	backendsMu sync.RWMutex
+	backends   = make(map[string]Backend)
+)
+
+// RegisterBackend makes backend available under name for NewPublisher and NewSubscriber.
+// It panics if backend is nil or if name is already registered.
+func RegisterBackend(name string, backend Backend) {
+	if backend == nil {
+		panic("eventbus: nil backend " + name)
+	}
+
+	backendsMu.Lock()
+	defer backendsMu.Unlock()
+
+	if _, ok := backends[name]; ok {
+		panic("eventbus: backend " + name + " registered twice")
+	}
+
+	backends[name] = backend
+}
+
+// Backends returns the names of the registered backends, sorted.
+func Backends() []string {
+	backendsMu.RLock()
+	defer backendsMu.RUnlock()
+
+	return sortedKeys(backends)
+}
+
+// NewPublisher creates a publisher with the backend named by config.Type (default "redis").
+// The package of the backend must be imported, e.g.:
+//
+//	import _ "github.com/tclavelloux/promy-event-bus/redis"
+func NewPublisher(config Config) (EventPublisher, error) {
+	backend, err := lookupBackend(config.Type)
+	if err != nil {
+		return nil, err
+	}
+
+	return backend.NewPublisher(config)
+}
+
+// NewSubscriber creates a subscriber with the backend named by config.Type (default "redis").
+// The package of the backend must be imported (see NewPublisher).
+func NewSubscriber(config Config) (EventSubscriber, error) {
+	backend, err := lookupBackend(config.Type)
+	if err != nil {
+		return nil, err
+	}
+
+	return backend.NewSubscriber(config)
+}
+
+func lookupBackend(name string) (Backend, error) {
+	if name == "" {
+		name = DefaultType
+	}
+
+	backendsMu.RLock()
+	backend, ok := backends[name]
+	backendsMu.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown backend %q (forgotten import?)", ErrInvalidConfig, name)
+	}
+
+	return backend, nil
+}
+
+// backendRegistered reports whether a backend is registered under name.
+func backendRegistered(name string) bool {
+	backendsMu.RLock()
+	defer backendsMu.RUnlock()
+
+	_, ok := backends[name]
+
+	return ok
+}
+
+// acceptedTypes returns the values Validate accepts for Config.Type: the registered
+// backends, and "redis" whose configuration is validated here.
+func acceptedTypes() []string {
+	names := Backends()
+	if !backendRegistered(DefaultType) {
+		names = append(names, DefaultType)
+		sort.Strings(names)
+	}
+
+	return names
+}
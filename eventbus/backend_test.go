@@ -0,0 +1,49 @@
+package eventbus_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tclavelloux/promy-event-bus/eventbus"
+	"github.com/tclavelloux/promy-event-bus/testutil"
+)
+
+// mockBackend returns the publisher and subscriber it holds.
+type mockBackend struct {
+	publisher  *testutil.MockPublisher
+	subscriber *testutil.MockSubscriber
+}
+
+func (b mockBackend) NewPublisher(eventbus.Config) (eventbus.EventPublisher, error) {
+	return b.publisher, nil
+}
+
+func (b mockBackend) NewSubscriber(eventbus.Config) (eventbus.EventSubscriber, error) {
+	return b.subscriber, nil
+}
+
+func TestBackends(t *testing.T) {
+	backend := mockBackend{publisher: &testutil.MockPublisher{}, subscriber: &testutil.MockSubscriber{}}
+	eventbus.RegisterBackend("test-backend", backend)
+	t.Cleanup(func() { eventbus.UnregisterBackend("test-backend") })
+
+	assert.Contains(t, eventbus.Backends(), "test-backend")
+	assert.Panics(t, func() { eventbus.RegisterBackend("test-backend", backend) })
+	assert.Panics(t, func() { eventbus.RegisterBackend("test-nil", nil) })
+
+	config := eventbus.Config{Type: "test-backend"}
+	require.NoError(t, config.Validate(), "registered backends are valid types")
+
+	publisher, err := eventbus.NewPublisher(config)
+	require.NoError(t, err)
+	assert.Same(t, backend.publisher, publisher)
+
+	subscriber, err := eventbus.NewSubscriber(config)
+	require.NoError(t, err)
+	assert.Same(t, backend.subscriber, subscriber)
+
+	_, err = eventbus.NewPublisher(eventbus.Config{Type: "kafka"})
+	assert.ErrorIs(t, err, eventbus.ErrInvalidConfig)
+	assert.ErrorContains(t, err, `unknown backend "kafka"`)
+}
@@ -1,6 +1,7 @@
 package eventbus
 
 import (
+	"encoding/base64"
 	"encoding/json"
 	"time"
 
@@ -16,28 +17,86 @@ type DLQEntry struct {
 	OriginalStream    string    `json:"original_stream"    validate:"required"`
 	OriginalEventID   string    `json:"original_event_id"  validate:"required"`
 	OriginalEventType string    `json:"original_event_type" validate:"required"`
+	OriginalVersion   string    `json:"original_version,omitempty"`
 	OriginalPayload   string    `json:"original_payload"`
+	OriginalHeaders   Headers   `json:"original_headers,omitempty"`
 	FailureReason     string    `json:"failure_reason"     validate:"required"`
 	FailedAt          time.Time `json:"failed_at"          validate:"required"`
 	FailedService     string    `json:"failed_service"     validate:"required"`
 	AttemptsExhausted int       `json:"attempts_exhausted" validate:"required,min=1"`
+
+	// The remaining fields are set for payloads the subscriber could not fetch or decode.
+	// OriginalClaimCheck is the claim-check key of the payload, which OriginalPayload then
+	// leaves empty. Otherwise OriginalPayload holds the stored bytes, base64-encoded, and
+	// OriginalPayloadBase64 is set. OriginalEncoding and OriginalContentType describe the
+	// stored payload; both are empty for uncompressed JSON.
+	OriginalClaimCheck    string `json:"original_claim_check,omitempty"`
+	OriginalPayloadBase64 bool   `json:"original_payload_base64,omitempty"`
+	OriginalEncoding      string `json:"original_encoding,omitempty"`
+	OriginalContentType   string `json:"original_content_type,omitempty"`
+}
+
+// StoredEvent is implemented by delivered events whose Data and Headers differ from
+// the message stored in the stream, e.g. because encrypted fields were decrypted for the
+// handler or the payload was upcast.
+type StoredEvent interface {
+	// StoredData returns the payload as stored in the stream.
+	StoredData() string
+
+	// StoredHeaders returns the headers as stored in the stream.
+	StoredHeaders() Headers
+
+	// StoredVersion returns the schema version of the stored payload.
+	StoredVersion() string
+}
+
+// UndecodedEvent is implemented by delivered events whose payload could not be fetched
+// or decoded, so that their DLQ entry keeps the payload as stored.
+type UndecodedEvent interface {
+	// Undecoded reports whether the payload could not be fetched or decoded.
+	Undecoded() bool
+
+	// StoredClaimCheck returns the claim-check key of the payload, or "" if it was not claim-checked.
+	StoredClaimCheck() string
+
+	// StoredEncoding returns the compression encoding and content type of the stored payload.
+	StoredEncoding() (encoding, contentType string)
 }
 
 // NewDLQEntry creates a DLQ entry from a failed event.
+// Events implementing StoredEvent are recorded in their stored form, so that the DLQ
+// never holds decrypted data, and UndecodedEvent payloads as they were stored.
 func NewDLQEntry(stream string, event Event, err error, service string, attempts int) *DLQEntry {
 	now := time.Now().UTC()
 
-	return &DLQEntry{
+	payload, headers, version := event.Data(), EventHeaders(event), EventVersion(event)
+	if stored, ok := event.(StoredEvent); ok {
+		payload, headers, version = stored.StoredData(), stored.StoredHeaders().Clone(), stored.StoredVersion()
+	}
+
+	entry := &DLQEntry{
 		id:                uuid.New().String(),
 		OriginalStream:    stream,
 		OriginalEventID:   event.EventID(),
 		OriginalEventType: event.EventType(),
-		OriginalPayload:   event.Data(),
+		OriginalVersion:   version,
+		OriginalPayload:   payload,
+		OriginalHeaders:   headers,
 		FailureReason:     err.Error(),
 		FailedAt:          now,
 		FailedService:     service,
 		AttemptsExhausted: attempts,
 	}
+	if undecoded, ok := event.(UndecodedEvent); ok && undecoded.Undecoded() {
+		entry.OriginalEncoding, entry.OriginalContentType = undecoded.StoredEncoding()
+		if entry.OriginalClaimCheck = undecoded.StoredClaimCheck(); entry.OriginalClaimCheck == "" {
+			// Compressed or binary payloads are not valid UTF-8
+			entry.OriginalPayload = base64.StdEncoding.EncodeToString([]byte(payload))
+			entry.OriginalPayloadBase64 = true
+		}
+	}
+
+	return entry
 }
 
 func (d *DLQEntry) EventID() string      { return d.id }
@@ -26,6 +26,7 @@ func TestNewDLQEntry_Fields(t *testing.T) {
 	assert.Equal(t, "events:users", entry.OriginalStream)
 	assert.Equal(t, event.EventID(), entry.OriginalEventID)
 	assert.Equal(t, "user.registered", entry.OriginalEventType)
+	assert.Equal(t, "1.0", entry.OriginalVersion)
 	assert.Equal(t, event.Data(), entry.OriginalPayload)
 	assert.Equal(t, "timeout calling email service", entry.FailureReason)
 	assert.Equal(t, "promy-crm", entry.FailedService)
@@ -111,3 +112,41 @@ func TestNewDLQEntry_EmptyData(t *testing.T) {
 	assert.Equal(t, "", entry.OriginalPayload)
 	assert.NoError(t, entry.Validate())
 }
+
+func TestNewDLQEntry_KeepsHeaders(t *testing.T) {
+	event := &deliveredEvent{
+		TestEvent: testutil.NewTestEvent("user.registered", map[string]any{testUserIDKey: testUserID}),
+		headers:   eventbus.Headers{eventbus.HeaderCorrelationID: "corr-1"},
+	}
+
+	entry := eventbus.NewDLQEntry("events:users", event, errors.New("fail"), "promy-crm", 3)
+
+	assert.Equal(t, "corr-1", entry.OriginalHeaders.CorrelationID())
+}
+
+type storedEvent struct {
+	*deliveredEvent
+	stored string
+}
+
+func (e *storedEvent) StoredData() string { return e.stored }
+func (e *storedEvent) StoredHeaders() eventbus.Headers {
+	return eventbus.Headers{"encryption_key_id": "k1"}
+}
+func (e *storedEvent) StoredVersion() string { return "1.0" }
+
+func TestNewDLQEntry_KeepsStoredForm(t *testing.T) {
+	event := &storedEvent{
+		deliveredEvent: &deliveredEvent{
+			TestEvent: testutil.NewTestEvent("user.registered", map[string]any{"email": "thomas@example.com"}),
+		},
+		stored: `{"email":"ciphertext"}`,
+	}
+	event.Version = "2.0" // upcast for the handler
+
+	entry := eventbus.NewDLQEntry("events:users", event, errors.New("fail"), "promy-crm", 3)
+
+	assert.Equal(t, `{"email":"ciphertext"}`, entry.OriginalPayload)
+	assert.Equal(t, "k1", entry.OriginalHeaders["encryption_key_id"])
+	assert.Equal(t, "1.0", entry.OriginalVersion)
+}
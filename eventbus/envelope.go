@@ -0,0 +1,83 @@
+package eventbus
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Envelope is an event in serialized form: its identity, partition key, headers and JSON payload.
+// It implements Event, so events stored outside the bus (outbox, spool) can be
+// published again without their original Go type.
+type Envelope struct {
+	ID      string          `json:"id"`
+	Type    string          `json:"type"`
+	Version string          `json:"version,omitempty"`
+	Time    time.Time       `json:"time"`
+	Key     string          `json:"partition_key,omitempty"`
+	Header  Headers         `json:"headers,omitempty"`
+	Payload json.RawMessage `json:"payload"`
+}
+
+// NewEnvelope validates and serializes event. Its headers are resolved from ctx and
+// the event (see OutgoingHeaders), so they survive until the envelope is published.
+func NewEnvelope(ctx context.Context, event Event) (*Envelope, error) {
+	if err := validate(event); err != nil {
+		return nil, err
+	}
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal payload: %w", err)
+	}
+
+	return &Envelope{
+		ID:      event.EventID(),
+		Type:    event.EventType(),
+		Version: EventVersion(event),
+		Time:    event.EventTime(),
+		Key:     PartitionKey(event),
+		Header:  OutgoingHeaders(ctx, event),
+		Payload: payload,
+	}, nil
+}
+
+// EventType implements Event.
+func (e *Envelope) EventType() string { return e.Type }
+
+// EventID implements Event.
+func (e *Envelope) EventID() string { return e.ID }
+
+// EventTime implements Event.
+func (e *Envelope) EventTime() time.Time { return e.Time }
+
+// EventVersion implements VersionCarrier.
+func (e *Envelope) EventVersion() string { return e.Version }
+
+// PartitionKey implements PartitionKeyCarrier.
+func (e *Envelope) PartitionKey() string { return e.Key }
+
+// Data implements Event.
+func (e *Envelope) Data() string { return string(e.Payload) }
+
+// Headers implements HeaderCarrier.
+func (e *Envelope) Headers() Headers { return e.Header }
+
+// Validate checks that the envelope identifies an event and holds a JSON payload.
+// The payload itself was validated when the envelope was created.
+func (e *Envelope) Validate() error {
+	if e.ID == "" || e.Type == "" {
+		return fmt.Errorf("%w: envelope requires an id and a type", ErrInvalidEvent)
+	}
+	if !json.Valid(e.Payload) {
+		return fmt.Errorf("%w: envelope payload is not valid JSON", ErrInvalidEvent)
+	}
+
+	return nil
+}
+
+// MarshalJSON returns the payload, so publishers write the original event body.
+func (e *Envelope) MarshalJSON() ([]byte, error) {
+	return e.Payload, nil
+}
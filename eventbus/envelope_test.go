@@ -0,0 +1,46 @@
+package eventbus_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tclavelloux/promy-event-bus/eventbus"
+	"github.com/tclavelloux/promy-event-bus/testutil"
+)
+
+func TestNewEnvelope(t *testing.T) {
+	event := testutil.NewTestEvent("user.registered", map[string]any{testUserIDKey: testUserID})
+	event.Key = testUserID
+	ctx := eventbus.WithCorrelationID(context.Background(), "corr-1")
+
+	envelope, err := eventbus.NewEnvelope(ctx, event)
+	require.NoError(t, err)
+
+	assert.Equal(t, event.EventID(), envelope.EventID())
+	assert.Equal(t, event.EventType(), envelope.EventType())
+	assert.Equal(t, "1.0", eventbus.EventVersion(envelope))
+	assert.Equal(t, testUserID, eventbus.PartitionKey(envelope))
+	assert.True(t, event.EventTime().Equal(envelope.EventTime()))
+	assert.Equal(t, "corr-1", eventbus.EventHeaders(envelope).CorrelationID())
+	assert.NoError(t, envelope.Validate())
+
+	// Publishers marshal the original event body, not the envelope.
+	body, err := json.Marshal(envelope)
+	require.NoError(t, err)
+	expected, err := json.Marshal(event)
+	require.NoError(t, err)
+	assert.JSONEq(t, string(expected), string(body))
+}
+
+func TestNewEnvelope_InvalidEvent(t *testing.T) {
+	_, err := eventbus.NewEnvelope(context.Background(), &eventbus.DLQEntry{})
+	assert.ErrorIs(t, err, eventbus.ErrInvalidEvent)
+}
+
+func TestEnvelope_Validate(t *testing.T) {
+	assert.ErrorIs(t, (&eventbus.Envelope{Type: "user.registered", Payload: []byte(`{}`)}).Validate(), eventbus.ErrInvalidEvent)
+	assert.ErrorIs(t, (&eventbus.Envelope{ID: "e-1", Type: "user.registered", Payload: []byte(`{`)}).Validate(), eventbus.ErrInvalidEvent)
+}
@@ -1,6 +1,10 @@
 package eventbus
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
 
 // Domain-specific errors for event bus operations.
 var (
@@ -18,4 +22,50 @@ var (
 
 	// ErrConsumerGroupExists is returned when attempting to create an existing consumer group.
 	ErrConsumerGroupExists = errors.New("consumer group already exists")
+
+	// ErrInvalidConfig is returned when a configuration cannot be parsed or fails validation.
+	ErrInvalidConfig = errors.New("invalid config")
+
+	// ErrUnsupportedVersion is returned by handlers for event schema versions they do not handle.
+	// Subscribers dead-letter such events without retrying them.
+	ErrUnsupportedVersion = errors.New("unsupported event version")
 )
+
+// BatchError reports per-event results of an atomic batch publish.
+// When it is returned, no event of the batch was published.
+type BatchError struct {
+	// Errs holds one entry per event, in input order. A nil entry means the event
+	// itself was fine but was not published because another event failed.
+	Errs []error
+}
+
+// NewBatchError returns a BatchError for a batch of size events.
+func NewBatchError(size int) *BatchError {
+	return &BatchError{Errs: make([]error, size)}
+}
+
+// Error lists the failing events by index.
+func (e *BatchError) Error() string {
+	var b strings.Builder
+	b.WriteString("batch rejected")
+
+	for i, err := range e.Errs {
+		if err != nil {
+			fmt.Fprintf(&b, "; event %d: %v", i, err)
+		}
+	}
+
+	return b.String()
+}
+
+// Unwrap returns the non-nil per-event errors, so errors.Is and errors.As see them.
+func (e *BatchError) Unwrap() []error {
+	errs := make([]error, 0, len(e.Errs))
+	for _, err := range e.Errs {
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errs
+}
@@ -0,0 +1,22 @@
+package eventbus_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tclavelloux/promy-event-bus/eventbus"
+)
+
+func TestBatchError(t *testing.T) {
+	batchErr := eventbus.NewBatchError(3)
+	batchErr.Errs[1] = eventbus.ErrInvalidEvent
+
+	assert.Equal(t, "batch rejected; event 1: invalid event", batchErr.Error())
+	assert.ErrorIs(t, batchErr, eventbus.ErrInvalidEvent)
+	assert.NotErrorIs(t, batchErr, eventbus.ErrPublishFailed)
+
+	var target *eventbus.BatchError
+	assert.True(t, errors.As(error(batchErr), &target))
+	assert.Len(t, target.Errs, 3)
+}
@@ -25,6 +25,40 @@ type Event interface {
 	Validate() error
 }
 
+// DefaultEventVersion is the schema version of events that do not declare one.
+const DefaultEventVersion = "1.0"
+
+// VersionCarrier is implemented by events that declare their schema version, such as
+// BaseEvent. Events delivered to an EventHandler implement it.
+type VersionCarrier interface {
+	EventVersion() string
+}
+
+// EventVersion returns the schema version of event, or DefaultEventVersion if it declares none.
+func EventVersion(event Event) string {
+	if carrier, ok := event.(VersionCarrier); ok && carrier.EventVersion() != "" {
+		return carrier.EventVersion()
+	}
+
+	return DefaultEventVersion
+}
+
+// PartitionKeyCarrier is implemented by events that choose their partition in a
+// partitioned stream. Events with the same partition key go to the same partition,
+// so they are consumed in publication order (e.g., all events of one promotion).
+type PartitionKeyCarrier interface {
+	PartitionKey() string
+}
+
+// PartitionKey returns the partition key of event, or its ID if it declares none.
+func PartitionKey(event Event) string {
+	if carrier, ok := event.(PartitionKeyCarrier); ok && carrier.PartitionKey() != "" {
+		return carrier.PartitionKey()
+	}
+
+	return event.EventID()
+}
+
 // BaseEvent provides common event fields that all events should embed.
 type BaseEvent struct {
 	ID        string    `json:"id" validate:"required,uuid"`
@@ -40,7 +74,7 @@ func NewBaseEvent(eventType, source string) BaseEvent {
 		ID:        uuid.New().String(),
 		Type:      eventType,
 		Timestamp: time.Now().UTC(),
-		Version:   "1.0", // Event schema version
+		Version:   DefaultEventVersion,
 		Source:    source,
 	}
 }
@@ -60,6 +94,16 @@ func (e BaseEvent) EventTime() time.Time {
 	return e.Timestamp
 }
 
+// EventVersion returns the event schema version.
+func (e BaseEvent) EventVersion() string {
+	return e.Version
+}
+
+// EventSource returns the service that produced the event.
+func (e BaseEvent) EventSource() string {
+	return e.Source
+}
+
 // Data returns an empty string by default.
 // Concrete event structs should override this to return their JSON payload.
 // On the subscriber side, rawEvent.Data() returns the actual payload from Redis.
@@ -0,0 +1,36 @@
+package eventbus_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tclavelloux/promy-event-bus/eventbus"
+	"github.com/tclavelloux/promy-event-bus/testutil"
+)
+
+func TestEventVersion(t *testing.T) {
+	base := eventbus.NewBaseEvent("user.registered", "promy-user")
+	assert.Equal(t, eventbus.DefaultEventVersion, base.EventVersion())
+
+	base.Version = "2.0"
+	assert.Equal(t, "2.0", eventbus.EventVersion(base))
+
+	// Events without a version, or with an empty one, follow the default schema
+	assert.Equal(t, eventbus.DefaultEventVersion, eventbus.EventVersion(&eventbus.DLQEntry{}))
+
+	event := testutil.NewTestEvent("user.registered", nil)
+	event.Version = ""
+	assert.Equal(t, eventbus.DefaultEventVersion, eventbus.EventVersion(event))
+}
+
+func TestPartitionKey(t *testing.T) {
+	event := testutil.NewTestEvent("promotion.updated", nil)
+	assert.Equal(t, event.ID, eventbus.PartitionKey(event))
+
+	event.Key = "promo-1"
+	assert.Equal(t, "promo-1", eventbus.PartitionKey(event))
+
+	// Events without a partition key fall back to their ID
+	base := eventbus.NewBaseEvent("user.registered", "promy-user")
+	assert.Equal(t, base.EventID(), eventbus.PartitionKey(base))
+}
@@ -0,0 +1,10 @@
+package eventbus
+
+// UnregisterBackend removes the backend registered under name, so that tests registering
+// backends can run more than once, e.g. with go test -count=2.
+func UnregisterBackend(name string) {
+	backendsMu.Lock()
+	defer backendsMu.Unlock()
+
+	delete(backends, name)
+}
@@ -0,0 +1,121 @@
+package eventbus
+
+import "context"
+
+// Well-known header keys.
+const (
+	// HeaderCorrelationID groups every event that belongs to the same logical flow.
+	// It is set to the ID of the first event of the flow and inherited from there.
+	HeaderCorrelationID = "correlation_id"
+
+	// HeaderCausationID is the ID of the event whose handling produced this event.
+	HeaderCausationID = "causation_id"
+)
+
+// Headers carries string key/value pairs alongside an event, outside of its payload.
+// Keys are case-sensitive.
+type Headers map[string]string
+
+// CorrelationID returns the correlation ID header.
+func (h Headers) CorrelationID() string {
+	return h[HeaderCorrelationID]
+}
+
+// CausationID returns the causation ID header.
+func (h Headers) CausationID() string {
+	return h[HeaderCausationID]
+}
+
+// Clone returns a copy of the headers. It returns nil for empty headers.
+func (h Headers) Clone() Headers {
+	if len(h) == 0 {
+		return nil
+	}
+
+	clone := make(Headers, len(h))
+	for k, v := range h {
+		clone[k] = v
+	}
+
+	return clone
+}
+
+// HeaderCarrier is implemented by events that carry headers.
+// Events delivered to an EventHandler implement it.
+type HeaderCarrier interface {
+	Headers() Headers
+}
+
+// EventHeaders returns a copy of the headers carried by event, or nil if it carries none.
+func EventHeaders(event Event) Headers {
+	carrier, ok := event.(HeaderCarrier)
+	if !ok {
+		return nil
+	}
+
+	return carrier.Headers().Clone()
+}
+
+type headersContextKey struct{}
+
+// WithHeaders returns a copy of ctx carrying headers to attach to every event
+// published with it. Headers already present in ctx are kept unless overridden.
+func WithHeaders(ctx context.Context, headers Headers) context.Context {
+	merged := HeadersFromContext(ctx)
+	if merged == nil {
+		merged = make(Headers, len(headers))
+	}
+
+	for k, v := range headers {
+		merged[k] = v
+	}
+
+	return context.WithValue(ctx, headersContextKey{}, merged)
+}
+
+// WithCorrelationID returns a copy of ctx whose published events use the given correlation ID.
+func WithCorrelationID(ctx context.Context, correlationID string) context.Context {
+	return WithHeaders(ctx, Headers{HeaderCorrelationID: correlationID})
+}
+
+// HeadersFromContext returns a copy of the headers attached to ctx, or nil if there are none.
+func HeadersFromContext(ctx context.Context) Headers {
+	headers, _ := ctx.Value(headersContextKey{}).(Headers)
+
+	return headers.Clone()
+}
+
+// ContextWithCause returns a copy of ctx for handling event. Events published with
+// the returned context inherit the correlation ID of event and use its ID as causation ID.
+// Subscribers call it before invoking the handler.
+func ContextWithCause(ctx context.Context, event Event) context.Context {
+	correlationID := EventHeaders(event).CorrelationID()
+	if correlationID == "" {
+		correlationID = event.EventID()
+	}
+
+	return WithHeaders(ctx, Headers{
+		HeaderCorrelationID: correlationID,
+		HeaderCausationID:   event.EventID(),
+	})
+}
+
+// OutgoingHeaders resolves the headers to publish with event.
+// Context headers are applied first, then the headers carried by the event itself.
+// If no correlation ID is set, the event starts a new flow and its own ID is used.
+func OutgoingHeaders(ctx context.Context, event Event) Headers {
+	headers := HeadersFromContext(ctx)
+	if headers == nil {
+		headers = make(Headers)
+	}
+
+	for k, v := range EventHeaders(event) {
+		headers[k] = v
+	}
+
+	if headers.CorrelationID() == "" {
+		headers[HeaderCorrelationID] = event.EventID()
+	}
+
+	return headers
+}
@@ -0,0 +1,112 @@
+package eventbus_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tclavelloux/promy-event-bus/eventbus"
+	"github.com/tclavelloux/promy-event-bus/testutil"
+)
+
+// deliveredEvent mimics an event handed to a handler by a subscriber.
+type deliveredEvent struct {
+	*testutil.TestEvent
+	headers eventbus.Headers
+}
+
+func (e *deliveredEvent) Headers() eventbus.Headers { return e.headers }
+
+func TestWithHeaders_Merges(t *testing.T) {
+	ctx := eventbus.WithHeaders(context.Background(), eventbus.Headers{"tenant": "t-1", "source": "api"})
+	ctx = eventbus.WithHeaders(ctx, eventbus.Headers{"source": "worker"})
+
+	headers := eventbus.HeadersFromContext(ctx)
+
+	assert.Equal(t, eventbus.Headers{"tenant": "t-1", "source": "worker"}, headers)
+}
+
+func TestHeadersFromContext_ReturnsCopy(t *testing.T) {
+	ctx := eventbus.WithCorrelationID(context.Background(), "corr-1")
+
+	headers := eventbus.HeadersFromContext(ctx)
+	headers[eventbus.HeaderCorrelationID] = "mutated"
+
+	assert.Equal(t, "corr-1", eventbus.HeadersFromContext(ctx).CorrelationID())
+	assert.Nil(t, eventbus.HeadersFromContext(context.Background()))
+}
+
+func TestOutgoingHeaders(t *testing.T) {
+	t.Run("new flow is correlated by its own event ID", func(t *testing.T) {
+		event := testutil.NewTestEvent("promotion.created", nil)
+
+		headers := eventbus.OutgoingHeaders(context.Background(), event)
+
+		assert.Equal(t, event.EventID(), headers.CorrelationID())
+		assert.Empty(t, headers.CausationID())
+	})
+
+	t.Run("context correlation ID is kept", func(t *testing.T) {
+		event := testutil.NewTestEvent("promotion.created", nil)
+		ctx := eventbus.WithCorrelationID(context.Background(), "http-request-1")
+
+		headers := eventbus.OutgoingHeaders(ctx, event)
+
+		assert.Equal(t, "http-request-1", headers.CorrelationID())
+	})
+
+	t.Run("event headers override context headers", func(t *testing.T) {
+		event := &deliveredEvent{
+			TestEvent: testutil.NewTestEvent("promotion.created", nil),
+			headers:   eventbus.Headers{"source": "event"},
+		}
+		ctx := eventbus.WithHeaders(context.Background(), eventbus.Headers{"source": "ctx", "tenant": "t-1"})
+
+		headers := eventbus.OutgoingHeaders(ctx, event)
+
+		assert.Equal(t, "event", headers["source"])
+		assert.Equal(t, "t-1", headers["tenant"])
+	})
+}
+
+func TestContextWithCause(t *testing.T) {
+	t.Run("inherits correlation and sets causation", func(t *testing.T) {
+		parent := &deliveredEvent{
+			TestEvent: testutil.NewTestEvent("promotion.created", nil),
+			headers:   eventbus.Headers{eventbus.HeaderCorrelationID: "corr-1"},
+		}
+		child := testutil.NewTestEvent("product.identified", nil)
+
+		ctx := eventbus.ContextWithCause(context.Background(), parent)
+		headers := eventbus.OutgoingHeaders(ctx, child)
+
+		assert.Equal(t, "corr-1", headers.CorrelationID())
+		assert.Equal(t, parent.EventID(), headers.CausationID())
+	})
+
+	t.Run("uses parent ID as correlation when parent has none", func(t *testing.T) {
+		parent := testutil.NewTestEvent("promotion.created", nil)
+		child := testutil.NewTestEvent("product.identified", nil)
+
+		ctx := eventbus.ContextWithCause(context.Background(), parent)
+		headers := eventbus.OutgoingHeaders(ctx, child)
+
+		assert.Equal(t, parent.EventID(), headers.CorrelationID())
+		assert.Equal(t, parent.EventID(), headers.CausationID())
+	})
+}
+
+func TestEventHeaders(t *testing.T) {
+	assert.Nil(t, eventbus.EventHeaders(testutil.NewTestEvent("user.registered", nil)))
+
+	event := &deliveredEvent{
+		TestEvent: &testutil.TestEvent{ID: "e-1", Type: "user.registered", CreatedAt: time.Now()},
+		headers:   eventbus.Headers{eventbus.HeaderCausationID: "c-1"},
+	}
+
+	headers := eventbus.EventHeaders(event)
+	headers[eventbus.HeaderCausationID] = "mutated"
+
+	assert.Equal(t, "c-1", event.Headers().CausationID())
+}
@@ -0,0 +1,258 @@
+package eventbus
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// EnvPrefix prefixes the environment variables overriding configuration fields.
+const EnvPrefix = "EVENTBUS_"
+
+// configSection is the key of the event bus configuration in a service configuration file.
+const configSection = "event_bus"
+
+// LoadConfig reads the configuration file at path (see ParseConfig).
+func LoadConfig(path string) (Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Config{}, fmt.Errorf("failed to read config: %w", err)
+	}
+
+	return ParseConfig(data)
+}
+
+// ParseConfig parses a YAML configuration, either a Config document or a service
+// configuration holding it under an "event_bus" key (see examples/config.yaml).
+//
+// Values are interpolated from the environment first, shell style:
+//
+//	${VAR}          value of VAR, or empty
+//	${VAR:-default} default if VAR is unset or empty (${VAR-default}: unset only)
+//	${VAR:?message} fails with message if VAR is unset or empty (${VAR?message}: unset only)
+//	$$              a literal $
+//
+// Then environment variables named after the YAML path of a field override it, e.g.
+// EVENTBUS_REDIS_DSN or EVENTBUS_CONSUMER_DEFAULTS_BATCH_SIZE; lists are comma-separated
+// and maps cannot be overridden. Finally, documented defaults are applied and the
+// configuration is validated (see Config.Validate).
+func ParseConfig(data []byte) (Config, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return Config{}, fmt.Errorf("%w: %w", ErrInvalidConfig, err)
+	}
+
+	var config Config
+
+	if root := configRoot(&doc); root != nil {
+		if err := interpolate(root); err != nil {
+			return Config{}, fmt.Errorf("%w: %w", ErrInvalidConfig, err)
+		}
+
+		// Decode through a strict decoder to report unknown fields
+		raw, err := yaml.Marshal(root)
+		if err != nil {
+			return Config{}, fmt.Errorf("%w: %w", ErrInvalidConfig, err)
+		}
+
+		decoder := yaml.NewDecoder(bytes.NewReader(raw))
+		decoder.KnownFields(true)
+		if err := decoder.Decode(&config); err != nil {
+			return Config{}, fmt.Errorf("%w: %w", ErrInvalidConfig, err)
+		}
+	}
+
+	if err := overrideFromEnv(reflect.ValueOf(&config).Elem(), strings.TrimSuffix(EnvPrefix, "_")); err != nil {
+		return Config{}, fmt.Errorf("%w: %w", ErrInvalidConfig, err)
+	}
+
+	config.SetDefaults()
+
+	if err := config.Validate(); err != nil {
+		return Config{}, err
+	}
+
+	return config, nil
+}
+
+// configRoot returns the node holding the Config of doc, or nil for an empty document.
+func configRoot(doc *yaml.Node) *yaml.Node {
+	if doc.Kind != yaml.DocumentNode || len(doc.Content) == 0 {
+		return nil
+	}
+
+	root := doc.Content[0]
+	if root.Kind == yaml.MappingNode {
+		for i := 0; i+1 < len(root.Content); i += 2 {
+			if root.Content[i].Value == configSection {
+				return root.Content[i+1]
+			}
+		}
+	}
+
+	return root
+}
+
+// envPattern matches $$ and ${VAR}, ${VAR:-default}, ${VAR-default}, ${VAR:?message} and ${VAR?message}.
+var envPattern = regexp.MustCompile(`\$\$|\$\{([A-Za-z_][A-Za-z0-9_]*)(?:(:?[-?])([^}]*))?\}`)
+
+// interpolate expands environment references in the scalar values of node, reporting
+// every missing required variable. Unquoted values are retyped after expansion, so
+// that "${POOL_SIZE:-10}" decodes into an int, except as null: "${PASSWORD}" set to
+// "null" or "~" stays a string rather than an empty password.
+func interpolate(node *yaml.Node) error {
+	var errs []error
+
+	var walk func(n *yaml.Node, isKey bool)
+	walk = func(n *yaml.Node, isKey bool) {
+		switch n.Kind {
+		case yaml.ScalarNode:
+			if isKey || !strings.Contains(n.Value, "$") {
+				return
+			}
+
+			expanded, err := expandEnv(n.Value)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("line %d: %w", n.Line, err))
+
+				return
+			}
+
+			n.Value = expanded
+			if n.Style == 0 {
+				n.Tag = ""
+				if expanded != "" && n.ShortTag() == "!!null" {
+					n.Tag = "!!str"
+				}
+			}
+		case yaml.MappingNode:
+			for i, child := range n.Content {
+				walk(child, i%2 == 0)
+			}
+		default:
+			for _, child := range n.Content {
+				walk(child, false)
+			}
+		}
+	}
+	walk(node, false)
+
+	return errors.Join(errs...)
+}
+
+// expandEnv expands the environment references of s (see ParseConfig).
+func expandEnv(s string) (string, error) {
+	var errs []error
+
+	expanded := envPattern.ReplaceAllStringFunc(s, func(match string) string {
+		if match == "$$" {
+			return "$"
+		}
+
+		groups := envPattern.FindStringSubmatch(match)
+		name, operator, word := groups[1], groups[2], groups[3]
+		value, set := os.LookupEnv(name)
+		missing := !set || (strings.HasPrefix(operator, ":") && value == "")
+
+		switch {
+		case !missing || operator == "":
+			return value
+		case strings.HasSuffix(operator, "-"):
+			return word
+		default:
+			if word == "" {
+				word = "required"
+			}
+			errs = append(errs, fmt.Errorf("%s: %s", name, word))
+
+			return ""
+		}
+	})
+
+	return expanded, errors.Join(errs...)
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// overrideFromEnv sets the fields of the struct v from the environment variables named
+// prefix_<YAML NAME>, recursing into nested structs. Maps are skipped.
+func overrideFromEnv(v reflect.Value, prefix string) error {
+	var errs []error
+
+	for i := range v.NumField() {
+		field := v.Type().Field(i)
+
+		tag, _, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+		if tag == "" || tag == "-" {
+			continue
+		}
+
+		name := prefix + "_" + strings.ToUpper(tag)
+		value := v.Field(i)
+
+		if value.Kind() == reflect.Struct {
+			if err := overrideFromEnv(value, name); err != nil {
+				errs = append(errs, err)
+			}
+
+			continue
+		}
+
+		env, ok := os.LookupEnv(name)
+		if !ok {
+			continue
+		}
+
+		if err := setFromString(value, env); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", name, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// setFromString parses s into v according to its type.
+func setFromString(v reflect.Value, s string) error {
+	switch {
+	case v.Type() == durationType:
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+	case v.Kind() == reflect.String:
+		v.SetString(s)
+	case v.Kind() == reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case v.CanInt():
+		n, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.String:
+		var items []string
+		for _, item := range strings.Split(s, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		v.Set(reflect.ValueOf(items))
+	default:
+		return fmt.Errorf("cannot override a %s from the environment", v.Type())
+	}
+
+	return nil
+}
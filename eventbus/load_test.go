@@ -0,0 +1,154 @@
+package eventbus_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tclavelloux/promy-event-bus/eventbus"
+	"github.com/tclavelloux/promy-event-bus/streams"
+)
+
+func TestLoadConfig_Example(t *testing.T) {
+	t.Setenv("APP_NAME", "promy-notification")
+	t.Setenv("HOSTNAME", "pod-1")
+
+	config, err := eventbus.LoadConfig(filepath.Join("..", "examples", "config.yaml"))
+	require.NoError(t, err)
+
+	assert.Equal(t, "redis", config.Type)
+	assert.Equal(t, "redis://localhost:6379/0", config.Redis.DSN, "default of unset REDIS_URL")
+	assert.Equal(t, 20, config.Redis.PoolSize)
+	assert.Equal(t, 100*time.Millisecond, config.Redis.MinRetryBackoff)
+	assert.Equal(t, "promy-notification-consumers", config.Consumer.Group)
+	assert.Equal(t, "promy-notification-pod-1", config.Consumer.ConsumerID)
+	assert.Equal(t, 1, config.Consumer.StreamConfig(streams.StreamUsers).MaxConcurrency)
+}
+
+func TestParseConfig_Interpolation(t *testing.T) {
+	t.Setenv("POOL_SIZE", "25")
+	t.Setenv("EMPTY", "")
+
+	config, err := eventbus.ParseConfig([]byte(`
+redis:
+  dsn: ${REDIS_URL-redis://localhost:6379/1}
+  pool_size: ${POOL_SIZE}
+  username: ${EMPTY:-svc}
+  password: "p$$ss${EMPTY-unused}"
+`))
+	require.NoError(t, err)
+
+	assert.Equal(t, "redis://localhost:6379/1", config.Redis.DSN)
+	assert.Equal(t, 25, config.Redis.PoolSize)
+	assert.Equal(t, "svc", config.Redis.Username)
+	assert.Equal(t, "p$ss", config.Redis.Password)
+
+	// Interpolated values reading as null stay strings
+	t.Setenv("NULL_PASSWORD", "null")
+	t.Setenv("TILDE_USERNAME", "~")
+	config, err = eventbus.ParseConfig([]byte(`
+redis:
+  dsn: redis://localhost:6379/1
+  username: ${TILDE_USERNAME}
+  password: ${NULL_PASSWORD}
+`))
+	require.NoError(t, err)
+	assert.Equal(t, "~", config.Redis.Username)
+	assert.Equal(t, "null", config.Redis.Password)
+
+	_, err = eventbus.ParseConfig([]byte("redis:\n  dsn: ${MISSING_REDIS_URL:?set the Redis URL}\n"))
+	assert.ErrorIs(t, err, eventbus.ErrInvalidConfig)
+	assert.ErrorContains(t, err, "MISSING_REDIS_URL: set the Redis URL")
+}
+
+func TestParseConfig_Defaults(t *testing.T) {
+	config, err := eventbus.ParseConfig([]byte("redis:\n  dsn: redis://localhost:6379/0\n"))
+	require.NoError(t, err)
+
+	assert.Equal(t, eventbus.DefaultType, config.Type)
+	assert.Equal(t, eventbus.DefaultRedisPoolSize, config.Redis.PoolSize)
+	assert.Equal(t, eventbus.DefaultRedisDialTimeout, config.Redis.DialTimeout)
+	assert.Equal(t, eventbus.DefaultRedisWriteTimeout, config.Redis.WriteTimeout)
+
+	// Options of the DSN are not replaced by defaults
+	config, err = eventbus.ParseConfig([]byte("redis:\n  dsn: redis://localhost:6379/0?pool_size=50&dial_timeout=20s\n"))
+	require.NoError(t, err)
+
+	assert.Zero(t, config.Redis.PoolSize)
+	assert.Zero(t, config.Redis.DialTimeout)
+	assert.Equal(t, eventbus.DefaultRedisWriteTimeout, config.Redis.WriteTimeout)
+}
+
+func TestParseConfig_EnvOverrides(t *testing.T) {
+	t.Setenv("EVENTBUS_REDIS_DSN", "redis://redis.internal:6379/0")
+	t.Setenv("EVENTBUS_REDIS_ADDRS", "node-0:6379, node-1:6379")
+	t.Setenv("EVENTBUS_REDIS_TLS_ENABLED", "true")
+	t.Setenv("EVENTBUS_CONSUMER_CONSUMER_ID", "worker-2")
+	t.Setenv("EVENTBUS_CONSUMER_DEFAULTS_BLOCK_DURATION", "5s")
+
+	config, err := eventbus.ParseConfig([]byte(`
+event_bus:
+  redis:
+    dsn: redis://localhost:6379/0
+  consumer:
+    group: notifications
+    consumer_id: worker-1
+`))
+	require.NoError(t, err)
+
+	assert.Equal(t, "redis://redis.internal:6379/0", config.Redis.DSN)
+	assert.Equal(t, []string{"node-0:6379", "node-1:6379"}, config.Redis.Addrs)
+	assert.True(t, config.Redis.TLS.Enabled)
+	assert.Equal(t, "worker-2", config.Consumer.ConsumerID)
+	assert.Equal(t, 5*time.Second, config.Consumer.Defaults.BlockDuration)
+
+	t.Setenv("EVENTBUS_REDIS_POOL_SIZE", "many")
+	_, err = eventbus.ParseConfig(nil)
+	assert.ErrorIs(t, err, eventbus.ErrInvalidConfig)
+	assert.ErrorContains(t, err, "EVENTBUS_REDIS_POOL_SIZE")
+}
+
+func TestParseConfig_UnknownField(t *testing.T) {
+	_, err := eventbus.ParseConfig([]byte("redis:\n  dsn: redis://localhost:6379/0\n  poolsize: 10\n"))
+	assert.ErrorIs(t, err, eventbus.ErrInvalidConfig)
+	assert.ErrorContains(t, err, "poolsize")
+}
+
+func TestConfig_Validate(t *testing.T) {
+	config := eventbus.Config{
+		Type: "kafka",
+		Redis: eventbus.RedisConfig{
+			Retention: map[string]eventbus.StreamRetention{"events:unknown": {MaxLen: 10}},
+		},
+		Consumer: eventbus.ConsumerConfig{
+			Streams: map[string]eventbus.ConsumerStreamConfig{streams.StreamUsers: {MaxConcurrency: 1}},
+		},
+	}
+
+	err := config.Validate()
+	assert.ErrorIs(t, err, eventbus.ErrInvalidConfig)
+	for _, problem := range []string{
+		`type: unknown type "kafka"`,
+		"consumer.group: must be set",
+		"consumer.consumer_id: must be set",
+	} {
+		assert.ErrorContains(t, err, problem)
+	}
+
+	config.Type = "redis"
+	err = config.Validate()
+	assert.ErrorContains(t, err, "redis.dsn: set dsn or addrs")
+	assert.ErrorContains(t, err, `redis.retention: stream "events:unknown" is not declared in the schema registry`)
+
+	// Publisher-only services have no consumer section
+	publisher := eventbus.Config{Type: "redis", Redis: eventbus.RedisConfig{DSN: "redis://localhost:6379/0"}}
+	assert.NoError(t, publisher.Validate())
+}
+
+func TestLoadConfig_MissingFile(t *testing.T) {
+	_, err := eventbus.LoadConfig(filepath.Join(t.TempDir(), "missing.yaml"))
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
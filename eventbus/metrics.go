@@ -0,0 +1,54 @@
+package eventbus
+
+import "time"
+
+// Outcome describes how the handling of a delivered event ended.
+type Outcome string
+
+const (
+	// OutcomeSuccess means the handler returned nil and the event was acknowledged.
+	OutcomeSuccess Outcome = "success"
+
+	// OutcomeRetry means the handler failed and the event was scheduled for another attempt.
+	OutcomeRetry Outcome = "retry"
+
+	// OutcomeDeadLettered means the handler failed on the last attempt and the event was routed to the DLQ.
+	OutcomeDeadLettered Outcome = "dead_lettered"
+
+	// OutcomeDropped means the handler failed on the last attempt and no DLQ was configured,
+	// or the event could not be scheduled for another attempt.
+	OutcomeDropped Outcome = "dropped"
+)
+
+// Metrics receives instrumentation from publishers and subscribers.
+// Implementations must be safe for concurrent use.
+type Metrics interface {
+	// EventPublished records one publish of an event. err is nil on success.
+	EventPublished(stream, eventType string, duration time.Duration, err error)
+
+	// EventHandled records one handler invocation and its outcome.
+	EventHandled(stream, group, eventType string, outcome Outcome, duration time.Duration)
+
+	// EventRetried records that an event was scheduled for the given attempt.
+	EventRetried(stream, group, eventType string, attempt int)
+
+	// EventDeadLettered records that an event was routed to the DLQ.
+	EventDeadLettered(stream, group, eventType string)
+
+	// InFlight adjusts the number of events currently being handled by delta.
+	InFlight(stream, group string, delta int)
+
+	// EndToEndLatency records the time between EventTime() and the start of handling.
+	EndToEndLatency(stream, group, eventType string, latency time.Duration)
+}
+
+// NopMetrics is a Metrics implementation that discards everything.
+// It is the default when no metrics are configured.
+type NopMetrics struct{}
+
+func (NopMetrics) EventPublished(string, string, time.Duration, error)         {}
+func (NopMetrics) EventHandled(string, string, string, Outcome, time.Duration) {}
+func (NopMetrics) EventRetried(string, string, string, int)                    {}
+func (NopMetrics) EventDeadLettered(string, string, string)                    {}
+func (NopMetrics) InFlight(string, string, int)                                {}
+func (NopMetrics) EndToEndLatency(string, string, string, time.Duration)       {}
@@ -1,6 +1,9 @@
 package eventbus
 
-import "context"
+import (
+	"context"
+	"time"
+)
 
 // EventPublisher publishes events to a stream.
 // Implementations must be safe for concurrent use.
@@ -19,3 +22,43 @@ type EventPublisher interface {
 	// Health checks the connection health.
 	Health(ctx context.Context) error
 }
+
+// PublishResult describes the outcome of publishing one event.
+type PublishResult struct {
+	// MessageID is the backend ID of the stored message (e.g., a Redis stream entry ID).
+	MessageID string
+
+	// Duplicate is set when the event had already been published and was not stored again.
+	// MessageID is then the ID of the original message.
+	Duplicate bool
+}
+
+// StreamEvent pairs an event with the stream it is published to.
+type StreamEvent struct {
+	Stream string
+	Event  Event
+}
+
+// MultiStreamPublisher publishes events to several streams in one atomic operation.
+// Implementations must be safe for concurrent use.
+type MultiStreamPublisher interface {
+	// PublishMulti publishes every event to its stream atomically.
+	// All events are published or none are published.
+	PublishMulti(ctx context.Context, events []StreamEvent) error
+}
+
+// ScheduledPublisher publishes events at a later time, e.g. reminders or events that
+// take effect at a given date. Scheduled events survive restarts of the publisher.
+// Implementations must be safe for concurrent use.
+type ScheduledPublisher interface {
+	// PublishAt publishes event to stream at the given time, or as soon as possible if
+	// it is in the past. Scheduling an event ID again replaces the previous schedule.
+	PublishAt(ctx context.Context, stream string, event Event, at time.Time) error
+
+	// PublishAfter publishes event to stream once delay has elapsed.
+	PublishAfter(ctx context.Context, stream string, event Event, delay time.Duration) error
+
+	// CancelScheduled cancels the scheduled event with the given ID and reports whether
+	// it was still scheduled.
+	CancelScheduled(ctx context.Context, eventID string) (bool, error)
+}
@@ -2,6 +2,7 @@ package eventbus
 
 import (
 	"context"
+	"fmt"
 	"time"
 )
 
@@ -58,4 +59,21 @@ type SubscriptionConfig struct {
 // EventHandler processes a single event.
 // Return nil if the event was processed successfully.
 // Return an error to trigger retry logic.
+// Return an error wrapping ErrUnsupportedVersion to dead-letter the event without retries.
 type EventHandler func(ctx context.Context, event Event) error
+
+// RouteByVersion returns a handler that dispatches each event to the handler of its
+// schema version (see EventVersion). Events of other versions are rejected with
+// ErrUnsupportedVersion, so they are dead-lettered rather than retried.
+func RouteByVersion(handlers map[string]EventHandler) EventHandler {
+	return func(ctx context.Context, event Event) error {
+		version := EventVersion(event)
+
+		handler, ok := handlers[version]
+		if !ok {
+			return fmt.Errorf("%w: %s %s", ErrUnsupportedVersion, event.EventType(), version)
+		}
+
+		return handler(ctx, event)
+	}
+}
@@ -0,0 +1,38 @@
+package eventbus_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tclavelloux/promy-event-bus/eventbus"
+	"github.com/tclavelloux/promy-event-bus/testutil"
+)
+
+func TestRouteByVersion(t *testing.T) {
+	var handled []string
+	handler := eventbus.RouteByVersion(map[string]eventbus.EventHandler{
+		"1.0": func(_ context.Context, _ eventbus.Event) error {
+			handled = append(handled, "v1")
+			return nil
+		},
+		"2.0": func(_ context.Context, _ eventbus.Event) error {
+			handled = append(handled, "v2")
+			return nil
+		},
+	})
+
+	ctx := context.Background()
+	event := testutil.NewTestEvent("promotion.created", nil)
+	require.NoError(t, handler(ctx, event))
+
+	event.Version = "2.0"
+	require.NoError(t, handler(ctx, event))
+	assert.Equal(t, []string{"v1", "v2"}, handled)
+
+	event.Version = "3.0"
+	err := handler(ctx, event)
+	assert.ErrorIs(t, err, eventbus.ErrUnsupportedVersion)
+	assert.Contains(t, err.Error(), "promotion.created 3.0")
+}
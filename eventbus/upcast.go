@@ -0,0 +1,131 @@
+package eventbus
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+)
+
+// Upcaster converts the JSON payload of an event from one schema version to the next,
+// e.g. by filling a field that became required.
+type Upcaster func(raw []byte) ([]byte, error)
+
+// Upcasters holds the upcasters of each event type. Subscribers apply them so that
+// handlers only see the latest version of an event. It is safe for concurrent use.
+type Upcasters struct {
+	mu    sync.RWMutex
+	steps map[upcastKey]upcastStep
+}
+
+type upcastKey struct {
+	eventType string
+	version   string
+}
+
+type upcastStep struct {
+	to       string
+	upcaster Upcaster
+}
+
+// NewUpcasters returns an empty set of upcasters.
+func NewUpcasters() *Upcasters {
+	return &Upcasters{steps: make(map[upcastKey]upcastStep)}
+}
+
+// Register adds the upcaster of eventType from version from to version to.
+// Each version of an event type upgrades to a single version. Register panics if
+// upcaster is nil, if from equals to, or if from already has an upcaster.
+func (u *Upcasters) Register(eventType, from, to string, upcaster Upcaster) {
+	if upcaster == nil {
+		panic("eventbus: nil upcaster for " + eventType)
+	}
+	if from == to {
+		panic(fmt.Sprintf("eventbus: upcaster of %s from %s to itself", eventType, from))
+	}
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	key := upcastKey{eventType: eventType, version: from}
+	if _, ok := u.steps[key]; ok {
+		panic(fmt.Sprintf("eventbus: upcaster of %s from %s registered twice", eventType, from))
+	}
+
+	u.steps[key] = upcastStep{to: to, upcaster: upcaster}
+}
+
+// Upcast applies the chain of upcasters of eventType starting at version and returns
+// the payload and version it ends at. Payloads without upcasters are returned as is.
+// A top-level "version" field of the payload, as written by BaseEvent, is updated too.
+func (u *Upcasters) Upcast(eventType, version string, raw []byte) ([]byte, string, error) {
+	if version == "" {
+		version = DefaultEventVersion
+	}
+
+	u.mu.RLock()
+	defer u.mu.RUnlock()
+
+	from := version
+	seen := map[string]bool{version: true}
+
+	for {
+		step, ok := u.steps[upcastKey{eventType: eventType, version: version}]
+		if !ok {
+			break
+		}
+		if seen[step.to] {
+			return nil, "", fmt.Errorf("upcasters of %s loop from %s to %s", eventType, version, step.to)
+		}
+
+		upcast, err := step.upcaster(raw)
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to upcast %s from %s to %s: %w", eventType, version, step.to, err)
+		}
+
+		raw, version = upcast, step.to
+		seen[version] = true
+	}
+
+	if version == from {
+		return raw, version, nil
+	}
+
+	raw, err := setPayloadVersion(raw, version)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to upcast %s to %s: %w", eventType, version, err)
+	}
+
+	return raw, version, nil
+}
+
+// setPayloadVersion sets the "version" field of a JSON object payload that has one.
+func setPayloadVersion(raw []byte, version string) ([]byte, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		return nil, err
+	}
+
+	if _, ok := fields["version"]; !ok {
+		return raw, nil
+	}
+
+	fields["version"], _ = json.Marshal(version) //nolint:errchkjson // a string always marshals
+
+	return json.Marshal(fields)
+}
+
+var defaultUpcasters = NewUpcasters()
+
+// RegisterUpcaster registers an upcaster of eventType from version from to version to
+// in DefaultUpcasters. It is meant to be called from init functions, e.g.:
+//
+//	eventbus.RegisterUpcaster("promotion.created", "1.0", "2.0", func(raw []byte) ([]byte, error) { ... })
+func RegisterUpcaster(eventType, from, to string, upcaster Upcaster) {
+	defaultUpcasters.Register(eventType, from, to, upcaster)
+}
+
+// DefaultUpcasters returns the upcasters registered with RegisterUpcaster. Subscribers
+// and replay tooling use them unless configured otherwise.
+func DefaultUpcasters() *Upcasters {
+	return defaultUpcasters
+}
@@ -0,0 +1,84 @@
+package eventbus_test
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tclavelloux/promy-event-bus/eventbus"
+)
+
+// addField returns an upcaster setting field to value.
+func addField(field string, value any) eventbus.Upcaster {
+	return func(raw []byte) ([]byte, error) {
+		var payload map[string]any
+		if err := json.Unmarshal(raw, &payload); err != nil {
+			return nil, err
+		}
+
+		payload[field] = value
+
+		return json.Marshal(payload)
+	}
+}
+
+func TestUpcasters_Chain(t *testing.T) {
+	upcasters := eventbus.NewUpcasters()
+	upcasters.Register("promotion.created", "1.0", "2.0", addField("currency", "EUR"))
+	upcasters.Register("promotion.created", "2.0", "2.1", addField("store_id", "unknown"))
+
+	payload, version, err := upcasters.Upcast("promotion.created", "1.0", []byte(`{"promotion_id":"promo-1","version":"1.0"}`))
+	require.NoError(t, err)
+	assert.Equal(t, "2.1", version)
+	assert.JSONEq(t, `{"promotion_id":"promo-1","version":"2.1","currency":"EUR","store_id":"unknown"}`, string(payload))
+
+	// Later versions only run the rest of the chain; an empty version is the default one
+	payload, version, err = upcasters.Upcast("promotion.created", "2.0", []byte(`{"promotion_id":"promo-1"}`))
+	require.NoError(t, err)
+	assert.Equal(t, "2.1", version)
+	assert.JSONEq(t, `{"promotion_id":"promo-1","store_id":"unknown"}`, string(payload))
+
+	_, version, err = upcasters.Upcast("promotion.created", "", []byte(`{}`))
+	require.NoError(t, err)
+	assert.Equal(t, "2.1", version)
+}
+
+func TestUpcasters_NoUpcaster(t *testing.T) {
+	upcasters := eventbus.NewUpcasters()
+	upcasters.Register("promotion.created", "1.0", "2.0", addField("currency", "EUR"))
+
+	for _, tc := range []struct{ eventType, version string }{
+		{"promotion.updated", "1.0"},
+		{"promotion.created", "2.0"},
+	} {
+		payload, version, err := upcasters.Upcast(tc.eventType, tc.version, []byte(`not json`))
+		require.NoError(t, err)
+		assert.Equal(t, tc.version, version)
+		assert.Equal(t, "not json", string(payload))
+	}
+}
+
+func TestUpcasters_Errors(t *testing.T) {
+	upcasters := eventbus.NewUpcasters()
+	upcasters.Register("promotion.created", "1.0", "2.0", func([]byte) ([]byte, error) { return nil, assert.AnError })
+	upcasters.Register("promotion.updated", "1.0", "2.0", addField("a", 1))
+	upcasters.Register("promotion.updated", "2.0", "1.0", addField("b", 2))
+
+	_, _, err := upcasters.Upcast("promotion.created", "1.0", []byte(`{}`))
+	assert.True(t, errors.Is(err, assert.AnError))
+	assert.Contains(t, err.Error(), "promotion.created from 1.0 to 2.0")
+
+	_, _, err = upcasters.Upcast("promotion.updated", "1.0", []byte(`{}`))
+	assert.ErrorContains(t, err, "loop")
+}
+
+func TestUpcasters_RegisterPanics(t *testing.T) {
+	upcasters := eventbus.NewUpcasters()
+	upcasters.Register("promotion.created", "1.0", "2.0", addField("currency", "EUR"))
+
+	assert.Panics(t, func() { upcasters.Register("promotion.created", "1.0", "3.0", addField("a", 1)) })
+	assert.Panics(t, func() { upcasters.Register("promotion.created", "2.0", "2.0", addField("a", 1)) })
+	assert.Panics(t, func() { upcasters.Register("promotion.created", "2.0", "3.0", nil) })
+}
@@ -0,0 +1,41 @@
+package memory
+
+import (
+	eventbus "github.com/tclavelloux/promy-event-bus/eventbus"
+)
+
+func init() {
+	eventbus.RegisterBackend("memory", NewBackend(Default()))
+}
+
+// backend builds publishers and subscribers over a broker for eventbus.NewPublisher and
+// eventbus.NewSubscriber.
+type backend struct {
+	broker *Broker
+	opts   []Option
+}
+
+// NewBackend returns a backend creating publishers and subscribers over broker, the
+// subscribers with opts. Package memory registers one over the Default broker as
+// "memory". Backends cannot be unregistered, so register others once per test binary,
+// e.g. in TestMain:
+//
+//	var isolated = memory.NewBroker()
+//
+//	func TestMain(m *testing.M) {
+//		eventbus.RegisterBackend("memory-isolated", memory.NewBackend(isolated))
+//		os.Exit(m.Run())
+//	}
+func NewBackend(broker *Broker, opts ...Option) eventbus.Backend {
+	return backend{broker: broker, opts: opts}
+}
+
+// NewPublisher implements eventbus.Backend with NewPublisher.
+func (b backend) NewPublisher(eventbus.Config) (eventbus.EventPublisher, error) {
+	return NewPublisher(b.broker), nil
+}
+
+// NewSubscriber implements eventbus.Backend with NewSubscriber.
+func (b backend) NewSubscriber(eventbus.Config) (eventbus.EventSubscriber, error) {
+	return NewSubscriber(b.broker, b.opts...), nil
+}
@@ -0,0 +1,39 @@
+//nolint:all // Test file
+package memory_test
+
+import (
+	"context"
+	"testing"
+
+	eventbus "github.com/tclavelloux/promy-event-bus/eventbus"
+	"github.com/tclavelloux/promy-event-bus/memory"
+	"github.com/tclavelloux/promy-event-bus/testutil"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBackend(t *testing.T) {
+	memory.Default().Reset()
+	defer memory.Default().Reset()
+
+	config := eventbus.Config{Type: "memory"}
+	require.NoError(t, config.Validate())
+
+	publisher, err := eventbus.NewPublisher(config)
+	require.NoError(t, err)
+	defer publisher.Close()
+	assert.IsType(t, &memory.Publisher{}, publisher)
+
+	subscriber, err := eventbus.NewSubscriber(config)
+	require.NoError(t, err)
+	defer subscriber.Close()
+	assert.IsType(t, &memory.Subscriber{}, subscriber)
+
+	event := testutil.NewTestEvent("user.registered", nil)
+	require.NoError(t, publisher.Publish(context.Background(), "events:users", event))
+
+	events := memory.Default().Events("events:users")
+	require.Len(t, events, 1)
+	assert.Equal(t, event.EventID(), events[0].EventID())
+}
@@ -0,0 +1,360 @@
+// Package memory implements eventbus.EventPublisher and eventbus.EventSubscriber in
+// memory, for tests of services that publish and consume events without a Redis server.
+//
+// A Broker holds streams with the semantics of Redis Streams consumer groups: every group
+// sees every message of a stream, consumers of a group share its messages, and a delivered
+// message stays pending until it is acknowledged. Subscribers process messages like the
+// redis package does: failed events are retried as new messages with an incremented
+// attempt, then routed to the DLQ after the last attempt.
+//
+// The package registers the "memory" backend (see eventbus.RegisterBackend), whose
+// publishers and subscribers share the Default broker:
+//
+//	import _ "github.com/tclavelloux/promy-event-bus/memory"
+//
+//	publisher, err := eventbus.NewPublisher(eventbus.Config{Type: "memory"})
+//
+// Partitions, retention, scheduling and the payload options of the redis package
+// (codecs, compression, encryption, claim checks) are not modelled.
+package memory
+
+import (
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	eventbus "github.com/tclavelloux/promy-event-bus/eventbus"
+)
+
+// Broker holds streams and their consumer groups. Publishers and subscribers created over
+// the same broker share its streams. It is safe for concurrent use.
+type Broker struct {
+	mu      sync.Mutex
+	streams map[string]*stream
+}
+
+var defaultBroker = NewBroker()
+
+// NewBroker returns an empty broker.
+func NewBroker() *Broker {
+	return &Broker{streams: make(map[string]*stream)}
+}
+
+// Default returns the broker of the "memory" backend.
+func Default() *Broker {
+	return defaultBroker
+}
+
+// stream is an append-only log of messages and the consumer groups reading it.
+type stream struct {
+	messages []*message
+	groups   map[string]*group
+
+	// added is closed and replaced whenever messages are added, waking blocked readers.
+	added chan struct{}
+}
+
+// message is a stream entry: an event in its stored form and its delivery attempt.
+type message struct {
+	id      string
+	record  record
+	attempt int
+}
+
+// group tracks the progress of a consumer group in a stream.
+type group struct {
+	// next is the index of the first message not yet delivered to the group.
+	next    int
+	pending map[string]*pendingEntry
+}
+
+// pendingEntry is a message delivered to a consumer and not acknowledged yet.
+type pendingEntry struct {
+	msg        *message
+	index      int
+	consumer   string
+	delivered  time.Time
+	deliveries int
+}
+
+// PendingMessage describes a message delivered to a consumer of a group and not
+// acknowledged yet.
+type PendingMessage struct {
+	// ID is the ID of the message in the stream.
+	ID string
+
+	// Consumer is the consumer the message was last delivered to.
+	Consumer string
+
+	// Deliveries counts how many times the message was delivered.
+	Deliveries int
+
+	// Attempt is the delivery attempt of the event, as counted by the retry logic.
+	Attempt int
+
+	// Event is the pending event.
+	Event eventbus.Event
+}
+
+// Events returns the events of stream in order, including retries of failed events.
+// Events implement eventbus.HeaderCarrier and eventbus.VersionCarrier.
+func (b *Broker) Events(name string) []eventbus.Event {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	s, ok := b.streams[name]
+	if !ok {
+		return nil
+	}
+
+	events := make([]eventbus.Event, len(s.messages))
+	for i, msg := range s.messages {
+		events[i] = msg.record.event()
+	}
+
+	return events
+}
+
+// Pending returns the pending messages of group in stream, in stream order.
+func (b *Broker) Pending(name, groupName string) []PendingMessage {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	s, ok := b.streams[name]
+	if !ok {
+		return nil
+	}
+
+	g, ok := s.groups[groupName]
+	if !ok {
+		return nil
+	}
+
+	entries := make([]*pendingEntry, 0, len(g.pending))
+	for _, entry := range g.pending {
+		entries = append(entries, entry)
+	}
+	slices.SortFunc(entries, func(a, b *pendingEntry) int { return a.index - b.index })
+
+	pending := make([]PendingMessage, len(entries))
+	for i, entry := range entries {
+		pending[i] = PendingMessage{
+			ID:         entry.msg.id,
+			Consumer:   entry.consumer,
+			Deliveries: entry.deliveries,
+			Attempt:    entry.msg.attempt,
+			Event:      entry.msg.record.event(),
+		}
+	}
+
+	return pending
+}
+
+// Reset deletes every stream and consumer group, e.g. between tests sharing the Default
+// broker. Running subscriptions keep waiting for messages of their streams.
+func (b *Broker) Reset() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for _, s := range b.streams {
+		close(s.added)
+	}
+
+	b.streams = make(map[string]*stream)
+}
+
+// stream returns the stream called name, creating it if needed. b.mu must be held.
+func (b *Broker) stream(name string) *stream {
+	s, ok := b.streams[name]
+	if !ok {
+		s = &stream{groups: make(map[string]*group), added: make(chan struct{})}
+		b.streams[name] = s
+	}
+
+	return s
+}
+
+// entry is a message to append to a stream.
+type entry struct {
+	stream  string
+	record  record
+	attempt int
+}
+
+// append adds entries to their streams atomically.
+func (b *Broker) append(entries []entry) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now().UnixMilli()
+	touched := make(map[*stream]bool)
+
+	for _, e := range entries {
+		s := b.stream(e.stream)
+		id := fmt.Sprintf("%d-%d", now, len(s.messages))
+		s.messages = append(s.messages, &message{id: id, record: e.record, attempt: e.attempt})
+		touched[s] = true
+	}
+
+	for s := range touched {
+		close(s.added)
+		s.added = make(chan struct{})
+	}
+}
+
+// createGroup creates the consumer group of stream if it doesn't exist. Like a group
+// created from ID "0", it starts with the first message of the stream.
+func (b *Broker) createGroup(name, groupName string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	s := b.stream(name)
+	if _, ok := s.groups[groupName]; !ok {
+		s.groups[groupName] = &group{pending: make(map[string]*pendingEntry)}
+	}
+}
+
+// readArgs selects the messages a consumer reads.
+type readArgs struct {
+	stream   string
+	group    string
+	consumer string
+	count    int
+
+	// claimAfter claims messages pending for longer than it for other consumers,
+	// like XAUTOCLAIM. Zero disables claiming.
+	claimAfter time.Duration
+}
+
+// read delivers up to args.count messages to the consumer and marks them pending.
+// When there is none, it returns a channel closed once messages are added to the stream.
+func (b *Broker) read(args readArgs) ([]*message, <-chan struct{}) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	s := b.stream(args.stream)
+	g, ok := s.groups[args.group]
+	if !ok {
+		// The group was deleted by Reset
+		g = &group{pending: make(map[string]*pendingEntry)}
+		s.groups[args.group] = g
+	}
+
+	now := time.Now()
+	var deliveries []*message
+
+	if args.claimAfter > 0 {
+		entries := make([]*pendingEntry, 0, len(g.pending))
+		for _, entry := range g.pending {
+			if now.Sub(entry.delivered) >= args.claimAfter {
+				entries = append(entries, entry)
+			}
+		}
+		slices.SortFunc(entries, func(a, b *pendingEntry) int { return a.index - b.index })
+
+		for _, entry := range entries {
+			if len(deliveries) == args.count {
+				break
+			}
+
+			entry.consumer = args.consumer
+			entry.delivered = now
+			entry.deliveries++
+			deliveries = append(deliveries, entry.msg)
+		}
+	}
+
+	for len(deliveries) < args.count && g.next < len(s.messages) {
+		msg := s.messages[g.next]
+		g.pending[msg.id] = &pendingEntry{
+			msg:        msg,
+			index:      g.next,
+			consumer:   args.consumer,
+			delivered:  now,
+			deliveries: 1,
+		}
+		g.next++
+		deliveries = append(deliveries, msg)
+	}
+
+	if len(deliveries) == 0 {
+		return nil, s.added
+	}
+
+	return deliveries, nil
+}
+
+// ack acknowledges the message id of group in stream.
+func (b *Broker) ack(name, groupName, id string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if s, ok := b.streams[name]; ok {
+		if g, ok := s.groups[groupName]; ok {
+			delete(g.pending, id)
+		}
+	}
+}
+
+// record is an event in stored form, as a subscriber of another process would read it.
+type record struct {
+	id        string
+	eventType string
+	version   string
+	timestamp time.Time
+	payload   string
+	headers   eventbus.Headers
+}
+
+// event returns a copy of r as an event.
+func (r record) event() *storedEvent {
+	return &storedEvent{
+		id:            r.id,
+		eventType:     r.eventType,
+		version:       r.version,
+		timestamp:     r.timestamp,
+		data:          r.payload,
+		headers:       r.headers.Clone(),
+		storedData:    r.payload,
+		storedHeaders: r.headers.Clone(),
+		storedVersion: r.version,
+	}
+}
+
+// storedEvent is an event read from a stream.
+type storedEvent struct {
+	id        string
+	eventType string
+	version   string
+	timestamp time.Time
+	data      string
+	headers   eventbus.Headers
+
+	// storedData, storedHeaders and storedVersion are the payload, headers and version
+	// as stored in the stream, before upcasting.
+	storedData    string
+	storedHeaders eventbus.Headers
+	storedVersion string
+}
+
+func (e *storedEvent) EventType() string    { return e.eventType }
+func (e *storedEvent) EventID() string      { return e.id }
+func (e *storedEvent) EventTime() time.Time { return e.timestamp }
+func (e *storedEvent) Data() string         { return e.data }
+func (e *storedEvent) Validate() error      { return nil }
+
+// EventVersion returns the schema version of the event, after upcasting.
+func (e *storedEvent) EventVersion() string { return e.version }
+
+// Headers returns the headers the event was published with.
+func (e *storedEvent) Headers() eventbus.Headers { return e.headers }
+
+// StoredData returns the payload as stored in the stream, before upcasting.
+func (e *storedEvent) StoredData() string { return e.storedData }
+
+// StoredHeaders returns the headers as stored in the stream.
+func (e *storedEvent) StoredHeaders() eventbus.Headers { return e.storedHeaders }
+
+// StoredVersion returns the version the event was stored with, before upcasting.
+func (e *storedEvent) StoredVersion() string { return e.storedVersion }
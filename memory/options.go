@@ -0,0 +1,101 @@
+package memory
+
+import (
+	"context"
+	"log/slog"
+	"math"
+	"time"
+
+	eventbus "github.com/tclavelloux/promy-event-bus/eventbus"
+)
+
+// Option configures a Subscriber.
+type Option func(*options)
+
+type options struct {
+	logger     *slog.Logger
+	upcasters  *eventbus.Upcasters
+	backoff    func(attempt int) time.Duration
+	claimAfter time.Duration
+}
+
+func newOptions(opts []Option) options {
+	o := options{
+		logger:    slog.New(discardHandler{}),
+		upcasters: eventbus.DefaultUpcasters(),
+		backoff:   calculateBackoff,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	return o
+}
+
+// WithLogger sets the logger for retries, DLQ routing and lifecycle events.
+// Default: a logger that discards everything.
+func WithLogger(logger *slog.Logger) Option {
+	return func(o *options) {
+		if logger != nil {
+			o.logger = logger
+		}
+	}
+}
+
+// WithUpcasters sets the upcasters subscribers apply before calling the handler (see
+// redis.WithUpcasters).
+// Default: eventbus.DefaultUpcasters, filled by eventbus.RegisterUpcaster.
+func WithUpcasters(upcasters *eventbus.Upcasters) Option {
+	return func(o *options) {
+		o.upcasters = upcasters
+	}
+}
+
+// WithRetryBackoff sets how long the subscriber waits before retrying an event that
+// failed on the given attempt, e.g. func(int) time.Duration { return 0 } to speed up tests.
+// Default: the backoff of the redis package (none after the first attempt, then 100ms).
+func WithRetryBackoff(backoff func(attempt int) time.Duration) Option {
+	return func(o *options) {
+		if backoff != nil {
+			o.backoff = backoff
+		}
+	}
+}
+
+// WithClaimAfter makes subscribers take over messages left pending in their group for
+// longer than idle, like XAUTOCLAIM, e.g. to test the recovery of a consumer that
+// stopped while processing. The redis subscriber doesn't claim messages of unpartitioned
+// streams itself.
+// Default: disabled; pending messages are never delivered again, as with the redis subscriber.
+func WithClaimAfter(idle time.Duration) Option {
+	return func(o *options) {
+		if idle > 0 {
+			o.claimAfter = idle
+		}
+	}
+}
+
+// calculateBackoff calculates exponential backoff like the redis package.
+func calculateBackoff(attempt int) time.Duration {
+	if attempt <= 1 {
+		return 0
+	}
+
+	backoff := time.Duration(100*math.Pow(5, float64(attempt-2))) * time.Millisecond
+
+	// Cap at 10 seconds
+	if backoff > 10*time.Second {
+		return 10 * time.Second
+	}
+
+	return backoff
+}
+
+// discardHandler drops every record. It is the default handler so the package stays
+// silent unless a logger is configured.
+type discardHandler struct{}
+
+func (discardHandler) Enabled(context.Context, slog.Level) bool  { return false }
+func (discardHandler) Handle(context.Context, slog.Record) error { return nil }
+func (h discardHandler) WithAttrs([]slog.Attr) slog.Handler      { return h }
+func (h discardHandler) WithGroup(string) slog.Handler           { return h }
@@ -0,0 +1,126 @@
+package memory
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync/atomic"
+
+	eventbus "github.com/tclavelloux/promy-event-bus/eventbus"
+)
+
+// Publisher implements eventbus.EventPublisher and eventbus.MultiStreamPublisher over a Broker.
+type Publisher struct {
+	broker *Broker
+	closed atomic.Bool
+}
+
+// NewPublisher creates a publisher appending to the streams of broker.
+func NewPublisher(broker *Broker) *Publisher {
+	return &Publisher{broker: broker}
+}
+
+// Publish validates event and appends it to stream.
+func (p *Publisher) Publish(ctx context.Context, stream string, event eventbus.Event) error {
+	if p.closed.Load() {
+		return fmt.Errorf("%w: %w", eventbus.ErrPublishFailed, eventbus.ErrConnectionClosed)
+	}
+
+	rec, err := encodeRecord(ctx, event)
+	if err != nil {
+		return err
+	}
+
+	p.broker.append([]entry{{stream: stream, record: rec, attempt: 1}})
+
+	return nil
+}
+
+// PublishBatch appends events to stream atomically.
+// If any event is invalid, nothing is published and a *eventbus.BatchError reports the
+// result of each event.
+func (p *Publisher) PublishBatch(ctx context.Context, stream string, events []eventbus.Event) error {
+	batch := make([]eventbus.StreamEvent, len(events))
+	for i, event := range events {
+		batch[i] = eventbus.StreamEvent{Stream: stream, Event: event}
+	}
+
+	return p.PublishMulti(ctx, batch)
+}
+
+// PublishMulti appends events to their streams atomically.
+// If any event is invalid, nothing is published and a *eventbus.BatchError reports the
+// result of each event.
+func (p *Publisher) PublishMulti(ctx context.Context, events []eventbus.StreamEvent) error {
+	if p.closed.Load() {
+		return fmt.Errorf("%w: %w", eventbus.ErrPublishFailed, eventbus.ErrConnectionClosed)
+	}
+	if len(events) == 0 {
+		return nil
+	}
+
+	batchErr := eventbus.NewBatchError(len(events))
+	failed := false
+
+	entries := make([]entry, len(events))
+	for i, se := range events {
+		rec, err := encodeRecord(ctx, se.Event)
+		if err != nil {
+			batchErr.Errs[i] = err
+			failed = true
+
+			continue
+		}
+
+		entries[i] = entry{stream: se.Stream, record: rec, attempt: 1}
+	}
+
+	if failed {
+		return batchErr
+	}
+
+	p.broker.append(entries)
+
+	return nil
+}
+
+// encodeRecord validates event and serializes it as the redis package does, with the
+// headers resolved from ctx and the event (see eventbus.OutgoingHeaders).
+func encodeRecord(ctx context.Context, event eventbus.Event) (record, error) {
+	if err := eventbus.ValidateStruct(event); err != nil {
+		return record{}, err
+	}
+	if err := event.Validate(); err != nil {
+		return record{}, err
+	}
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return record{}, fmt.Errorf("failed to marshal payload: %w", err)
+	}
+
+	return record{
+		id:        event.EventID(),
+		eventType: event.EventType(),
+		version:   eventbus.EventVersion(event),
+		timestamp: event.EventTime(),
+		payload:   string(payload),
+		headers:   eventbus.OutgoingHeaders(ctx, event),
+	}, nil
+}
+
+// Close makes further publishing fail with eventbus.ErrConnectionClosed.
+func (p *Publisher) Close() error {
+	p.closed.Store(true)
+
+	return nil
+}
+
+// Health reports eventbus.ErrConnectionClosed once the publisher is closed.
+func (p *Publisher) Health(context.Context) error {
+	if p.closed.Load() {
+		return eventbus.ErrConnectionClosed
+	}
+
+	return nil
+}
@@ -0,0 +1,77 @@
+//nolint:all // Test file
+package memory_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	eventbus "github.com/tclavelloux/promy-event-bus/eventbus"
+	"github.com/tclavelloux/promy-event-bus/memory"
+	"github.com/tclavelloux/promy-event-bus/testutil"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPublisher(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("appends events with their headers", func(t *testing.T) {
+		broker := memory.NewBroker()
+		publisher := memory.NewPublisher(broker)
+
+		event := testutil.NewTestEvent("user.registered", map[string]any{"user_id": "u-1"})
+		require.NoError(t, publisher.Publish(eventbus.WithCorrelationID(ctx, "flow-1"), "events:users", event))
+
+		events := broker.Events("events:users")
+		require.Len(t, events, 1)
+		assert.Equal(t, event.EventID(), events[0].EventID())
+		assert.Equal(t, "user.registered", events[0].EventType())
+		payload, err := json.Marshal(event)
+		require.NoError(t, err)
+		assert.JSONEq(t, string(payload), events[0].Data())
+		assert.Equal(t, "flow-1", eventbus.EventHeaders(events[0]).CorrelationID())
+	})
+
+	t.Run("rejects invalid events", func(t *testing.T) {
+		broker := memory.NewBroker()
+		publisher := memory.NewPublisher(broker)
+
+		err := publisher.Publish(ctx, "events:users", &eventbus.DLQEntry{})
+		assert.ErrorIs(t, err, eventbus.ErrInvalidEvent)
+		assert.Empty(t, broker.Events("events:users"))
+	})
+
+	t.Run("publishes batches atomically", func(t *testing.T) {
+		broker := memory.NewBroker()
+		publisher := memory.NewPublisher(broker)
+
+		valid := testutil.NewTestEvent("user.registered", nil)
+		err := publisher.PublishBatch(ctx, "events:users", []eventbus.Event{valid, &eventbus.DLQEntry{}})
+
+		var batchErr *eventbus.BatchError
+		require.ErrorAs(t, err, &batchErr)
+		assert.NoError(t, batchErr.Errs[0])
+		assert.ErrorIs(t, batchErr.Errs[1], eventbus.ErrInvalidEvent)
+		assert.Empty(t, broker.Events("events:users"))
+
+		err = publisher.PublishMulti(ctx, []eventbus.StreamEvent{
+			{Stream: "events:users", Event: valid},
+			{Stream: "events:subscriptions", Event: testutil.NewTestEvent("subscription.started", nil)},
+		})
+		require.NoError(t, err)
+		assert.Len(t, broker.Events("events:users"), 1)
+		assert.Len(t, broker.Events("events:subscriptions"), 1)
+	})
+
+	t.Run("fails once closed", func(t *testing.T) {
+		publisher := memory.NewPublisher(memory.NewBroker())
+		require.NoError(t, publisher.Health(ctx))
+		require.NoError(t, publisher.Close())
+
+		assert.ErrorIs(t, publisher.Health(ctx), eventbus.ErrConnectionClosed)
+		assert.ErrorIs(t, publisher.Publish(ctx, "events:users", testutil.NewTestEvent("user.registered", nil)),
+			eventbus.ErrConnectionClosed)
+	})
+}
@@ -0,0 +1,273 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	eventbus "github.com/tclavelloux/promy-event-bus/eventbus"
+	"github.com/tclavelloux/promy-event-bus/streams"
+
+	"golang.org/x/sync/semaphore"
+)
+
+// maxAttempts is the number of times an event is handled before it is dead-lettered,
+// as in the redis package.
+const maxAttempts = 3
+
+// errClosed is returned by subscriptions of a closed subscriber.
+var errClosed = fmt.Errorf("%w: %w", eventbus.ErrSubscriptionFailed, eventbus.ErrConnectionClosed)
+
+// Subscriber implements eventbus.EventSubscriber over a Broker.
+type Subscriber struct {
+	broker *Broker
+
+	logger     *slog.Logger
+	upcasters  *eventbus.Upcasters
+	backoff    func(attempt int) time.Duration
+	claimAfter time.Duration
+
+	closeOnce sync.Once
+	closed    chan struct{}
+}
+
+// NewSubscriber creates a subscriber consuming the streams of broker.
+func NewSubscriber(broker *Broker, opts ...Option) *Subscriber {
+	o := newOptions(opts)
+
+	return &Subscriber{
+		broker:     broker,
+		logger:     o.logger,
+		upcasters:  o.upcasters,
+		backoff:    o.backoff,
+		claimAfter: o.claimAfter,
+		closed:     make(chan struct{}),
+	}
+}
+
+// Subscribe consumes the stream of subConfig with the semantics of the redis package,
+// until ctx is cancelled or the subscriber is closed. The consumer group is created at
+// the start of the stream if it doesn't exist. Like the redis subscriber, it only reads
+// messages not yet delivered to the group: messages left pending, even for ConsumerID,
+// are not delivered again unless WithClaimAfter is set.
+//
+// A failed event is retried as a new message of the stream, up to 3 attempts, then
+// routed to the DLQ with DLQPublisher, if set, and acknowledged. Events failing with
+// eventbus.ErrUnsupportedVersion are not retried. When a handler fails because ctx was
+// cancelled, its message stays pending, like one of a stopped Redis consumer.
+func (s *Subscriber) Subscribe(ctx context.Context, subConfig eventbus.SubscriptionConfig) error {
+	// Set defaults
+	if subConfig.MaxConcurrency <= 0 {
+		subConfig.MaxConcurrency = 1
+	}
+	if subConfig.BatchSize <= 0 {
+		subConfig.BatchSize = 1
+	}
+	if subConfig.BlockDuration <= 0 {
+		subConfig.BlockDuration = 1 * time.Second
+	}
+
+	log := s.logger.With(
+		slog.String("stream", subConfig.Stream),
+		slog.String("group", subConfig.ConsumerGroup),
+		slog.String("consumer", subConfig.ConsumerID),
+	)
+
+	s.broker.createGroup(subConfig.Stream, subConfig.ConsumerGroup)
+	log.InfoContext(ctx, "subscription started")
+
+	// Semaphore for concurrency control
+	sem := semaphore.NewWeighted(int64(subConfig.MaxConcurrency))
+
+	for {
+		select {
+		case <-ctx.Done():
+			log.InfoContext(ctx, "subscription stopped")
+
+			return ctx.Err()
+		case <-s.closed:
+			log.InfoContext(ctx, "subscription stopped")
+
+			return errClosed
+		default:
+		}
+
+		deliveries, added := s.broker.read(readArgs{
+			stream:     subConfig.Stream,
+			group:      subConfig.ConsumerGroup,
+			consumer:   subConfig.ConsumerID,
+			count:      subConfig.BatchSize,
+			claimAfter: s.claimAfter,
+		})
+
+		if len(deliveries) == 0 {
+			if err := s.wait(ctx, added, subConfig.BlockDuration); err != nil {
+				log.InfoContext(ctx, "subscription stopped")
+
+				return err
+			}
+
+			continue
+		}
+
+		// Process messages concurrently
+		var wg sync.WaitGroup
+		for _, msg := range deliveries {
+			if err := sem.Acquire(ctx, 1); err != nil {
+				wg.Wait()
+
+				return err
+			}
+
+			wg.Add(1)
+			go func(msg *message) {
+				defer wg.Done()
+				defer sem.Release(1)
+
+				s.processMessage(ctx, subConfig, msg, log)
+			}(msg)
+		}
+
+		wg.Wait()
+	}
+}
+
+// wait blocks until messages are added, timeout elapses, ctx is cancelled or the
+// subscriber is closed.
+func (s *Subscriber) wait(ctx context.Context, added <-chan struct{}, timeout time.Duration) error {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case <-added:
+		return nil
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-s.closed:
+		return errClosed
+	}
+}
+
+// processMessage handles a delivered message with retry logic.
+func (s *Subscriber) processMessage(
+	ctx context.Context, config eventbus.SubscriptionConfig, msg *message, log *slog.Logger,
+) {
+	event := msg.record.event()
+	log = log.With(
+		slog.String("message_id", msg.id),
+		slog.String("event_id", event.EventID()),
+		slog.String("event_type", event.EventType()),
+		slog.Int("attempt", msg.attempt),
+	)
+
+	// Create timeout context for processing
+	processCtx, cancel := context.WithTimeout(ctx, config.BlockDuration)
+	defer cancel()
+
+	// Events published by the handler inherit the correlation ID and are caused by this event
+	processCtx = eventbus.ContextWithCause(processCtx, event)
+
+	handlerErr := s.upcastEvent(event)
+	if handlerErr == nil {
+		handlerErr = config.Handler(processCtx, event)
+	}
+
+	if handlerErr == nil {
+		s.broker.ack(config.Stream, config.ConsumerGroup, msg.id)
+
+		return
+	}
+
+	if ctx.Err() != nil {
+		log.WarnContext(ctx, "event handler failed while stopping, leaving message pending",
+			slog.Any("error", handlerErr))
+
+		return
+	}
+
+	switch {
+	case msg.attempt < maxAttempts && !errors.Is(handlerErr, eventbus.ErrUnsupportedVersion):
+		backoff := s.backoff(msg.attempt)
+		log.WarnContext(ctx, "event handler failed, retrying",
+			slog.Any("error", handlerErr), slog.Duration("backoff", backoff))
+
+		if !sleep(ctx, backoff) {
+			// Stopped during the backoff: the message stays pending
+			return
+		}
+
+		s.broker.append([]entry{{stream: config.Stream, record: msg.record, attempt: msg.attempt + 1}})
+	case config.DLQPublisher != nil:
+		dlqEntry := eventbus.NewDLQEntry(config.Stream, event, handlerErr, config.DLQService, msg.attempt)
+		if err := config.DLQPublisher.Publish(eventbus.ContextWithCause(ctx, event), streams.StreamDLQ, dlqEntry); err != nil {
+			log.ErrorContext(ctx, "failed to route event to DLQ, dropping it",
+				slog.Any("error", err), slog.String("handler_error", handlerErr.Error()))
+		} else {
+			log.WarnContext(ctx, "event handler failed on last attempt, routed to DLQ",
+				slog.Any("error", handlerErr), slog.String("dlq_entry_id", dlqEntry.EventID()))
+		}
+	default:
+		log.ErrorContext(ctx, "event handler failed on last attempt, dropping event (no DLQ configured)",
+			slog.Any("error", handlerErr))
+	}
+
+	s.broker.ack(config.Stream, config.ConsumerGroup, msg.id)
+}
+
+// upcastEvent upgrades the payload of event in place to the latest version known to the
+// subscriber's upcasters. The stored form is kept for DLQ entries.
+func (s *Subscriber) upcastEvent(event *storedEvent) error {
+	if s.upcasters == nil {
+		return nil
+	}
+
+	payload, version, err := s.upcasters.Upcast(event.eventType, eventbus.EventVersion(event), []byte(event.data))
+	if err != nil {
+		return err
+	}
+
+	event.data = string(payload)
+	event.version = version
+
+	return nil
+}
+
+// sleep waits for d and reports whether ctx is still active.
+func sleep(ctx context.Context, d time.Duration) bool {
+	if d <= 0 {
+		return ctx.Err() == nil
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// Close stops the running subscriptions, which return eventbus.ErrConnectionClosed.
+// Messages they are processing are still acknowledged.
+func (s *Subscriber) Close() error {
+	s.closeOnce.Do(func() { close(s.closed) })
+
+	return nil
+}
+
+// Health reports eventbus.ErrConnectionClosed once the subscriber is closed.
+func (s *Subscriber) Health(context.Context) error {
+	select {
+	case <-s.closed:
+		return eventbus.ErrConnectionClosed
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,385 @@
+//nolint:all // Test file
+package memory_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	eventbus "github.com/tclavelloux/promy-event-bus/eventbus"
+	"github.com/tclavelloux/promy-event-bus/memory"
+	"github.com/tclavelloux/promy-event-bus/streams"
+	"github.com/tclavelloux/promy-event-bus/testutil"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var noBackoff = memory.WithRetryBackoff(func(int) time.Duration { return 0 })
+
+// subscribe runs subConfig on subscriber until the test ends, and returns the result of Subscribe.
+func subscribe(t *testing.T, subscriber *memory.Subscriber, subConfig eventbus.SubscriptionConfig) <-chan error {
+	t.Helper()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	if subConfig.BlockDuration == 0 {
+		subConfig.BlockDuration = 100 * time.Millisecond
+	}
+
+	errc := make(chan error, 1)
+	go func() { errc <- subscriber.Subscribe(ctx, subConfig) }()
+
+	return errc
+}
+
+func TestSubscriber_ConsumerGroups(t *testing.T) {
+	broker := memory.NewBroker()
+	publisher := memory.NewPublisher(broker)
+	subscriber := memory.NewSubscriber(broker)
+
+	var mu sync.Mutex
+	received := make(map[string][]string) // consumer -> event IDs
+
+	handler := func(consumer string) eventbus.EventHandler {
+		return func(ctx context.Context, event eventbus.Event) error {
+			mu.Lock()
+			defer mu.Unlock()
+			received[consumer] = append(received[consumer], event.EventID())
+
+			return nil
+		}
+	}
+
+	// Events published before the group exists are consumed too
+	for range 5 {
+		require.NoError(t, publisher.Publish(context.Background(), "events:users", testutil.NewTestEvent("user.registered", nil)))
+	}
+
+	subscribe(t, subscriber, eventbus.SubscriptionConfig{
+		Stream: "events:users", ConsumerGroup: "crm", ConsumerID: "crm-1", Handler: handler("crm-1"),
+	})
+	subscribe(t, subscriber, eventbus.SubscriptionConfig{
+		Stream: "events:users", ConsumerGroup: "crm", ConsumerID: "crm-2", Handler: handler("crm-2"),
+	})
+	subscribe(t, subscriber, eventbus.SubscriptionConfig{
+		Stream: "events:users", ConsumerGroup: "billing", ConsumerID: "billing-1", Handler: handler("billing-1"),
+		BatchSize: 10, MaxConcurrency: 4,
+	})
+
+	for range 5 {
+		require.NoError(t, publisher.Publish(context.Background(), "events:users", testutil.NewTestEvent("user.registered", nil)))
+	}
+
+	assert.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+
+		return len(received["crm-1"])+len(received["crm-2"]) == 10 && len(received["billing-1"]) == 10
+	}, 2*time.Second, 10*time.Millisecond)
+
+	// Consumers of a group share its messages
+	mu.Lock()
+	shared := append(append([]string{}, received["crm-1"]...), received["crm-2"]...)
+	mu.Unlock()
+	assert.ElementsMatch(t, received["billing-1"], shared)
+
+	assert.Eventually(t, func() bool {
+		return len(broker.Pending("events:users", "crm")) == 0 && len(broker.Pending("events:users", "billing")) == 0
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestSubscriber_HandlerContext(t *testing.T) {
+	broker := memory.NewBroker()
+	publisher := memory.NewPublisher(broker)
+	subscriber := memory.NewSubscriber(broker)
+
+	received := make(chan eventbus.Event, 1)
+	subscribe(t, subscriber, eventbus.SubscriptionConfig{
+		Stream: "events:users", ConsumerGroup: "crm", ConsumerID: "crm-1",
+		Handler: func(ctx context.Context, event eventbus.Event) error {
+			// Events published by handlers are caused by the handled event
+			received <- event
+
+			return publisher.Publish(ctx, "events:audit", testutil.NewTestEvent("audit.logged", nil))
+		},
+	})
+
+	event := testutil.NewTestEvent("user.registered", map[string]any{"user_id": "u-1"})
+	require.NoError(t, publisher.Publish(eventbus.WithCorrelationID(context.Background(), "flow-1"), "events:users", event))
+
+	select {
+	case got := <-received:
+		var payload struct {
+			UserID string `json:"user_id"`
+		}
+		require.NoError(t, json.Unmarshal([]byte(got.Data()), &payload))
+		assert.Equal(t, "u-1", payload.UserID)
+		assert.Equal(t, "1.0", eventbus.EventVersion(got))
+	case <-time.After(2 * time.Second):
+		t.Fatal("event not received")
+	}
+
+	assert.Eventually(t, func() bool { return len(broker.Events("events:audit")) == 1 }, time.Second, 10*time.Millisecond)
+
+	headers := eventbus.EventHeaders(broker.Events("events:audit")[0])
+	assert.Equal(t, "flow-1", headers.CorrelationID())
+	assert.Equal(t, event.EventID(), headers.CausationID())
+}
+
+func TestSubscriber_Retry(t *testing.T) {
+	t.Run("retries failed events", func(t *testing.T) {
+		broker := memory.NewBroker()
+		publisher := memory.NewPublisher(broker)
+		subscriber := memory.NewSubscriber(broker, noBackoff)
+
+		var mu sync.Mutex
+		attempts := 0
+		subscribe(t, subscriber, eventbus.SubscriptionConfig{
+			Stream: "events:users", ConsumerGroup: "crm", ConsumerID: "crm-1",
+			Handler: func(ctx context.Context, event eventbus.Event) error {
+				mu.Lock()
+				defer mu.Unlock()
+
+				attempts++
+				if attempts == 1 {
+					return errors.New("transient failure")
+				}
+
+				return nil
+			},
+		})
+
+		require.NoError(t, publisher.Publish(context.Background(), "events:users", testutil.NewTestEvent("user.registered", nil)))
+
+		assert.Eventually(t, func() bool {
+			mu.Lock()
+			defer mu.Unlock()
+
+			return attempts == 2
+		}, 2*time.Second, 10*time.Millisecond)
+
+		// The retry is a new message of the stream
+		events := broker.Events("events:users")
+		require.Len(t, events, 2)
+		assert.Equal(t, events[0].EventID(), events[1].EventID())
+		assert.Eventually(t, func() bool { return len(broker.Pending("events:users", "crm")) == 0 }, time.Second, 10*time.Millisecond)
+		assert.Empty(t, broker.Events(streams.StreamDLQ))
+	})
+
+	t.Run("routes exhausted events to the DLQ", func(t *testing.T) {
+		broker := memory.NewBroker()
+		publisher := memory.NewPublisher(broker)
+		subscriber := memory.NewSubscriber(broker, noBackoff)
+
+		subscribe(t, subscriber, eventbus.SubscriptionConfig{
+			Stream: "events:users", ConsumerGroup: "crm", ConsumerID: "crm-1",
+			Handler: func(ctx context.Context, event eventbus.Event) error {
+				return errors.New("permanent failure")
+			},
+			DLQPublisher: publisher,
+			DLQService:   "promy-crm",
+		})
+
+		event := testutil.NewTestEvent("user.registered", nil)
+		require.NoError(t, publisher.Publish(context.Background(), "events:users", event))
+
+		assert.Eventually(t, func() bool { return len(broker.Events(streams.StreamDLQ)) == 1 }, 2*time.Second, 10*time.Millisecond)
+
+		var entry eventbus.DLQEntry
+		require.NoError(t, json.Unmarshal([]byte(broker.Events(streams.StreamDLQ)[0].Data()), &entry))
+		assert.Equal(t, "events:users", entry.OriginalStream)
+		assert.Equal(t, event.EventID(), entry.OriginalEventID)
+		assert.Equal(t, "permanent failure", entry.FailureReason)
+		assert.Equal(t, "promy-crm", entry.FailedService)
+		assert.Equal(t, 3, entry.AttemptsExhausted)
+		assert.Len(t, broker.Events("events:users"), 3)
+	})
+
+	t.Run("dead-letters unsupported versions without retrying", func(t *testing.T) {
+		broker := memory.NewBroker()
+		publisher := memory.NewPublisher(broker)
+		subscriber := memory.NewSubscriber(broker, noBackoff)
+
+		subscribe(t, subscriber, eventbus.SubscriptionConfig{
+			Stream: "events:users", ConsumerGroup: "crm", ConsumerID: "crm-1",
+			Handler: eventbus.RouteByVersion(map[string]eventbus.EventHandler{
+				"2.0": func(ctx context.Context, event eventbus.Event) error { return nil },
+			}),
+			DLQPublisher: publisher,
+			DLQService:   "promy-crm",
+		})
+
+		require.NoError(t, publisher.Publish(context.Background(), "events:users", testutil.NewTestEvent("user.registered", nil)))
+
+		assert.Eventually(t, func() bool { return len(broker.Events(streams.StreamDLQ)) == 1 }, 2*time.Second, 10*time.Millisecond)
+
+		var entry eventbus.DLQEntry
+		require.NoError(t, json.Unmarshal([]byte(broker.Events(streams.StreamDLQ)[0].Data()), &entry))
+		assert.Equal(t, 1, entry.AttemptsExhausted)
+		assert.Len(t, broker.Events("events:users"), 1)
+	})
+}
+
+func TestSubscriber_Redelivery(t *testing.T) {
+	broker := memory.NewBroker()
+	publisher := memory.NewPublisher(broker)
+
+	// The first consumer stops while handling the event, leaving it pending
+	started := make(chan struct{})
+	ctx, cancel := context.WithCancel(context.Background())
+	errc := make(chan error, 1)
+	go func() {
+		errc <- memory.NewSubscriber(broker).Subscribe(ctx, eventbus.SubscriptionConfig{
+			Stream: "events:users", ConsumerGroup: "crm", ConsumerID: "crm-1",
+			BlockDuration: time.Second,
+			Handler: func(ctx context.Context, event eventbus.Event) error {
+				close(started)
+				<-ctx.Done()
+
+				return ctx.Err()
+			},
+		})
+	}()
+
+	event := testutil.NewTestEvent("user.registered", nil)
+	require.NoError(t, publisher.Publish(context.Background(), "events:users", event))
+
+	<-started
+	cancel()
+	assert.ErrorIs(t, <-errc, context.Canceled)
+
+	pending := broker.Pending("events:users", "crm")
+	require.Len(t, pending, 1)
+	assert.Equal(t, "crm-1", pending[0].Consumer)
+	assert.Equal(t, 1, pending[0].Deliveries)
+	assert.Equal(t, event.EventID(), pending[0].Event.EventID())
+
+	t.Run("another consumer claims idle messages", func(t *testing.T) {
+		received := make(chan eventbus.Event, 1)
+		subscriber := memory.NewSubscriber(broker, memory.WithClaimAfter(50*time.Millisecond))
+		subCtx, subCancel := context.WithCancel(context.Background())
+		defer subCancel()
+
+		// Fail while stopping, so that the message stays pending
+		go subscriber.Subscribe(subCtx, eventbus.SubscriptionConfig{
+			Stream: "events:users", ConsumerGroup: "crm", ConsumerID: "crm-2",
+			BlockDuration: 50 * time.Millisecond,
+			Handler: func(ctx context.Context, event eventbus.Event) error {
+				received <- event
+				subCancel()
+
+				return ctx.Err()
+			},
+		})
+
+		select {
+		case got := <-received:
+			assert.Equal(t, event.EventID(), got.EventID())
+		case <-time.After(2 * time.Second):
+			t.Fatal("pending message not claimed")
+		}
+
+		assert.Eventually(t, func() bool {
+			pending := broker.Pending("events:users", "crm")
+			return len(pending) == 1 && pending[0].Consumer == "crm-2" && pending[0].Deliveries == 2
+		}, time.Second, 10*time.Millisecond)
+	})
+
+	t.Run("a resubscribing consumer only reads new messages", func(t *testing.T) {
+		received := make(chan eventbus.Event, 2)
+		subscribe(t, memory.NewSubscriber(broker), eventbus.SubscriptionConfig{
+			Stream: "events:users", ConsumerGroup: "crm", ConsumerID: "crm-2",
+			Handler: func(ctx context.Context, event eventbus.Event) error {
+				received <- event
+				return nil
+			},
+		})
+
+		next := testutil.NewTestEvent("user.registered", nil)
+		require.NoError(t, publisher.Publish(context.Background(), "events:users", next))
+
+		select {
+		case got := <-received:
+			assert.Equal(t, next.EventID(), got.EventID(), "pending messages are not redelivered")
+		case <-time.After(2 * time.Second):
+			t.Fatal("new message not delivered")
+		}
+
+		assert.Eventually(t, func() bool {
+			pending := broker.Pending("events:users", "crm")
+			return len(pending) == 1 && pending[0].Event.EventID() == event.EventID()
+		}, time.Second, 10*time.Millisecond)
+	})
+}
+
+func TestSubscriber_Close(t *testing.T) {
+	subscriber := memory.NewSubscriber(memory.NewBroker())
+	errc := subscribe(t, subscriber, eventbus.SubscriptionConfig{
+		Stream: "events:users", ConsumerGroup: "crm", ConsumerID: "crm-1",
+		Handler: func(ctx context.Context, event eventbus.Event) error { return nil },
+	})
+
+	require.NoError(t, subscriber.Health(context.Background()))
+	require.NoError(t, subscriber.Close())
+
+	select {
+	case err := <-errc:
+		assert.ErrorIs(t, err, eventbus.ErrConnectionClosed)
+	case <-time.After(2 * time.Second):
+		t.Fatal("subscription not stopped")
+	}
+	assert.ErrorIs(t, subscriber.Health(context.Background()), eventbus.ErrConnectionClosed)
+}
+
+func TestSubscriber_Concurrency(t *testing.T) {
+	broker := memory.NewBroker()
+	publisher := memory.NewPublisher(broker)
+	subscriber := memory.NewSubscriber(broker)
+
+	var mu sync.Mutex
+	seen := make(map[string]int)
+	for i := range 4 {
+		subscribe(t, subscriber, eventbus.SubscriptionConfig{
+			Stream: "events:users", ConsumerGroup: "crm", ConsumerID: fmt.Sprintf("crm-%d", i),
+			BatchSize: 5, MaxConcurrency: 3,
+			Handler: func(ctx context.Context, event eventbus.Event) error {
+				mu.Lock()
+				defer mu.Unlock()
+				seen[event.EventID()]++
+
+				return nil
+			},
+		})
+	}
+
+	var wg sync.WaitGroup
+	for range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 50 {
+				assert.NoError(t, publisher.Publish(context.Background(), "events:users", testutil.NewTestEvent("user.registered", nil)))
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+
+		return len(seen) == 200
+	}, 5*time.Second, 10*time.Millisecond)
+
+	mu.Lock()
+	defer mu.Unlock()
+	for id, count := range seen {
+		assert.Equal(t, 1, count, "event %s delivered more than once", id)
+	}
+}
@@ -0,0 +1,60 @@
+// Package outbox implements the transactional outbox pattern.
+//
+// Services write events into an outbox table inside the database transaction that
+// changes their state (SQLStore.Add). A Relay then publishes pending rows in order
+// through an eventbus.EventPublisher and marks them sent. An event is therefore
+// published if and only if its transaction commits.
+//
+// Delivery is at-least-once: a relay that crashes between publishing and marking a
+// row sent publishes it again. Use redis.WithIdempotentPublish on the relay's
+// publisher to drop such duplicates.
+package outbox
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	eventbus "github.com/tclavelloux/promy-event-bus/eventbus"
+)
+
+// ErrUnknownDialect is returned when an SQLStore is configured with an unsupported dialect.
+var ErrUnknownDialect = errors.New("unknown SQL dialect")
+
+// Message is an event waiting in the outbox.
+type Message struct {
+	// ID orders messages: the relay publishes them in increasing ID order.
+	ID int64
+
+	// Stream is the stream the event is published to.
+	Stream string
+
+	// Event is the serialized event, with the headers resolved when it was written.
+	Event *eventbus.Envelope
+
+	// Attempts is the number of failed publish attempts so far.
+	Attempts int
+
+	// NextAttemptAt is when the relay may try again after a failure.
+	// It is zero for messages that never failed.
+	NextAttemptAt time.Time
+}
+
+// Store persists outbox messages for a Relay.
+// Implementations must be safe for concurrent use.
+type Store interface {
+	// AcquireLease makes owner the only relay allowed to publish for ttl.
+	// It returns true if owner holds the lease, either newly acquired or renewed,
+	// and false if another owner holds an unexpired lease.
+	AcquireLease(ctx context.Context, owner string, ttl time.Duration) (bool, error)
+
+	// Pending returns up to limit unsent messages in increasing ID order,
+	// including messages whose next attempt is not due yet.
+	Pending(ctx context.Context, limit int) ([]Message, error)
+
+	// MarkSent records that the messages with the given IDs were published.
+	MarkSent(ctx context.Context, ids ...int64) error
+
+	// MarkFailed records a failed publish attempt of message id and when to try again.
+	MarkFailed(ctx context.Context, id int64, cause error, retryAt time.Time) error
+}
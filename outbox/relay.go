@@ -0,0 +1,218 @@
+package outbox
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"time"
+
+	eventbus "github.com/tclavelloux/promy-event-bus/eventbus"
+
+	"github.com/google/uuid"
+)
+
+// Default Relay settings.
+const (
+	defaultBatchSize    = 100
+	defaultPollInterval = time.Second
+	defaultLeaseTTL     = 30 * time.Second
+	defaultMinBackoff   = time.Second
+	defaultMaxBackoff   = 5 * time.Minute
+)
+
+// RelayConfig configures a Relay.
+type RelayConfig struct {
+	// Owner identifies this relay instance in the lease.
+	// Default: "<hostname>-<random UUID>"
+	Owner string
+
+	// BatchSize is the maximum number of messages read per pass.
+	// Default: 100
+	BatchSize int
+
+	// PollInterval is the pause between passes when the outbox is drained,
+	// the lease is held by another relay or a message waits for its retry.
+	// Default: 1s
+	PollInterval time.Duration
+
+	// LeaseTTL is how long the lease stays valid without renewal. Only the lease
+	// owner publishes, so another relay takes over at most LeaseTTL after a crash.
+	// It must be longer than a pass. Default: 30s
+	LeaseTTL time.Duration
+
+	// MinBackoff is the delay before the first retry of a failed message.
+	// It doubles on each further failure, up to MaxBackoff.
+	// Default: 1s
+	MinBackoff time.Duration
+
+	// MaxBackoff caps the retry delay.
+	// Default: 5m
+	MaxBackoff time.Duration
+
+	// Logger receives relay failures. Default: discard.
+	Logger *slog.Logger
+}
+
+// Relay publishes outbox messages in ID order.
+//
+// Only the relay holding the store lease publishes, so several instances can run
+// for availability. Consecutive messages for the same stream are published with one
+// PublishBatch call. A failed message is retried with exponential backoff and blocks
+// the messages after it, which keeps the publish order.
+type Relay struct {
+	store     Store
+	publisher eventbus.EventPublisher
+	config    RelayConfig
+	logger    *slog.Logger
+}
+
+// NewRelay creates a Relay publishing messages of store through publisher.
+func NewRelay(store Store, publisher eventbus.EventPublisher, config RelayConfig) *Relay {
+	if config.Owner == "" {
+		hostname, _ := os.Hostname()
+		config.Owner = hostname + "-" + uuid.NewString()
+	}
+	if config.BatchSize <= 0 {
+		config.BatchSize = defaultBatchSize
+	}
+	if config.PollInterval <= 0 {
+		config.PollInterval = defaultPollInterval
+	}
+	if config.LeaseTTL <= 0 {
+		config.LeaseTTL = defaultLeaseTTL
+	}
+	if config.MinBackoff <= 0 {
+		config.MinBackoff = defaultMinBackoff
+	}
+	if config.MaxBackoff <= 0 {
+		config.MaxBackoff = defaultMaxBackoff
+	}
+
+	logger := config.Logger
+	if logger == nil {
+		logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+	}
+
+	return &Relay{
+		store:     store,
+		publisher: publisher,
+		config:    config,
+		logger:    logger.With(slog.String("relay", config.Owner)),
+	}
+}
+
+// Run relays messages until ctx is cancelled. Pass failures are logged and retried.
+func (r *Relay) Run(ctx context.Context) error {
+	for {
+		relayed, err := r.RelayOnce(ctx)
+		if err != nil && ctx.Err() == nil {
+			r.logger.ErrorContext(ctx, "outbox relay pass failed", slog.Any("error", err))
+		}
+
+		// Keep going without pause while full batches are being relayed.
+		wait := r.config.PollInterval
+		if err == nil && relayed == r.config.BatchSize {
+			wait = 0
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(wait):
+		}
+	}
+}
+
+// RelayOnce runs a single pass: it acquires the lease, then publishes pending
+// messages until the batch is done or a message fails or is not due yet.
+// It returns the number of messages published.
+func (r *Relay) RelayOnce(ctx context.Context) (int, error) {
+	leader, err := r.store.AcquireLease(ctx, r.config.Owner, r.config.LeaseTTL)
+	if err != nil || !leader {
+		return 0, err
+	}
+
+	messages, err := r.store.Pending(ctx, r.config.BatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	relayed := 0
+	for len(messages) > 0 {
+		run := sameStreamRun(messages, time.Now())
+		if len(run) == 0 {
+			// The head message waits for its retry.
+			break
+		}
+
+		if err := r.publish(ctx, run); err != nil {
+			return relayed, err
+		}
+
+		relayed += len(run)
+		messages = messages[len(run):]
+	}
+
+	return relayed, nil
+}
+
+// publish publishes run and marks it sent, or records the failure of its first message.
+func (r *Relay) publish(ctx context.Context, run []Message) error {
+	stream := run[0].Stream
+	events := make([]eventbus.Event, len(run))
+	ids := make([]int64, len(run))
+	for i, msg := range run {
+		events[i] = msg.Event
+		ids[i] = msg.ID
+	}
+
+	if err := r.publisher.PublishBatch(ctx, stream, events); err != nil {
+		head := run[0]
+		retryAt := time.Now().Add(r.backoff(head.Attempts))
+
+		r.logger.WarnContext(ctx, "failed to relay outbox message",
+			slog.Int64("outbox_id", head.ID),
+			slog.String("stream", stream),
+			slog.String("event_id", head.Event.EventID()),
+			slog.String("event_type", head.Event.EventType()),
+			slog.Int("attempt", head.Attempts+1),
+			slog.Time("retry_at", retryAt),
+			slog.Any("error", err),
+		)
+
+		if markErr := r.store.MarkFailed(ctx, head.ID, err, retryAt); markErr != nil {
+			return markErr
+		}
+
+		return fmt.Errorf("failed to relay outbox message %d: %w", head.ID, err)
+	}
+
+	return r.store.MarkSent(ctx, ids...)
+}
+
+// backoff returns the delay before the next attempt of a message that failed attempts times before.
+func (r *Relay) backoff(attempts int) time.Duration {
+	delay := r.config.MinBackoff
+	for i := 0; i < attempts && delay < r.config.MaxBackoff; i++ {
+		delay *= 2
+	}
+
+	return min(delay, r.config.MaxBackoff)
+}
+
+// sameStreamRun returns the leading messages that share the stream of the first one
+// and are due at now. Only the head can be waiting for a retry: messages behind it never failed.
+func sameStreamRun(messages []Message, now time.Time) []Message {
+	if messages[0].NextAttemptAt.After(now) {
+		return nil
+	}
+
+	n := 1
+	for n < len(messages) && messages[n].Stream == messages[0].Stream && !messages[n].NextAttemptAt.After(now) {
+		n++
+	}
+
+	return messages[:n]
+}
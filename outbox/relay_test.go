@@ -0,0 +1,213 @@
+package outbox_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tclavelloux/promy-event-bus/eventbus"
+	"github.com/tclavelloux/promy-event-bus/outbox"
+	"github.com/tclavelloux/promy-event-bus/testutil"
+)
+
+// memoryStore is an in-memory outbox.Store.
+type memoryStore struct {
+	mu          sync.Mutex
+	messages    []outbox.Message
+	sent        map[int64]bool
+	leaseOwner  string
+	leaseExpiry time.Time
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{sent: make(map[int64]bool)}
+}
+
+func (s *memoryStore) add(t *testing.T, stream string, eventType string) {
+	t.Helper()
+
+	envelope, err := eventbus.NewEnvelope(context.Background(), testutil.NewTestEvent(eventType, nil))
+	require.NoError(t, err)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.messages = append(s.messages, outbox.Message{ID: int64(len(s.messages) + 1), Stream: stream, Event: envelope})
+}
+
+func (s *memoryStore) AcquireLease(_ context.Context, owner string, ttl time.Duration) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.leaseOwner != owner && time.Now().Before(s.leaseExpiry) {
+		return false, nil
+	}
+
+	s.leaseOwner = owner
+	s.leaseExpiry = time.Now().Add(ttl)
+
+	return true, nil
+}
+
+func (s *memoryStore) Pending(_ context.Context, limit int) ([]outbox.Message, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var pending []outbox.Message
+	for _, msg := range s.messages {
+		if !s.sent[msg.ID] && len(pending) < limit {
+			pending = append(pending, msg)
+		}
+	}
+
+	return pending, nil
+}
+
+func (s *memoryStore) MarkSent(_ context.Context, ids ...int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, id := range ids {
+		s.sent[id] = true
+	}
+
+	return nil
+}
+
+func (s *memoryStore) MarkFailed(_ context.Context, id int64, _ error, retryAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := range s.messages {
+		if s.messages[i].ID == id {
+			s.messages[i].Attempts++
+			s.messages[i].NextAttemptAt = retryAt
+		}
+	}
+
+	return nil
+}
+
+// streamRecorder is an EventPublisher recording published event types, versions and
+// partition keys per call.
+type streamRecorder struct {
+	calls    []string
+	types    []string
+	versions []string
+	keys     []string
+	failing  bool
+}
+
+func (r *streamRecorder) Publish(ctx context.Context, stream string, event eventbus.Event) error {
+	return r.PublishBatch(ctx, stream, []eventbus.Event{event})
+}
+
+func (r *streamRecorder) PublishBatch(_ context.Context, stream string, events []eventbus.Event) error {
+	if r.failing {
+		return eventbus.ErrPublishFailed
+	}
+
+	r.calls = append(r.calls, stream)
+	for _, event := range events {
+		r.types = append(r.types, event.EventType())
+		r.versions = append(r.versions, eventbus.EventVersion(event))
+		r.keys = append(r.keys, eventbus.PartitionKey(event))
+	}
+
+	return nil
+}
+
+func (r *streamRecorder) Close() error                 { return nil }
+func (r *streamRecorder) Health(context.Context) error { return nil }
+
+func TestRelay_PublishesInOrder(t *testing.T) {
+	store := newMemoryStore()
+	store.add(t, "events:subscriptions", "subscription.started")
+	store.add(t, "events:subscriptions", "subscription.renewed")
+	store.add(t, "events:users", "user.updated")
+	store.add(t, "events:subscriptions", "subscription.cancelled")
+
+	publisher := &streamRecorder{}
+	relay := outbox.NewRelay(store, publisher, outbox.RelayConfig{})
+
+	relayed, err := relay.RelayOnce(context.Background())
+	require.NoError(t, err)
+
+	assert.Equal(t, 4, relayed)
+	assert.Equal(t, []string{"events:subscriptions", "events:users", "events:subscriptions"}, publisher.calls)
+	assert.Equal(t, []string{"subscription.started", "subscription.renewed", "user.updated", "subscription.cancelled"}, publisher.types)
+
+	pending, err := store.Pending(context.Background(), 10)
+	require.NoError(t, err)
+	assert.Empty(t, pending)
+}
+
+func TestRelay_RetriesWithBackoff(t *testing.T) {
+	store := newMemoryStore()
+	store.add(t, "events:subscriptions", "subscription.started")
+	store.add(t, "events:users", "user.updated")
+
+	publisher := &streamRecorder{failing: true}
+	relay := outbox.NewRelay(store, publisher, outbox.RelayConfig{MinBackoff: 50 * time.Millisecond})
+	ctx := context.Background()
+
+	relayed, err := relay.RelayOnce(ctx)
+	assert.True(t, errors.Is(err, eventbus.ErrPublishFailed))
+	assert.Zero(t, relayed)
+
+	pending, err := store.Pending(ctx, 10)
+	require.NoError(t, err)
+	require.Len(t, pending, 2)
+	assert.Equal(t, 1, pending[0].Attempts)
+	assert.True(t, pending[0].NextAttemptAt.After(time.Now()))
+
+	// The failed head blocks the next message until its retry is due.
+	publisher.failing = false
+	relayed, err = relay.RelayOnce(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, relayed)
+
+	time.Sleep(60 * time.Millisecond)
+	relayed, err = relay.RelayOnce(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 2, relayed)
+	assert.Equal(t, []string{"subscription.started", "user.updated"}, publisher.types)
+}
+
+func TestRelay_OnlyLeaseOwnerPublishes(t *testing.T) {
+	store := newMemoryStore()
+	store.add(t, "events:subscriptions", "subscription.started")
+
+	first := &streamRecorder{}
+	second := &streamRecorder{}
+	ctx := context.Background()
+
+	_, err := outbox.NewRelay(store, first, outbox.RelayConfig{Owner: "relay-1"}).RelayOnce(ctx)
+	require.NoError(t, err)
+
+	store.add(t, "events:subscriptions", "subscription.renewed")
+	relayed, err := outbox.NewRelay(store, second, outbox.RelayConfig{Owner: "relay-2"}).RelayOnce(ctx)
+	require.NoError(t, err)
+
+	assert.Zero(t, relayed)
+	assert.Equal(t, []string{"subscription.started"}, first.types)
+	assert.Empty(t, second.types)
+}
+
+func TestRelay_Run(t *testing.T) {
+	store := newMemoryStore()
+	store.add(t, "events:subscriptions", "subscription.started")
+
+	publisher := &streamRecorder{}
+	relay := outbox.NewRelay(store, publisher, outbox.RelayConfig{PollInterval: 10 * time.Millisecond})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	err := relay.Run(ctx)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Equal(t, []string{"subscription.started"}, publisher.types)
+}
@@ -0,0 +1,350 @@
+package outbox
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	eventbus "github.com/tclavelloux/promy-event-bus/eventbus"
+)
+
+// Dialect selects the SQL flavour of an SQLStore.
+type Dialect string
+
+// Supported dialects.
+const (
+	DialectPostgres Dialect = "postgres"
+	DialectMySQL    Dialect = "mysql"
+	DialectSQLite   Dialect = "sqlite"
+)
+
+const (
+	defaultTable = "eventbus_outbox"
+
+	// leaseName identifies the single relay lease row.
+	leaseName = "relay"
+)
+
+// SQLConfig configures an SQLStore.
+type SQLConfig struct {
+	// Dialect is the SQL flavour of the database.
+	Dialect Dialect
+
+	// Table is the name of the outbox table. The lease table is named "<Table>_lease".
+	// Default: "eventbus_outbox"
+	Table string
+}
+
+// Execer executes statements. *sql.Tx, *sql.DB and *sql.Conn implement it.
+type Execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+// SQLStore is a Store backed by a database/sql database.
+type SQLStore struct {
+	db      *sql.DB
+	dialect Dialect
+	table   string
+	lease   string
+}
+
+// NewSQLStore creates an SQLStore. Call Migrate, or create the tables from Schema, before use.
+func NewSQLStore(db *sql.DB, config SQLConfig) (*SQLStore, error) {
+	switch config.Dialect {
+	case DialectPostgres, DialectMySQL, DialectSQLite:
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnknownDialect, config.Dialect)
+	}
+
+	if config.Table == "" {
+		config.Table = defaultTable
+	}
+
+	return &SQLStore{
+		db:      db,
+		dialect: config.Dialect,
+		table:   config.Table,
+		lease:   config.Table + "_lease",
+	}, nil
+}
+
+// Schema returns the statements creating the outbox and lease tables.
+// Times are stored as Unix milliseconds so they compare the same way in every dialect.
+func (s *SQLStore) Schema() []string {
+	id := "BIGINT PRIMARY KEY AUTO_INCREMENT"
+	text := "TEXT"
+	key := "VARCHAR(255)"
+
+	switch s.dialect {
+	case DialectPostgres:
+		id = "BIGSERIAL PRIMARY KEY"
+	case DialectSQLite:
+		id = "INTEGER PRIMARY KEY AUTOINCREMENT"
+	case DialectMySQL:
+		text = "LONGTEXT"
+	}
+
+	return []string{
+		`CREATE TABLE IF NOT EXISTS ` + s.table + ` (
+	id ` + id + `,
+	stream ` + key + ` NOT NULL,
+	event_id ` + key + ` NOT NULL,
+	event_type ` + key + ` NOT NULL,
+	version ` + key + ` NOT NULL DEFAULT '',
+	partition_key ` + key + ` NOT NULL DEFAULT '',
+	occurred_at ` + key + ` NOT NULL,
+	headers ` + text + ` NOT NULL,
+	payload ` + text + ` NOT NULL,
+	attempts INTEGER NOT NULL DEFAULT 0,
+	last_error ` + text + `,
+	next_attempt_at BIGINT NOT NULL DEFAULT 0,
+	sent_at BIGINT
+)`,
+		`CREATE TABLE IF NOT EXISTS ` + s.lease + ` (
+	name ` + key + ` PRIMARY KEY,
+	owner ` + key + ` NOT NULL,
+	expires_at BIGINT NOT NULL
+)`,
+	}
+}
+
+// Migrate creates the outbox and lease tables if they do not exist, and adds the
+// columns of newer versions to an existing outbox table.
+func (s *SQLStore) Migrate(ctx context.Context) error {
+	for _, statement := range s.Schema() {
+		if _, err := s.db.ExecContext(ctx, statement); err != nil {
+			return fmt.Errorf("failed to create outbox tables: %w", err)
+		}
+	}
+
+	for _, column := range s.addedColumns() {
+		// Probe the column, since not every dialect supports ADD COLUMN IF NOT EXISTS
+		if _, err := s.db.ExecContext(ctx, `SELECT `+column.name+` FROM `+s.table+` WHERE 1 = 0`); err == nil {
+			continue
+		}
+
+		if _, err := s.db.ExecContext(ctx,
+			`ALTER TABLE `+s.table+` ADD COLUMN `+column.name+` `+column.definition,
+		); err != nil {
+			return fmt.Errorf("failed to add outbox column %s: %w", column.name, err)
+		}
+	}
+
+	return nil
+}
+
+// column is a column added to the outbox table after its first version.
+type column struct {
+	name       string
+	definition string
+}
+
+// addedColumns returns the columns Migrate adds to outbox tables created by older
+// versions. Rows written before have an empty value.
+func (s *SQLStore) addedColumns() []column {
+	return []column{
+		{name: "version", definition: "VARCHAR(255) NOT NULL DEFAULT ''"},
+		{name: "partition_key", definition: "VARCHAR(255) NOT NULL DEFAULT ''"},
+	}
+}
+
+// Add validates events and writes them to the outbox through tx, typically the
+// transaction that changes the state the events describe. Headers are resolved
+// from ctx and each event (see eventbus.OutgoingHeaders).
+func (s *SQLStore) Add(ctx context.Context, tx Execer, stream string, events ...eventbus.Event) error {
+	query := s.rebind(`INSERT INTO ` + s.table +
+		` (stream, event_id, event_type, version, partition_key, occurred_at, headers, payload) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`)
+
+	for _, event := range events {
+		envelope, err := eventbus.NewEnvelope(ctx, event)
+		if err != nil {
+			return err
+		}
+
+		headers, err := json.Marshal(envelope.Header)
+		if err != nil {
+			return fmt.Errorf("failed to marshal headers: %w", err)
+		}
+
+		if _, err := tx.ExecContext(ctx, query,
+			stream,
+			envelope.ID,
+			envelope.Type,
+			envelope.Version,
+			envelope.Key,
+			envelope.Time.UTC().Format(time.RFC3339Nano),
+			string(headers),
+			string(envelope.Payload),
+		); err != nil {
+			return fmt.Errorf("failed to write outbox message: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// AcquireLease implements Store.
+func (s *SQLStore) AcquireLease(ctx context.Context, owner string, ttl time.Duration) (bool, error) {
+	now := time.Now()
+	expiresAt := now.Add(ttl).UnixMilli()
+
+	result, err := s.db.ExecContext(ctx,
+		s.rebind(`UPDATE `+s.lease+` SET owner = ?, expires_at = ? WHERE name = ? AND (owner = ? OR expires_at < ?)`),
+		owner, expiresAt, leaseName, owner, now.UnixMilli(),
+	)
+	if err != nil {
+		return false, fmt.Errorf("failed to acquire outbox lease: %w", err)
+	}
+	if acquired, err := affected(result); err != nil || acquired {
+		return acquired, err
+	}
+
+	// First relay ever: create the lease row unless a concurrent relay just did.
+	result, err = s.db.ExecContext(ctx, s.insertLeaseQuery(), leaseName, owner, expiresAt)
+	if err != nil {
+		return false, fmt.Errorf("failed to acquire outbox lease: %w", err)
+	}
+
+	return affected(result)
+}
+
+func affected(result sql.Result) (bool, error) {
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to acquire outbox lease: %w", err)
+	}
+
+	return rows > 0, nil
+}
+
+// Pending implements Store.
+func (s *SQLStore) Pending(ctx context.Context, limit int) ([]Message, error) {
+	rows, err := s.db.QueryContext(ctx,
+		s.rebind(`SELECT id, stream, event_id, event_type, version, partition_key, occurred_at, headers, payload, attempts, next_attempt_at FROM `+
+			s.table+` WHERE sent_at IS NULL ORDER BY id LIMIT `+strconv.Itoa(limit)),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read outbox: %w", err)
+	}
+	defer rows.Close()
+
+	var messages []Message
+	for rows.Next() {
+		var (
+			msg           Message
+			envelope      eventbus.Envelope
+			occurredAt    string
+			headers       string
+			payload       string
+			nextAttemptAt int64
+		)
+
+		if err := rows.Scan(&msg.ID, &msg.Stream, &envelope.ID, &envelope.Type, &envelope.Version, &envelope.Key,
+			&occurredAt, &headers, &payload, &msg.Attempts, &nextAttemptAt); err != nil {
+			return nil, fmt.Errorf("failed to read outbox: %w", err)
+		}
+
+		if envelope.Time, err = time.Parse(time.RFC3339Nano, occurredAt); err != nil {
+			return nil, fmt.Errorf("outbox message %d: invalid occurred_at: %w", msg.ID, err)
+		}
+		if err := json.Unmarshal([]byte(headers), &envelope.Header); err != nil {
+			return nil, fmt.Errorf("outbox message %d: invalid headers: %w", msg.ID, err)
+		}
+		envelope.Payload = json.RawMessage(payload)
+
+		if nextAttemptAt > 0 {
+			msg.NextAttemptAt = time.UnixMilli(nextAttemptAt)
+		}
+		msg.Event = &envelope
+
+		messages = append(messages, msg)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read outbox: %w", err)
+	}
+
+	return messages, nil
+}
+
+// MarkSent implements Store.
+func (s *SQLStore) MarkSent(ctx context.Context, ids ...int64) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	args := make([]any, 0, len(ids)+1)
+	args = append(args, time.Now().UnixMilli())
+	for _, id := range ids {
+		args = append(args, id)
+	}
+
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(ids)), ", ")
+	if _, err := s.db.ExecContext(ctx,
+		s.rebind(`UPDATE `+s.table+` SET sent_at = ? WHERE id IN (`+placeholders+`)`),
+		args...,
+	); err != nil {
+		return fmt.Errorf("failed to mark outbox messages sent: %w", err)
+	}
+
+	return nil
+}
+
+// MarkFailed implements Store.
+func (s *SQLStore) MarkFailed(ctx context.Context, id int64, cause error, retryAt time.Time) error {
+	if _, err := s.db.ExecContext(ctx,
+		s.rebind(`UPDATE `+s.table+` SET attempts = attempts + 1, last_error = ?, next_attempt_at = ? WHERE id = ?`),
+		cause.Error(), retryAt.UnixMilli(), id,
+	); err != nil {
+		return fmt.Errorf("failed to mark outbox message failed: %w", err)
+	}
+
+	return nil
+}
+
+// DeleteSent removes messages sent before the given time, to keep the outbox table small.
+func (s *SQLStore) DeleteSent(ctx context.Context, before time.Time) (int64, error) {
+	result, err := s.db.ExecContext(ctx,
+		s.rebind(`DELETE FROM `+s.table+` WHERE sent_at IS NOT NULL AND sent_at < ?`),
+		before.UnixMilli(),
+	)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete sent outbox messages: %w", err)
+	}
+
+	return result.RowsAffected()
+}
+
+func (s *SQLStore) insertLeaseQuery() string {
+	if s.dialect == DialectMySQL {
+		return `INSERT IGNORE INTO ` + s.lease + ` (name, owner, expires_at) VALUES (?, ?, ?)`
+	}
+
+	return s.rebind(`INSERT INTO ` + s.lease + ` (name, owner, expires_at) VALUES (?, ?, ?) ON CONFLICT (name) DO NOTHING`)
+}
+
+// rebind rewrites "?" placeholders into the dialect's form.
+func (s *SQLStore) rebind(query string) string {
+	if s.dialect != DialectPostgres {
+		return query
+	}
+
+	var b strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+
+			continue
+		}
+
+		b.WriteRune(r)
+	}
+
+	return b.String()
+}
@@ -0,0 +1,201 @@
+package outbox_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tclavelloux/promy-event-bus/eventbus"
+	"github.com/tclavelloux/promy-event-bus/outbox"
+	"github.com/tclavelloux/promy-event-bus/testutil"
+
+	_ "modernc.org/sqlite"
+)
+
+func newSQLiteStore(t *testing.T) (*outbox.SQLStore, *sql.DB) {
+	t.Helper()
+
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "outbox.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	store, err := outbox.NewSQLStore(db, outbox.SQLConfig{Dialect: outbox.DialectSQLite})
+	require.NoError(t, err)
+	require.NoError(t, store.Migrate(context.Background()))
+
+	return store, db
+}
+
+func TestNewSQLStore_UnknownDialect(t *testing.T) {
+	_, err := outbox.NewSQLStore(nil, outbox.SQLConfig{Dialect: "oracle"})
+	assert.ErrorIs(t, err, outbox.ErrUnknownDialect)
+}
+
+func TestSQLStore_Add(t *testing.T) {
+	store, db := newSQLiteStore(t)
+	ctx := eventbus.WithCorrelationID(context.Background(), "corr-1")
+
+	t.Run("rolled back events are not pending", func(t *testing.T) {
+		tx, err := db.BeginTx(ctx, nil)
+		require.NoError(t, err)
+		require.NoError(t, store.Add(ctx, tx, "events:subscriptions", testutil.NewTestEvent("subscription.started", nil)))
+		require.NoError(t, tx.Rollback())
+
+		pending, err := store.Pending(ctx, 10)
+		require.NoError(t, err)
+		assert.Empty(t, pending)
+	})
+
+	t.Run("committed events are pending in order", func(t *testing.T) {
+		started := testutil.NewTestEvent("subscription.started", map[string]any{"user_id": "user-1"})
+		updated := testutil.NewTestEvent("user.updated", map[string]any{"user_id": "user-1"})
+
+		tx, err := db.BeginTx(ctx, nil)
+		require.NoError(t, err)
+		require.NoError(t, store.Add(ctx, tx, "events:subscriptions", started))
+		require.NoError(t, store.Add(ctx, tx, "events:users", updated))
+		require.NoError(t, tx.Commit())
+
+		pending, err := store.Pending(ctx, 10)
+		require.NoError(t, err)
+		require.Len(t, pending, 2)
+
+		assert.Equal(t, "events:subscriptions", pending[0].Stream)
+		assert.Equal(t, started.EventID(), pending[0].Event.EventID())
+		assert.Equal(t, "subscription.started", pending[0].Event.EventType())
+		assert.True(t, started.EventTime().Equal(pending[0].Event.EventTime()))
+		assert.Equal(t, "corr-1", pending[0].Event.Headers().CorrelationID())
+		assert.JSONEq(t, mustMarshal(t, started), pending[0].Event.Data())
+		assert.Equal(t, "events:users", pending[1].Stream)
+		assert.Less(t, pending[0].ID, pending[1].ID)
+
+		require.NoError(t, store.MarkSent(ctx, pending[0].ID, pending[1].ID))
+		pending, err = store.Pending(ctx, 10)
+		require.NoError(t, err)
+		assert.Empty(t, pending)
+
+		deleted, err := store.DeleteSent(ctx, time.Now().Add(time.Second))
+		require.NoError(t, err)
+		assert.Equal(t, int64(2), deleted)
+	})
+
+	t.Run("rejects invalid events", func(t *testing.T) {
+		err := store.Add(ctx, db, "events:dlq", &eventbus.DLQEntry{})
+		assert.ErrorIs(t, err, eventbus.ErrInvalidEvent)
+	})
+}
+
+func TestSQLStore_MarkFailed(t *testing.T) {
+	store, db := newSQLiteStore(t)
+	ctx := context.Background()
+
+	require.NoError(t, store.Add(ctx, db, "events:subscriptions", testutil.NewTestEvent("subscription.started", nil)))
+	pending, err := store.Pending(ctx, 10)
+	require.NoError(t, err)
+	require.Len(t, pending, 1)
+
+	retryAt := time.Now().Add(time.Minute).Truncate(time.Millisecond)
+	require.NoError(t, store.MarkFailed(ctx, pending[0].ID, errors.New("redis down"), retryAt))
+
+	pending, err = store.Pending(ctx, 10)
+	require.NoError(t, err)
+	require.Len(t, pending, 1)
+	assert.Equal(t, 1, pending[0].Attempts)
+	assert.True(t, retryAt.Equal(pending[0].NextAttemptAt))
+}
+
+func TestSQLStore_AcquireLease(t *testing.T) {
+	store, _ := newSQLiteStore(t)
+	ctx := context.Background()
+
+	acquired, err := store.AcquireLease(ctx, "relay-1", time.Hour)
+	require.NoError(t, err)
+	assert.True(t, acquired)
+
+	acquired, err = store.AcquireLease(ctx, "relay-2", time.Hour)
+	require.NoError(t, err)
+	assert.False(t, acquired, "lease is held by relay-1")
+
+	acquired, err = store.AcquireLease(ctx, "relay-1", -time.Second)
+	require.NoError(t, err)
+	assert.True(t, acquired, "owner renews its lease")
+
+	acquired, err = store.AcquireLease(ctx, "relay-2", time.Hour)
+	require.NoError(t, err)
+	assert.True(t, acquired, "expired lease is taken over")
+}
+
+func TestRelay_SQLStore(t *testing.T) {
+	store, db := newSQLiteStore(t)
+	ctx := context.Background()
+
+	event := testutil.NewTestEvent("subscription.started", nil)
+	event.Version = "2.0"
+	event.Key = "user-1"
+	require.NoError(t, store.Add(ctx, db, "events:subscriptions", event))
+
+	publisher := &streamRecorder{}
+	relayed, err := outbox.NewRelay(store, publisher, outbox.RelayConfig{}).RelayOnce(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, relayed)
+	assert.Equal(t, []string{"subscription.started"}, publisher.types)
+	assert.Equal(t, []string{"2.0"}, publisher.versions)
+	assert.Equal(t, []string{"user-1"}, publisher.keys)
+}
+
+func TestSQLStore_MigratesOlderTables(t *testing.T) {
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "outbox.db"))
+	require.NoError(t, err)
+	defer db.Close()
+
+	ctx := context.Background()
+
+	// Outbox table created before the version column
+	_, err = db.ExecContext(ctx, `CREATE TABLE eventbus_outbox (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	stream VARCHAR(255) NOT NULL,
+	event_id VARCHAR(255) NOT NULL,
+	event_type VARCHAR(255) NOT NULL,
+	occurred_at VARCHAR(255) NOT NULL,
+	headers TEXT NOT NULL,
+	payload TEXT NOT NULL,
+	attempts INTEGER NOT NULL DEFAULT 0,
+	last_error TEXT,
+	next_attempt_at BIGINT NOT NULL DEFAULT 0,
+	sent_at BIGINT
+)`)
+	require.NoError(t, err)
+	_, err = db.ExecContext(ctx, `INSERT INTO eventbus_outbox (stream, event_id, event_type, occurred_at, headers, payload)
+VALUES ('events:users', 'evt-1', 'user.registered', '2024-01-01T00:00:00Z', '{}', '{}')`)
+	require.NoError(t, err)
+
+	store, err := outbox.NewSQLStore(db, outbox.SQLConfig{Dialect: outbox.DialectSQLite})
+	require.NoError(t, err)
+	require.NoError(t, store.Migrate(ctx))
+	require.NoError(t, store.Migrate(ctx), "migrating twice is a no-op")
+
+	event := testutil.NewTestEvent("user.updated", nil)
+	event.Version = "2.0"
+	require.NoError(t, store.Add(ctx, db, "events:users", event))
+
+	pending, err := store.Pending(ctx, 10)
+	require.NoError(t, err)
+	require.Len(t, pending, 2)
+	assert.Equal(t, eventbus.DefaultEventVersion, eventbus.EventVersion(pending[0].Event), "older rows")
+	assert.Equal(t, "evt-1", eventbus.PartitionKey(pending[0].Event), "older rows use the event ID")
+	assert.Equal(t, "2.0", eventbus.EventVersion(pending[1].Event))
+}
+
+func mustMarshal(t *testing.T, event eventbus.Event) string {
+	t.Helper()
+
+	envelope, err := eventbus.NewEnvelope(context.Background(), event)
+	require.NoError(t, err)
+
+	return string(envelope.Payload)
+}
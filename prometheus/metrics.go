@@ -0,0 +1,172 @@
+// Package prometheus implements eventbus.Metrics and spool.Metrics with Prometheus collectors.
+package prometheus
+
+import (
+	"time"
+
+	eventbus "github.com/tclavelloux/promy-event-bus/eventbus"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const namespace = "eventbus"
+
+// Label names.
+const (
+	labelStream  = "stream"
+	labelGroup   = "group"
+	labelType    = "type"
+	labelOutcome = "outcome"
+)
+
+// Metrics implements eventbus.Metrics with Prometheus collectors.
+type Metrics struct {
+	published       *prometheus.CounterVec
+	publishErrors   *prometheus.CounterVec
+	publishDuration *prometheus.HistogramVec
+	handled         *prometheus.CounterVec
+	handlerDuration *prometheus.HistogramVec
+	retries         *prometheus.CounterVec
+	deadLettered    *prometheus.CounterVec
+	inFlight        *prometheus.GaugeVec
+	endToEnd        *prometheus.HistogramVec
+	spoolEvents     prometheus.Gauge
+	spoolBytes      prometheus.Gauge
+}
+
+// NewMetrics creates the event bus collectors and registers them with registerer.
+// If registerer is nil, prometheus.DefaultRegisterer is used.
+// Create a single Metrics per registerer and share it between publishers and subscribers.
+func NewMetrics(registerer prometheus.Registerer) (*Metrics, error) {
+	if registerer == nil {
+		registerer = prometheus.DefaultRegisterer
+	}
+
+	m := &Metrics{
+		published: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "published_total",
+			Help:      "Number of events successfully published.",
+		}, []string{labelStream, labelType}),
+		publishErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "publish_errors_total",
+			Help:      "Number of events that failed to publish.",
+		}, []string{labelStream, labelType}),
+		publishDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "publish_duration_seconds",
+			Help:      "Time spent publishing an event.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{labelStream}),
+		handled: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "handled_total",
+			Help:      "Number of handler invocations by outcome.",
+		}, []string{labelStream, labelGroup, labelType, labelOutcome}),
+		handlerDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "handler_duration_seconds",
+			Help:      "Time spent in event handlers.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{labelStream, labelGroup, labelType}),
+		retries: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "retries_total",
+			Help:      "Number of events scheduled for another attempt.",
+		}, []string{labelStream, labelGroup, labelType}),
+		deadLettered: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "dead_lettered_total",
+			Help:      "Number of events routed to the dead-letter queue.",
+		}, []string{labelStream, labelGroup, labelType}),
+		inFlight: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "in_flight",
+			Help:      "Number of events currently being handled.",
+		}, []string{labelStream, labelGroup}),
+		endToEnd: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "end_to_end_latency_seconds",
+			Help:      "Time between the event occurring and the start of its handling.",
+			Buckets:   []float64{.01, .05, .1, .5, 1, 5, 15, 30, 60, 300, 900},
+		}, []string{labelStream, labelGroup, labelType}),
+		spoolEvents: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "spool_events",
+			Help:      "Number of events waiting in the local spool.",
+		}),
+		spoolBytes: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "spool_bytes",
+			Help:      "Disk space used by the local spool.",
+		}),
+	}
+
+	for _, collector := range m.collectors() {
+		if err := registerer.Register(collector); err != nil {
+			return nil, err
+		}
+	}
+
+	return m, nil
+}
+
+func (m *Metrics) collectors() []prometheus.Collector {
+	return []prometheus.Collector{
+		m.published,
+		m.publishErrors,
+		m.publishDuration,
+		m.handled,
+		m.handlerDuration,
+		m.retries,
+		m.deadLettered,
+		m.inFlight,
+		m.endToEnd,
+		m.spoolEvents,
+		m.spoolBytes,
+	}
+}
+
+// EventPublished implements eventbus.Metrics.
+func (m *Metrics) EventPublished(stream, eventType string, duration time.Duration, err error) {
+	if err != nil {
+		m.publishErrors.WithLabelValues(stream, eventType).Inc()
+	} else {
+		m.published.WithLabelValues(stream, eventType).Inc()
+	}
+
+	m.publishDuration.WithLabelValues(stream).Observe(duration.Seconds())
+}
+
+// EventHandled implements eventbus.Metrics.
+func (m *Metrics) EventHandled(stream, group, eventType string, outcome eventbus.Outcome, duration time.Duration) {
+	m.handled.WithLabelValues(stream, group, eventType, string(outcome)).Inc()
+	m.handlerDuration.WithLabelValues(stream, group, eventType).Observe(duration.Seconds())
+}
+
+// EventRetried implements eventbus.Metrics.
+func (m *Metrics) EventRetried(stream, group, eventType string, _ int) {
+	m.retries.WithLabelValues(stream, group, eventType).Inc()
+}
+
+// EventDeadLettered implements eventbus.Metrics.
+func (m *Metrics) EventDeadLettered(stream, group, eventType string) {
+	m.deadLettered.WithLabelValues(stream, group, eventType).Inc()
+}
+
+// InFlight implements eventbus.Metrics.
+func (m *Metrics) InFlight(stream, group string, delta int) {
+	m.inFlight.WithLabelValues(stream, group).Add(float64(delta))
+}
+
+// EndToEndLatency implements eventbus.Metrics.
+func (m *Metrics) EndToEndLatency(stream, group, eventType string, latency time.Duration) {
+	m.endToEnd.WithLabelValues(stream, group, eventType).Observe(latency.Seconds())
+}
+
+// SpoolDepth implements spool.Metrics.
+func (m *Metrics) SpoolDepth(events int, bytes int64) {
+	m.spoolEvents.Set(float64(events))
+	m.spoolBytes.Set(float64(bytes))
+}
@@ -0,0 +1,86 @@
+package prometheus_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	eventbus "github.com/tclavelloux/promy-event-bus/eventbus"
+	eventbusprom "github.com/tclavelloux/promy-event-bus/prometheus"
+	"github.com/tclavelloux/promy-event-bus/spool"
+
+	"github.com/prometheus/client_golang/prometheus"
+	promtestutil "github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetrics_Collect(t *testing.T) {
+	registry := prometheus.NewRegistry()
+
+	metrics, err := eventbusprom.NewMetrics(registry)
+	require.NoError(t, err)
+
+	var _ eventbus.Metrics = metrics
+	var _ spool.Metrics = metrics
+
+	metrics.EventPublished("events:users", "user.registered", 5*time.Millisecond, nil)
+	metrics.EventPublished("events:users", "user.registered", 5*time.Millisecond, errors.New("boom"))
+	metrics.EventHandled("events:users", "promy-crm", "user.registered", eventbus.OutcomeSuccess, 20*time.Millisecond)
+	metrics.EventHandled("events:users", "promy-crm", "user.registered", eventbus.OutcomeRetry, 20*time.Millisecond)
+	metrics.EventRetried("events:users", "promy-crm", "user.registered", 2)
+	metrics.EventDeadLettered("events:users", "promy-crm", "user.registered")
+	metrics.InFlight("events:users", "promy-crm", 2)
+	metrics.InFlight("events:users", "promy-crm", -1)
+	metrics.EndToEndLatency("events:users", "promy-crm", "user.registered", time.Second)
+	metrics.SpoolDepth(3, 1024)
+
+	expected := `
+# HELP eventbus_handled_total Number of handler invocations by outcome.
+# TYPE eventbus_handled_total counter
+eventbus_handled_total{group="promy-crm",outcome="retry",stream="events:users",type="user.registered"} 1
+eventbus_handled_total{group="promy-crm",outcome="success",stream="events:users",type="user.registered"} 1
+# HELP eventbus_in_flight Number of events currently being handled.
+# TYPE eventbus_in_flight gauge
+eventbus_in_flight{group="promy-crm",stream="events:users"} 1
+# HELP eventbus_publish_errors_total Number of events that failed to publish.
+# TYPE eventbus_publish_errors_total counter
+eventbus_publish_errors_total{stream="events:users",type="user.registered"} 1
+# HELP eventbus_published_total Number of events successfully published.
+# TYPE eventbus_published_total counter
+eventbus_published_total{stream="events:users",type="user.registered"} 1
+# HELP eventbus_retries_total Number of events scheduled for another attempt.
+# TYPE eventbus_retries_total counter
+eventbus_retries_total{group="promy-crm",stream="events:users",type="user.registered"} 1
+# HELP eventbus_dead_lettered_total Number of events routed to the dead-letter queue.
+# TYPE eventbus_dead_lettered_total counter
+eventbus_dead_lettered_total{group="promy-crm",stream="events:users",type="user.registered"} 1
+# HELP eventbus_spool_events Number of events waiting in the local spool.
+# TYPE eventbus_spool_events gauge
+eventbus_spool_events 3
+`
+	err = promtestutil.GatherAndCompare(registry, strings.NewReader(expected),
+		"eventbus_handled_total",
+		"eventbus_in_flight",
+		"eventbus_publish_errors_total",
+		"eventbus_published_total",
+		"eventbus_retries_total",
+		"eventbus_dead_lettered_total",
+		"eventbus_spool_events",
+	)
+	assert.NoError(t, err)
+
+	assert.Equal(t, 1, promtestutil.CollectAndCount(registry, "eventbus_end_to_end_latency_seconds"))
+	assert.Equal(t, 1, promtestutil.CollectAndCount(registry, "eventbus_handler_duration_seconds"))
+}
+
+func TestNewMetrics_DuplicateRegistration(t *testing.T) {
+	registry := prometheus.NewRegistry()
+
+	_, err := eventbusprom.NewMetrics(registry)
+	require.NoError(t, err)
+
+	_, err = eventbusprom.NewMetrics(registry)
+	assert.Error(t, err)
+}
@@ -0,0 +1,44 @@
+package redis
+
+import (
+	eventbus "github.com/tclavelloux/promy-event-bus/eventbus"
+)
+
+func init() {
+	eventbus.RegisterBackend("redis", NewBackend())
+}
+
+// backend builds Redis publishers and subscribers for eventbus.NewPublisher and
+// eventbus.NewSubscriber.
+type backend struct {
+	opts []Option
+}
+
+// NewBackend returns a backend creating publishers and subscribers with opts. Package
+// redis registers one without options as "redis"; register another under a new name to
+// select options by configuration, e.g.:
+//
+//	eventbus.RegisterBackend("redis-traced", redis.NewBackend(redis.WithTracerProvider(tp)))
+func NewBackend(opts ...Option) eventbus.Backend {
+	return backend{opts: opts}
+}
+
+// NewPublisher implements eventbus.Backend with NewPublisher.
+func (b backend) NewPublisher(config eventbus.Config) (eventbus.EventPublisher, error) {
+	publisher, err := NewPublisher(config.Redis, b.opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	return publisher, nil
+}
+
+// NewSubscriber implements eventbus.Backend with NewSubscriber.
+func (b backend) NewSubscriber(config eventbus.Config) (eventbus.EventSubscriber, error) {
+	subscriber, err := NewSubscriber(config, b.opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	return subscriber, nil
+}
@@ -0,0 +1,32 @@
+//nolint:all // Test file
+package redis_test
+
+import (
+	"testing"
+
+	eventbus "github.com/tclavelloux/promy-event-bus/eventbus"
+	"github.com/tclavelloux/promy-event-bus/redis"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBackend(t *testing.T) {
+	config := eventbus.Config{Type: "redis", Redis: eventbus.RedisConfig{DSN: "redis://localhost:6379/1"}}
+
+	publisher, err := eventbus.NewPublisher(config)
+	require.NoError(t, err)
+	defer publisher.Close()
+	assert.IsType(t, &redis.Publisher{}, publisher)
+
+	// The default type is redis
+	config.Type = ""
+	subscriber, err := eventbus.NewSubscriber(config)
+	require.NoError(t, err)
+	defer subscriber.Close()
+	assert.IsType(t, &redis.Subscriber{}, subscriber)
+
+	publisher, err = eventbus.NewPublisher(eventbus.Config{Type: "redis", Redis: eventbus.RedisConfig{DSN: "http://localhost"}})
+	assert.Error(t, err)
+	assert.Nil(t, publisher)
+}
@@ -0,0 +1,143 @@
+package redis
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	eventbus "github.com/tclavelloux/promy-event-bus/eventbus"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// publishBatchScript XADDs one entry per event in a single atomic step.
+// Every stream is checked before anything is written, so a key holding another
+// type rejects the whole batch instead of failing halfway.
+// When the window is positive, events whose ID was published within it are
+// skipped and report the original message ID.
+//
+// KEYS, two per event: target stream, dedup key.
+// ARGV[1]: dedup window in ms ("0" disables deduplication).
+// ARGV, then four per event: trim strategy ("", "MAXLEN" or "MINID"), trim threshold, metadata, payload.
+// Returns {1, id1, duplicate1, id2, duplicate2, ...} on success or
+// {0, index, reason} when event index (1-based) is rejected.
+var publishBatchScript = redis.NewScript(`
+for i = 1, #KEYS, 2 do
+  local keyType = redis.call('TYPE', KEYS[i])['ok']
+  if keyType ~= 'stream' and keyType ~= 'none' then
+    return {0, (i + 1) / 2, 'WRONGTYPE ' .. KEYS[i] .. ' holds a ' .. keyType .. ', not a stream'}
+  end
+end
+
+local window = tonumber(ARGV[1])
+local result = {1}
+for i = 1, #KEYS, 2 do
+  local base = 1 + (i - 1) * 2
+  local existing = false
+  if window > 0 then
+    existing = redis.call('GET', KEYS[i + 1])
+  end
+
+  if existing then
+    table.insert(result, existing)
+    table.insert(result, 1)
+  else
+    local args = {KEYS[i]}
+    if ARGV[base + 1] ~= '' then
+      table.insert(args, ARGV[base + 1])
+      table.insert(args, '~')
+      table.insert(args, ARGV[base + 2])
+    end
+    table.insert(args, '*')
+    table.insert(args, 'metadata')
+    table.insert(args, ARGV[base + 3])
+    table.insert(args, 'payload')
+    table.insert(args, ARGV[base + 4])
+
+    local id = redis.call('XADD', unpack(args))
+    if window > 0 then
+      redis.call('SET', KEYS[i + 1], id, 'PX', window)
+    end
+    table.insert(result, id)
+    table.insert(result, 0)
+  end
+end
+
+return result
+`)
+
+// publishAtomic validates and encodes every event, then writes them all with publishBatchScript.
+// Results are in input order.
+func (p *Publisher) publishAtomic(ctx context.Context, events []eventbus.StreamEvent) ([]eventbus.PublishResult, error) {
+	batchErr := eventbus.NewBatchError(len(events))
+	failed := false
+
+	keys := make([]string, 0, 2*len(events))
+	args := make([]any, 0, 1+4*len(events))
+	args = append(args, dedupWindowArg(p.dedupWindow))
+	msgs := make([]streamMessage, len(events))
+	targets := make([]string, len(events))
+
+	for i, se := range events {
+		if err := validateEvent(se.Event); err != nil {
+			batchErr.Errs[i] = err
+			failed = true
+
+			continue
+		}
+
+		msg, err := p.encodeMessage(ctx, se.Stream, se.Event, time.Now())
+		if err != nil {
+			batchErr.Errs[i] = err
+			failed = true
+
+			continue
+		}
+		msgs[i] = msg
+
+		target := p.targetStream(se.Stream, se.Event)
+		targets[i] = target
+		strategy, threshold := trimArgs(streamRetention(p.config, target))
+		keys = append(keys, target, dedupKey(target, se.Event.EventID()))
+		args = append(args, strategy, threshold, msg.metadata, msg.payload)
+	}
+
+	if failed {
+		p.deleteClaimChecks(ctx, msgs...)
+
+		return nil, batchErr
+	}
+
+	// If the script fails, whether it wrote the batch is unknown: blobs are left to SweepClaimChecks
+	reply, err := publishBatchScript.Run(ctx, p.client, keys, args...).Slice()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", eventbus.ErrPublishFailed, err)
+	}
+
+	if ok, _ := reply[0].(int64); ok != 1 {
+		index, _ := reply[1].(int64)
+		reason, _ := reply[2].(string)
+		batchErr.Errs[index-1] = fmt.Errorf("%w: %s", eventbus.ErrPublishFailed, reason)
+		p.deleteClaimChecks(ctx, msgs...)
+
+		return nil, batchErr
+	}
+
+	results := make([]eventbus.PublishResult, len(events))
+	for i := range results {
+		results[i] = scriptResult(reply[1+2*i], reply[2+2*i])
+		if results[i].Duplicate {
+			p.deleteDuplicateClaimCheck(ctx, targets[i], results[i].MessageID, msgs[i])
+		}
+	}
+
+	return results, nil
+}
+
+// scriptResult converts the message ID and duplicate flag returned by a publish script.
+func scriptResult(id, duplicate any) eventbus.PublishResult {
+	messageID, _ := id.(string)
+	flag, _ := duplicate.(int64)
+
+	return eventbus.PublishResult{MessageID: messageID, Duplicate: flag == 1}
+}
@@ -0,0 +1,104 @@
+//nolint:all // Test file
+package redis_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	eventbus "github.com/tclavelloux/promy-event-bus/eventbus"
+	"github.com/tclavelloux/promy-event-bus/redis"
+	"github.com/tclavelloux/promy-event-bus/testutil"
+
+	goredis "github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPublisher_PublishBatch_Atomic(t *testing.T) {
+	const stream = "events:test-batch-atomic"
+
+	client := goredis.NewClient(&goredis.Options{Addr: "localhost:6379", DB: 1})
+	defer client.Close()
+
+	publisher, err := redis.NewPublisher(eventbus.RedisConfig{DSN: "redis://localhost:6379/1"})
+	require.NoError(t, err)
+	defer publisher.Close()
+
+	ctx := context.Background()
+
+	t.Run("invalid event rejects the whole batch", func(t *testing.T) {
+		require.NoError(t, client.Del(ctx, stream).Err())
+
+		events := []eventbus.Event{
+			testutil.NewTestEvent("promotion.created", map[string]any{"promotion_id": "promo-1"}),
+			&eventbus.DLQEntry{},
+			testutil.NewTestEvent("promotion.created", map[string]any{"promotion_id": "promo-3"}),
+		}
+
+		err := publisher.PublishBatch(ctx, stream, events)
+
+		var batchErr *eventbus.BatchError
+		require.True(t, errors.As(err, &batchErr))
+		assert.NoError(t, batchErr.Errs[0])
+		assert.ErrorIs(t, batchErr.Errs[1], eventbus.ErrInvalidEvent)
+		assert.NoError(t, batchErr.Errs[2])
+
+		length, err := client.XLen(ctx, stream).Result()
+		require.NoError(t, err)
+		assert.Zero(t, length)
+	})
+
+	t.Run("write failure rejects the whole batch", func(t *testing.T) {
+		const wrongType = "events:test-batch-wrongtype"
+		require.NoError(t, client.Del(ctx, stream).Err())
+		require.NoError(t, client.Set(ctx, wrongType, "not a stream", 0).Err())
+		defer client.Del(ctx, wrongType)
+
+		err := publisher.PublishMulti(ctx, []eventbus.StreamEvent{
+			{Stream: stream, Event: testutil.NewTestEvent("promotion.created", map[string]any{"promotion_id": "promo-1"})},
+			{Stream: wrongType, Event: testutil.NewTestEvent("promotion.created", map[string]any{"promotion_id": "promo-2"})},
+		})
+
+		var batchErr *eventbus.BatchError
+		require.True(t, errors.As(err, &batchErr))
+		assert.NoError(t, batchErr.Errs[0])
+		assert.ErrorIs(t, batchErr.Errs[1], eventbus.ErrPublishFailed)
+
+		length, err := client.XLen(ctx, stream).Result()
+		require.NoError(t, err)
+		assert.Zero(t, length)
+	})
+}
+
+func TestPublisher_PublishMulti(t *testing.T) {
+	const (
+		subscriptions = "events:test-multi-subscriptions"
+		users         = "events:test-multi-users"
+	)
+
+	client := goredis.NewClient(&goredis.Options{Addr: "localhost:6379", DB: 1})
+	defer client.Close()
+
+	ctx := context.Background()
+	require.NoError(t, client.Del(ctx, subscriptions, users).Err())
+
+	publisher, err := redis.NewPublisher(eventbus.RedisConfig{DSN: "redis://localhost:6379/1"})
+	require.NoError(t, err)
+	defer publisher.Close()
+
+	err = publisher.PublishMulti(ctx, []eventbus.StreamEvent{
+		{Stream: subscriptions, Event: testutil.NewTestEvent("subscription.started", map[string]any{"user_id": "user-1"})},
+		{Stream: users, Event: testutil.NewTestEvent("user.updated", map[string]any{"user_id": "user-1"})},
+		{Stream: subscriptions, Event: testutil.NewTestEvent("subscription.renewed", map[string]any{"user_id": "user-1"})},
+	})
+	require.NoError(t, err)
+
+	length, err := client.XLen(ctx, subscriptions).Result()
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), length)
+
+	length, err = client.XLen(ctx, users).Result()
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), length)
+}
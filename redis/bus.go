@@ -0,0 +1,82 @@
+package redis
+
+import (
+	"context"
+
+	eventbus "github.com/tclavelloux/promy-event-bus/eventbus"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Bus is a Publisher and a Subscriber sharing one Redis client, and so one connection
+// pool, for a service that both publishes and consumes. It routes failed events to the
+// DLQ and runs the Scheduler over the same pool; Client exposes it for admin tasks
+// (e.g., XINFO or XPENDING).
+//
+// Bus implements eventbus.EventPublisher and eventbus.EventSubscriber.
+type Bus struct {
+	*Publisher
+	*Subscriber
+
+	client     redis.UniversalClient
+	ownsClient bool
+}
+
+// NewBus connects to Redis once (see eventbus.RedisConfig for Sentinel, Cluster, TLS and
+// credentials) and returns a bus over that client. opts apply to both the publisher and
+// the subscriber.
+func NewBus(config eventbus.Config, opts ...Option) (*Bus, error) {
+	client, err := newClient(config.Redis, newOptions(opts))
+	if err != nil {
+		return nil, err
+	}
+
+	b := NewBusFromClient(client, config, opts...)
+	b.ownsClient = true
+
+	return b, nil
+}
+
+// NewBusFromClient returns a bus over an existing client, e.g. one the service also uses
+// as a cache. It does not ping Redis, and Close leaves client open.
+func NewBusFromClient(client redis.UniversalClient, config eventbus.Config, opts ...Option) *Bus {
+	return &Bus{
+		Publisher:  NewPublisherFromClient(client, config.Redis, opts...),
+		Subscriber: NewSubscriberFromClient(client, config, opts...),
+		client:     client,
+	}
+}
+
+// Subscribe is Subscriber.Subscribe, except that failed events of a subscription with a
+// DLQService and no DLQPublisher are routed to the DLQ by the bus publisher.
+func (b *Bus) Subscribe(ctx context.Context, subConfig eventbus.SubscriptionConfig) error {
+	if subConfig.DLQPublisher == nil && subConfig.DLQService != "" {
+		subConfig.DLQPublisher = b.Publisher
+	}
+
+	return b.Subscriber.Subscribe(ctx, subConfig)
+}
+
+// Scheduler returns a Scheduler publishing scheduled events through the bus publisher.
+func (b *Bus) Scheduler(config SchedulerConfig) *Scheduler {
+	return NewScheduler(b.Publisher, config)
+}
+
+// Client returns the shared Redis client.
+func (b *Bus) Client() redis.UniversalClient {
+	return b.client
+}
+
+// Health checks Redis connection health.
+func (b *Bus) Health(ctx context.Context) error {
+	return b.client.Ping(ctx).Err()
+}
+
+// Close closes the Redis connection, unless the client was provided by the caller.
+func (b *Bus) Close() error {
+	if !b.ownsClient {
+		return nil
+	}
+
+	return b.client.Close()
+}
@@ -0,0 +1,81 @@
+//nolint:all // Test file
+package redis_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	eventbus "github.com/tclavelloux/promy-event-bus/eventbus"
+	"github.com/tclavelloux/promy-event-bus/redis"
+	"github.com/tclavelloux/promy-event-bus/streams"
+	"github.com/tclavelloux/promy-event-bus/testutil"
+
+	goredis "github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBus_SharesClient(t *testing.T) {
+	const stream = "events:test-bus"
+
+	client := goredis.NewClient(&goredis.Options{Addr: "localhost:6379", DB: 1})
+	defer client.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	require.NoError(t, client.Del(ctx, stream).Err())
+
+	var bus interface {
+		eventbus.EventPublisher
+		eventbus.EventSubscriber
+	} = redis.NewBusFromClient(client, eventbus.Config{}, redis.WithIdempotentPublish(time.Minute))
+
+	failed := testutil.NewTestEvent("promotion.created", map[string]any{"promotion_id": "promo-1"})
+	handled := make(chan string, 3)
+	go bus.Subscribe(ctx, eventbus.SubscriptionConfig{
+		Stream:        stream,
+		ConsumerGroup: "test-bus-group",
+		ConsumerID:    "consumer-1",
+		Handler: func(_ context.Context, event eventbus.Event) error {
+			handled <- event.EventID()
+			return errors.New("always fails")
+		},
+		DLQService: "test-service",
+	})
+
+	time.Sleep(100 * time.Millisecond)
+	require.NoError(t, bus.Publish(ctx, stream, failed))
+
+	// Failed events reach the DLQ through the bus publisher
+	require.Eventually(t, func() bool {
+		entries, err := client.XRevRangeN(ctx, streams.StreamDLQ, "+", "-", 10).Result()
+		require.NoError(t, err)
+		for _, entry := range entries {
+			var dlq eventbus.DLQEntry
+			if json.Unmarshal([]byte(entry.Values["payload"].(string)), &dlq) == nil && dlq.OriginalEventID == failed.ID {
+				return true
+			}
+		}
+		return false
+	}, 5*time.Second, 50*time.Millisecond)
+
+	// The caller keeps ownership of the client
+	require.NoError(t, bus.Close())
+	assert.NoError(t, client.Ping(ctx).Err())
+}
+
+func TestBus_Close(t *testing.T) {
+	bus, err := redis.NewBus(eventbus.Config{Redis: eventbus.RedisConfig{DSN: "redis://localhost:6379/1"}})
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	require.NoError(t, bus.Health(ctx))
+	assert.Same(t, bus.Client(), bus.Client())
+
+	require.NoError(t, bus.Close())
+	assert.Error(t, bus.Health(ctx))
+	assert.Error(t, bus.Publisher.Health(ctx), "the publisher shares the closed client")
+}
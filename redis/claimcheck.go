@@ -0,0 +1,241 @@
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tclavelloux/promy-event-bus/claimcheck"
+	"github.com/tclavelloux/promy-event-bus/streams"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// metadataClaimCheck is the metadata field holding the blob key of a claim-checked payload.
+	metadataClaimCheck = "claim_check"
+
+	defaultClaimCheckThreshold = 64 << 10
+
+	// defaultClaimCheckSweepGrace keeps blobs for a while after the entry that referenced
+	// them was trimmed, for copies re-added to the stream by subscriber retries.
+	defaultClaimCheckSweepGrace = time.Hour
+)
+
+// claimCheck moves payload to the claim-check store if it reaches the threshold and
+// returns the blob key, or "" if payload stays in the stream. The key records the time
+// the payload reaches the stream, so blobs of scheduled events are not swept early.
+func (p *Publisher) claimCheck(
+	ctx context.Context, stream, eventID string, payload []byte, publishAt time.Time,
+) (string, error) {
+	if p.blobs == nil || len(payload) < p.claimCheckThreshold {
+		return "", nil
+	}
+
+	key := claimcheck.Key(stream, publishAt, eventID)
+	if err := p.blobs.Put(ctx, key, payload); err != nil {
+		return "", err
+	}
+
+	return key, nil
+}
+
+// deleteClaimChecks deletes the blobs of msgs, which no entry references because they
+// were not written. Failures are logged; SweepClaimChecks deletes such blobs later.
+func (p *Publisher) deleteClaimChecks(ctx context.Context, msgs ...streamMessage) {
+	keys := make([]string, 0, len(msgs))
+	for _, msg := range msgs {
+		if msg.claimKey != "" {
+			keys = append(keys, msg.claimKey)
+		}
+	}
+	if len(keys) == 0 {
+		return
+	}
+
+	if err := p.blobs.Delete(ctx, keys...); err != nil {
+		p.logger.WarnContext(ctx, "failed to delete claim-checked payloads of unpublished events",
+			slog.Any(logKeyError, err), slog.Any("claim_checks", keys))
+	}
+}
+
+// deleteDuplicateClaimCheck deletes the blob of msg, skipped as a duplicate of the entry
+// messageID of stream, unless that entry references the same blob: keys only differ by
+// the millisecond of publication.
+func (p *Publisher) deleteDuplicateClaimCheck(ctx context.Context, stream, messageID string, msg streamMessage) {
+	if msg.claimKey == "" {
+		return
+	}
+
+	original, err := p.client.XRangeN(ctx, stream, messageID, messageID, 1).Result()
+	if err != nil {
+		p.logger.WarnContext(ctx, "failed to read the original of a duplicate event, keeping its claim-checked payload",
+			slog.Any(logKeyError, err), slog.String(logKeyMessageID, messageID))
+
+		return
+	}
+	if len(original) > 0 {
+		var metadata struct {
+			ClaimCheck string `json:"claim_check"`
+		}
+		raw, _ := original[0].Values[fieldMetadata].(string)
+		if json.Unmarshal([]byte(raw), &metadata) == nil && metadata.ClaimCheck == msg.claimKey {
+			return
+		}
+	}
+
+	p.deleteClaimChecks(ctx, msg)
+}
+
+// SweepClaimChecks deletes the claim-checked payloads of stream that no entry can
+// reference anymore: those published before the oldest entry of the stream, minus a grace
+// period (see WithClaimCheckSweepGrace); for a partitioned stream, the oldest entry of any
+// partition counts.
+// Run it periodically, e.g. once per retention period fraction.
+// It returns the number of deleted blobs; without WithClaimCheck it does nothing.
+func (p *Publisher) SweepClaimChecks(ctx context.Context, stream string) (int, error) {
+	if p.blobs == nil {
+		return 0, nil
+	}
+
+	before := time.Now()
+
+	// Entries published before the stream was partitioned stay in the stream itself
+	streams := []string{stream}
+	if partitions := streamPartitions(p.config, stream); partitions > 1 {
+		for i := range partitions {
+			streams = append(streams, partitionStream(stream, i))
+		}
+	}
+
+	for _, name := range streams {
+		oldest, err := p.client.XRangeN(ctx, name, "-", "+", 1).Result()
+		if err != nil {
+			return 0, fmt.Errorf("failed to read oldest entry of %s: %w", name, err)
+		}
+		if len(oldest) > 0 {
+			if t, ok := streamIDTime(oldest[0].ID); ok && t.Before(before) {
+				before = t
+			}
+		}
+	}
+
+	referenced, err := p.dlqClaimChecks(ctx, stream)
+	if err != nil {
+		return 0, err
+	}
+
+	return claimcheck.SweepExcept(ctx, p.blobs, stream, before.Add(-p.sweepGrace(stream)), referenced)
+}
+
+// dlqPageSize is the number of DLQ entries read at once by dlqClaimChecks.
+const dlqPageSize = 500
+
+// dlqClaimChecks returns the blobs of stream referenced by DLQ entries, whose payload
+// could not be fetched, so that they can still be replayed. Entries that cannot be
+// decoded are skipped.
+func (p *Publisher) dlqClaimChecks(ctx context.Context, stream string) (map[string]bool, error) {
+	referenced := make(map[string]bool)
+
+	start := "-"
+	for {
+		msgs, err := p.client.XRangeN(ctx, streams.StreamDLQ, start, "+", dlqPageSize).Result()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", streams.StreamDLQ, err)
+		}
+
+		for _, msg := range msgs {
+			var entry struct {
+				ClaimCheck string `json:"original_claim_check"`
+			}
+			if payload, ok := p.dlqPayload(ctx, msg); ok && json.Unmarshal([]byte(payload), &entry) == nil &&
+				strings.HasPrefix(entry.ClaimCheck, stream+"/") {
+				referenced[entry.ClaimCheck] = true
+			}
+		}
+
+		if len(msgs) < dlqPageSize {
+			return referenced, nil
+		}
+		start = "(" + msgs[len(msgs)-1].ID
+	}
+}
+
+// dlqPayload returns the JSON payload of the DLQ message msg.
+func (p *Publisher) dlqPayload(ctx context.Context, msg redis.XMessage) (string, bool) {
+	var metadata struct {
+		Encoding    string `json:"encoding"`
+		ContentType string `json:"content_type"`
+		ClaimCheck  string `json:"claim_check"`
+	}
+	if raw, ok := msg.Values[fieldMetadata].(string); ok {
+		_ = json.Unmarshal([]byte(raw), &metadata)
+	}
+
+	payload, _ := msg.Values[fieldPayload].(string)
+	if metadata.ClaimCheck != "" {
+		blob, err := p.blobs.Get(ctx, metadata.ClaimCheck)
+		if err != nil {
+			return "", false
+		}
+		payload = string(blob)
+	}
+
+	payload, err := DecodePayload(metadata.Encoding, payload)
+	if err != nil {
+		return "", false
+	}
+
+	payload, err = payloadToJSON(nil, metadata.ContentType, "", payload)
+	if err != nil {
+		return "", false
+	}
+
+	return payload, true
+}
+
+// sweepGrace returns how long blobs of stream are kept before its oldest entry. An event
+// is read within the MaxAge retention of its stream, so its retries are re-added within
+// that time too.
+func (p *Publisher) sweepGrace(stream string) time.Duration {
+	if p.claimCheckSweepGrace > 0 {
+		return p.claimCheckSweepGrace
+	}
+
+	return max(defaultClaimCheckSweepGrace, streamRetention(p.config, stream).MaxAge)
+}
+
+// fetchClaimCheck returns the payload stored under key, or payload if key is empty or the
+// blob cannot be fetched.
+func (s *Subscriber) fetchClaimCheck(ctx context.Context, key, payload string) (string, error) {
+	if key == "" {
+		return payload, nil
+	}
+
+	if s.blobs == nil {
+		return payload, fmt.Errorf("failed to fetch claim-checked payload %s: no store configured (see WithClaimCheck)", key)
+	}
+
+	data, err := s.blobs.Get(ctx, key)
+	if err != nil {
+		return payload, fmt.Errorf("failed to fetch claim-checked payload %s: %w", key, err)
+	}
+
+	return string(data), nil
+}
+
+// streamIDTime returns the time encoded in a stream entry ID ("<ms>-<seq>").
+func streamIDTime(id string) (time.Time, bool) {
+	ms, _, _ := strings.Cut(id, "-")
+
+	n, err := strconv.ParseInt(ms, 10, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	return time.UnixMilli(n), true
+}
@@ -0,0 +1,346 @@
+//nolint:all // Test file
+package redis_test
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tclavelloux/promy-event-bus/claimcheck"
+	eventbus "github.com/tclavelloux/promy-event-bus/eventbus"
+	"github.com/tclavelloux/promy-event-bus/redis"
+	"github.com/tclavelloux/promy-event-bus/streams"
+	"github.com/tclavelloux/promy-event-bus/testutil"
+
+	goredis "github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClaimCheck_RoundTrip(t *testing.T) {
+	const stream = "events:test-claimcheck"
+
+	client := goredis.NewClient(&goredis.Options{Addr: "localhost:6379", DB: 1})
+	defer client.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	require.NoError(t, client.Del(ctx, stream).Err())
+
+	config := eventbus.Config{Redis: eventbus.RedisConfig{DSN: "redis://localhost:6379/1"}}
+	store, err := claimcheck.NewFileStore(t.TempDir())
+	require.NoError(t, err)
+
+	publisher, err := redis.NewPublisher(config.Redis, redis.WithClaimCheck(store, 1024))
+	require.NoError(t, err)
+	defer publisher.Close()
+
+	subscriber, err := redis.NewSubscriber(config, redis.WithClaimCheck(store, 1024))
+	require.NoError(t, err)
+	defer subscriber.Close()
+
+	received := make(chan eventbus.Event, 2)
+	go subscriber.Subscribe(ctx, eventbus.SubscriptionConfig{
+		Stream:        stream,
+		ConsumerGroup: "test-claimcheck-group",
+		ConsumerID:    "consumer-1",
+		Handler: func(_ context.Context, event eventbus.Event) error {
+			received <- event
+			return nil
+		},
+	})
+
+	time.Sleep(100 * time.Millisecond)
+
+	ocrText := strings.Repeat("lorem ipsum ", 200)
+	large := testutil.NewTestEvent("promotion.created", map[string]any{"promotion_id": "promo-1", "ocr_text": ocrText})
+	small := testutil.NewTestEvent("promotion.created", map[string]any{"promotion_id": "promo-2"})
+	require.NoError(t, publisher.Publish(ctx, stream, large))
+	require.NoError(t, publisher.Publish(ctx, stream, small))
+
+	messages, err := client.XRange(ctx, stream, "-", "+").Result()
+	require.NoError(t, err)
+	require.Len(t, messages, 2)
+
+	var metadata map[string]any
+	require.NoError(t, json.Unmarshal([]byte(messages[0].Values["metadata"].(string)), &metadata))
+	assert.True(t, strings.HasPrefix(metadata["claim_check"].(string), stream+"/"))
+	assert.Empty(t, messages[0].Values["payload"])
+
+	metadata = nil
+	require.NoError(t, json.Unmarshal([]byte(messages[1].Values["metadata"].(string)), &metadata))
+	assert.NotContains(t, metadata, "claim_check")
+	assert.NotEmpty(t, messages[1].Values["payload"])
+
+	for _, want := range []*testutil.TestEvent{large, small} {
+		select {
+		case got := <-received:
+			assert.Equal(t, want.ID, got.EventID())
+			var data map[string]any
+			require.NoError(t, json.Unmarshal([]byte(got.Data()), &data))
+			assert.Equal(t, want.Payload["promotion_id"], data["promotion_id"])
+			assert.Equal(t, want.Payload["ocr_text"], data["ocr_text"])
+		case <-ctx.Done():
+			t.Fatal("timed out waiting for event")
+		}
+	}
+}
+
+func TestSubscriber_MissingClaimCheck(t *testing.T) {
+	const stream = "events:test-claimcheck-missing"
+
+	client := goredis.NewClient(&goredis.Options{Addr: "localhost:6379", DB: 1})
+	defer client.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	require.NoError(t, client.Del(ctx, stream).Err())
+
+	config := eventbus.Config{Redis: eventbus.RedisConfig{DSN: "redis://localhost:6379/1"}}
+	store, err := claimcheck.NewFileStore(t.TempDir())
+	require.NoError(t, err)
+
+	subscriber, err := redis.NewSubscriber(config, redis.WithClaimCheck(store, 0))
+	require.NoError(t, err)
+	defer subscriber.Close()
+
+	dlq := &testutil.MockPublisher{}
+	dlqEntries := make(chan *eventbus.DLQEntry, 1)
+	dlq.On("Publish", mock.Anything, mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		dlqEntries <- args.Get(2).(*eventbus.DLQEntry)
+	}).Return(nil)
+
+	handled := make(chan struct{}, 1)
+	go subscriber.Subscribe(ctx, eventbus.SubscriptionConfig{
+		Stream:        stream,
+		ConsumerGroup: "test-claimcheck-missing-group",
+		ConsumerID:    "consumer-1",
+		Handler: func(_ context.Context, _ eventbus.Event) error {
+			handled <- struct{}{}
+			return nil
+		},
+		DLQPublisher: dlq,
+		DLQService:   "test-service",
+	})
+
+	time.Sleep(100 * time.Millisecond)
+
+	key := claimcheck.Key(stream, time.Now(), "evt-swept")
+	require.NoError(t, client.XAdd(ctx, &goredis.XAddArgs{
+		Stream: stream,
+		Values: map[string]any{
+			"metadata": `{"id":"evt-swept","type":"promotion.created","timestamp":"2026-10-18T17:31:00Z","version":"1.0","claim_check":"` + key + `"}`,
+			"payload":  "",
+		},
+	}).Err())
+
+	select {
+	case entry := <-dlqEntries:
+		assert.Equal(t, "evt-swept", entry.OriginalEventID)
+		assert.Contains(t, entry.FailureReason, key)
+		assert.Equal(t, key, entry.OriginalClaimCheck, "the DLQ entry references the blob")
+	case <-handled:
+		t.Fatal("handler called without payload")
+	case <-ctx.Done():
+		t.Fatal("timed out waiting for DLQ entry")
+	}
+}
+
+func TestPublisher_SweepClaimChecks(t *testing.T) {
+	const stream = "events:test-claimcheck-sweep"
+
+	client := goredis.NewClient(&goredis.Options{Addr: "localhost:6379", DB: 1})
+	defer client.Close()
+
+	ctx := context.Background()
+	require.NoError(t, client.Del(ctx, stream).Err())
+
+	store, err := claimcheck.NewFileStore(t.TempDir())
+	require.NoError(t, err)
+
+	publisher, err := redis.NewPublisher(eventbus.RedisConfig{DSN: "redis://localhost:6379/1"}, redis.WithClaimCheck(store, 1))
+	require.NoError(t, err)
+	defer publisher.Close()
+
+	// Blobs of entries trimmed long ago, and one still referenced by the stream
+	expired := claimcheck.Key(stream, time.Now().Add(-3*time.Hour), "evt-old")
+	require.NoError(t, store.Put(ctx, expired, []byte("{}")))
+	require.NoError(t, publisher.Publish(ctx, stream, testutil.NewTestEvent("promotion.created", map[string]any{})))
+
+	deleted, err := publisher.SweepClaimChecks(ctx, stream)
+	require.NoError(t, err)
+	assert.Equal(t, 1, deleted)
+
+	keys, err := store.List(ctx, stream+"/")
+	require.NoError(t, err)
+	require.Len(t, keys, 1)
+	assert.NotEqual(t, expired, keys[0])
+}
+
+func TestPublisher_SweepClaimChecksKeepsDeadLettered(t *testing.T) {
+	const stream = "events:test-claimcheck-sweep-dlq"
+
+	client := goredis.NewClient(&goredis.Options{Addr: "localhost:6379", DB: 1})
+	defer client.Close()
+
+	ctx := context.Background()
+	require.NoError(t, client.Del(ctx, stream).Err())
+
+	store, err := claimcheck.NewFileStore(t.TempDir())
+	require.NoError(t, err)
+
+	publisher, err := redis.NewPublisher(eventbus.RedisConfig{DSN: "redis://localhost:6379/1"}, redis.WithClaimCheck(store, 1))
+	require.NoError(t, err)
+	defer publisher.Close()
+
+	// The blob of an event dead-lettered because it could not be fetched in time
+	deadLettered := claimcheck.Key(stream, time.Now().Add(-3*time.Hour), "evt-dead-lettered")
+	require.NoError(t, store.Put(ctx, deadLettered, []byte("{}")))
+	dlqID, err := client.XAdd(ctx, &goredis.XAddArgs{
+		Stream: streams.StreamDLQ,
+		Values: map[string]any{
+			"metadata": `{"type":"dlq.promotion.created"}`,
+			"payload":  `{"original_stream":"` + stream + `","original_claim_check":"` + deadLettered + `"}`,
+		},
+	}).Result()
+	require.NoError(t, err)
+	t.Cleanup(func() { client.XDel(ctx, streams.StreamDLQ, dlqID) })
+
+	expired := claimcheck.Key(stream, time.Now().Add(-3*time.Hour), "evt-old")
+	require.NoError(t, store.Put(ctx, expired, []byte("{}")))
+	require.NoError(t, publisher.Publish(ctx, stream, testutil.NewTestEvent("promotion.created", map[string]any{})))
+
+	deleted, err := publisher.SweepClaimChecks(ctx, stream)
+	require.NoError(t, err)
+	assert.Equal(t, 1, deleted)
+
+	keys, err := store.List(ctx, stream+"/")
+	require.NoError(t, err)
+	assert.Len(t, keys, 2)
+	assert.Contains(t, keys, deadLettered)
+	assert.NotContains(t, keys, expired)
+}
+
+func TestPublisher_SweepClaimChecksGrace(t *testing.T) {
+	const stream = "events:test-claimcheck-sweep-grace"
+
+	client := goredis.NewClient(&goredis.Options{Addr: "localhost:6379", DB: 1})
+	defer client.Close()
+
+	ctx := context.Background()
+
+	sweep := func(t *testing.T, config eventbus.RedisConfig, opts ...redis.Option) []string {
+		require.NoError(t, client.Del(ctx, stream).Err())
+
+		store, err := claimcheck.NewFileStore(t.TempDir())
+		require.NoError(t, err)
+
+		publisher, err := redis.NewPublisher(config, append(opts, redis.WithClaimCheck(store, 1))...)
+		require.NoError(t, err)
+		defer publisher.Close()
+
+		// The blob of an event retried 3 hours after it was published
+		require.NoError(t, store.Put(ctx, claimcheck.Key(stream, time.Now().Add(-3*time.Hour), "evt-retried"), []byte("{}")))
+		require.NoError(t, publisher.Publish(ctx, stream, testutil.NewTestEvent("promotion.created", map[string]any{})))
+
+		_, err = publisher.SweepClaimChecks(ctx, stream)
+		require.NoError(t, err)
+
+		keys, err := store.List(ctx, stream+"/")
+		require.NoError(t, err)
+		return keys
+	}
+
+	t.Run("grace follows MaxAge retention", func(t *testing.T) {
+		keys := sweep(t, eventbus.RedisConfig{
+			DSN:       "redis://localhost:6379/1",
+			Retention: map[string]eventbus.StreamRetention{stream: {MaxAge: 4 * time.Hour}},
+		})
+		assert.Len(t, keys, 2)
+	})
+
+	t.Run("configured grace", func(t *testing.T) {
+		keys := sweep(t, eventbus.RedisConfig{DSN: "redis://localhost:6379/1"}, redis.WithClaimCheckSweepGrace(4*time.Hour))
+		assert.Len(t, keys, 2)
+
+		keys = sweep(t, eventbus.RedisConfig{DSN: "redis://localhost:6379/1"}, redis.WithClaimCheckSweepGrace(2*time.Hour))
+		assert.Len(t, keys, 1)
+	})
+}
+
+func TestPublisher_DeletesUnpublishedClaimChecks(t *testing.T) {
+	const stream = "events:test-claimcheck-unpublished"
+
+	client := goredis.NewClient(&goredis.Options{Addr: "localhost:6379", DB: 1})
+	defer client.Close()
+
+	ctx := context.Background()
+
+	newPublisher := func(t *testing.T) (*redis.Publisher, claimcheck.Store) {
+		require.NoError(t, client.Del(ctx, stream, "{"+stream+"}:dedup:evt-dup").Err())
+
+		store, err := claimcheck.NewFileStore(t.TempDir())
+		require.NoError(t, err)
+
+		publisher, err := redis.NewPublisher(eventbus.RedisConfig{DSN: "redis://localhost:6379/1"},
+			redis.WithClaimCheck(store, 1), redis.WithIdempotentPublish(time.Minute))
+		require.NoError(t, err)
+		t.Cleanup(func() { publisher.Close() })
+
+		return publisher, store
+	}
+
+	blobs := func(t *testing.T, store claimcheck.Store) []string {
+		keys, err := store.List(ctx, stream+"/")
+		require.NoError(t, err)
+		return keys
+	}
+
+	t.Run("rejected batch", func(t *testing.T) {
+		publisher, store := newPublisher(t)
+		require.NoError(t, client.Set(ctx, stream, "not a stream", 0).Err())
+		defer client.Del(ctx, stream)
+
+		err := publisher.PublishBatch(ctx, stream, []eventbus.Event{
+			testutil.NewTestEvent("promotion.created", map[string]any{}),
+			testutil.NewTestEvent("promotion.created", map[string]any{}),
+		})
+		var batchErr *eventbus.BatchError
+		require.ErrorAs(t, err, &batchErr)
+		assert.Empty(t, blobs(t, store))
+	})
+
+	t.Run("duplicates", func(t *testing.T) {
+		publisher, store := newPublisher(t)
+
+		event := testutil.NewTestEvent("promotion.created", map[string]any{})
+		event.ID = "evt-dup"
+		require.NoError(t, publisher.Publish(ctx, stream, event))
+
+		time.Sleep(2 * time.Millisecond)
+		result, err := publisher.PublishWithResult(ctx, stream, event)
+		require.NoError(t, err)
+		require.True(t, result.Duplicate)
+
+		time.Sleep(2 * time.Millisecond)
+		require.NoError(t, publisher.PublishBatch(ctx, stream, []eventbus.Event{event}))
+
+		keys := blobs(t, store)
+		require.Len(t, keys, 1, "blobs of duplicates are deleted")
+
+		// A duplicate published in the same millisecond shares the blob of its original
+		require.NoError(t, client.Del(ctx, stream, "{"+stream+"}:dedup:evt-dup").Err())
+		require.NoError(t, store.Delete(ctx, keys...))
+		require.NoError(t, publisher.PublishBatch(ctx, stream, []eventbus.Event{event, event}))
+
+		keys = blobs(t, store)
+		require.Len(t, keys, 1)
+		messages, err := client.XRange(ctx, stream, "-", "+").Result()
+		require.NoError(t, err)
+		require.Len(t, messages, 1)
+		assert.Contains(t, messages[0].Values["metadata"], keys[0])
+	})
+}
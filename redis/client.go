@@ -0,0 +1,148 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	eventbus "github.com/tclavelloux/promy-event-bus/eventbus"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// newClient builds the Redis client described by config: a Sentinel-backed failover
+// client when MasterName is set, a cluster client when Cluster is set or Addrs holds
+// several addresses, and a single-node client otherwise. It pings Redis before returning.
+//
+// Credentials come from the provider of o (see WithCredentialsProvider), else from the
+// files of config, read for every new connection, else they are static.
+func newClient(config eventbus.RedisConfig, o options) (redis.UniversalClient, error) {
+	opts, err := universalOptions(config)
+	if err != nil {
+		return nil, err
+	}
+
+	provider := o.credentials
+	if provider == nil && (config.UsernameFile != "" || config.PasswordFile != "") {
+		provider = fileCredentials(config, opts.Username, opts.Password)
+	}
+
+	var refresh func() (string, string)
+	if provider != nil {
+		refresh = (&credentials{provider: provider, logger: o.logger}).get
+	}
+
+	var client redis.UniversalClient
+	switch {
+	case opts.MasterName != "":
+		failover := redis.NewFailoverClient(opts.Failover())
+		failover.Options().CredentialsProvider = refresh
+		client = failover
+	case config.Cluster || len(opts.Addrs) > 1:
+		if opts.DB != 0 {
+			return nil, fmt.Errorf("invalid Redis config: Redis Cluster only supports DB 0, got %d", opts.DB)
+		}
+
+		clusterOpts := opts.Cluster()
+		clusterOpts.NewClient = func(nodeOpts *redis.Options) *redis.Client {
+			nodeOpts.CredentialsProvider = refresh
+			return redis.NewClient(nodeOpts)
+		}
+		client = redis.NewClusterClient(clusterOpts)
+	default:
+		simple := opts.Simple()
+		simple.CredentialsProvider = refresh
+		client = redis.NewClient(simple)
+	}
+
+	// Test connection
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := client.Ping(ctx).Err(); err != nil {
+		_ = client.Close()
+
+		return nil, fmt.Errorf("failed to connect to Redis: %w", err)
+	}
+
+	return client, nil
+}
+
+// universalOptions maps config to client options. DSN provides the credentials, DB,
+// TLS and query options, and the address unless Addrs is set; the other fields of
+// config override them. Credential files are left to newClient.
+func universalOptions(config eventbus.RedisConfig) (*redis.UniversalOptions, error) {
+	opts := &redis.UniversalOptions{
+		Addrs:            config.Addrs,
+		MasterName:       config.MasterName,
+		SentinelPassword: config.SentinelPassword,
+	}
+
+	switch {
+	case config.DSN != "":
+		parsed, err := redis.ParseURL(config.DSN)
+		if err != nil {
+			return nil, fmt.Errorf("invalid Redis DSN: %w", err)
+		}
+
+		if len(opts.Addrs) == 0 {
+			opts.Addrs = []string{parsed.Addr}
+		}
+
+		opts.ClientName = parsed.ClientName
+		opts.Protocol = parsed.Protocol
+		opts.Username = parsed.Username
+		opts.Password = parsed.Password
+		opts.DB = parsed.DB
+		opts.TLSConfig = parsed.TLSConfig
+		opts.MaxRetries = parsed.MaxRetries
+		opts.MinRetryBackoff = parsed.MinRetryBackoff
+		opts.MaxRetryBackoff = parsed.MaxRetryBackoff
+		opts.DialTimeout = parsed.DialTimeout
+		opts.ReadTimeout = parsed.ReadTimeout
+		opts.WriteTimeout = parsed.WriteTimeout
+		opts.PoolFIFO = parsed.PoolFIFO
+		opts.PoolSize = parsed.PoolSize
+		opts.PoolTimeout = parsed.PoolTimeout
+		opts.MinIdleConns = parsed.MinIdleConns
+		opts.MaxIdleConns = parsed.MaxIdleConns
+		opts.ConnMaxIdleTime = parsed.ConnMaxIdleTime
+		opts.ConnMaxLifetime = parsed.ConnMaxLifetime
+	case len(opts.Addrs) == 0:
+		return nil, errors.New("invalid Redis config: set DSN or Addrs")
+	}
+
+	opts.Username, opts.Password = staticCredentials(config, opts.Username, opts.Password)
+
+	tlsCfg, err := tlsConfig(config.TLS, opts.TLSConfig)
+	if err != nil {
+		return nil, err
+	}
+	opts.TLSConfig = tlsCfg
+
+	// Apply configuration
+	if config.PoolSize > 0 {
+		opts.PoolSize = config.PoolSize
+	}
+	if config.MaxRetries > 0 {
+		opts.MaxRetries = config.MaxRetries
+	}
+	if config.MinRetryBackoff > 0 {
+		opts.MinRetryBackoff = config.MinRetryBackoff
+	}
+	if config.MaxRetryBackoff > 0 {
+		opts.MaxRetryBackoff = config.MaxRetryBackoff
+	}
+	if config.DialTimeout > 0 {
+		opts.DialTimeout = config.DialTimeout
+	}
+	if config.ReadTimeout > 0 {
+		opts.ReadTimeout = config.ReadTimeout
+	}
+	if config.WriteTimeout > 0 {
+		opts.WriteTimeout = config.WriteTimeout
+	}
+
+	return opts, nil
+}
@@ -0,0 +1,143 @@
+package redis
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/tclavelloux/promy-event-bus/cloudevents"
+	"github.com/tclavelloux/promy-event-bus/codec"
+	eventbus "github.com/tclavelloux/promy-event-bus/eventbus"
+)
+
+// wrapCloudEvent encodes payload, encoded as contentType, as a structured CloudEvent for event.
+// headers become extensions, next to the version.
+func (p *Publisher) wrapCloudEvent(
+	event eventbus.Event, contentType string, payload []byte, headers eventbus.Headers,
+) ([]byte, error) {
+	ce, err := cloudevents.NewWithData(event, p.cloudEventsSource, contentType, payload)
+	if err != nil {
+		return nil, err
+	}
+
+	for name, value := range cloudevents.ExtensionsFromHeaders(headers) {
+		ce.Extensions[name] = value
+	}
+
+	return json.Marshal(ce)
+}
+
+// unwrapCloudEvent returns the data of a structured CloudEvent and its content type.
+func unwrapCloudEvent(payload string) (data, contentType string, err error) {
+	ce, err := cloudevents.Parse([]byte(payload))
+	if err != nil {
+		return "", "", err
+	}
+
+	if ce.HasJSONData() {
+		return string(ce.Data), codec.ContentTypeJSON, nil
+	}
+
+	return string(ce.Data), ce.DataContentType, nil
+}
+
+// cloudEventMetadata builds the metadata of a message that holds a structured CloudEvent
+// in its payload field and has no metadata field, as written by producers outside the bus.
+func cloudEventMetadata(values map[string]any) (string, bool) {
+	payload, ok := values[fieldPayload].(string)
+	if !ok {
+		return "", false
+	}
+
+	ce, err := cloudevents.Parse([]byte(payload))
+	if err != nil {
+		return "", false
+	}
+
+	metadata, err := json.Marshal(newMetadata(ce.ID, ce.Type, ce.Version(), ce.Time, ce.Headers(), cloudevents.ContentType))
+	if err != nil {
+		return "", false
+	}
+
+	return string(metadata), true
+}
+
+// MessageToCloudEvent converts the values of a stream message in the native format
+// (metadata and payload fields) to a CloudEvent. Native messages do not record their
+// producer, so source is used as CloudEvent source. Compressed payloads are decompressed;
+// payloads of other codecs keep their encoding and content type.
+func MessageToCloudEvent(values map[string]any, source string) (*cloudevents.Event, error) {
+	payload, ok := values[fieldPayload].(string)
+	if !ok {
+		return nil, errors.New("message has no payload field")
+	}
+
+	metadataStr, ok := values[fieldMetadata].(string)
+	if !ok {
+		// Already a structured CloudEvent
+		return cloudevents.Parse([]byte(payload))
+	}
+
+	var metadata struct {
+		ID          string           `json:"id"`
+		Type        string           `json:"type"`
+		Version     string           `json:"version"`
+		Timestamp   string           `json:"timestamp"`
+		Headers     eventbus.Headers `json:"headers"`
+		Encoding    string           `json:"encoding"`
+		ContentType string           `json:"content_type"`
+	}
+	if err := json.Unmarshal([]byte(metadataStr), &metadata); err != nil {
+		return nil, fmt.Errorf("invalid metadata: %w", err)
+	}
+
+	payload, err := DecodePayload(metadata.Encoding, payload)
+	if err != nil {
+		return nil, err
+	}
+
+	if metadata.ContentType == cloudevents.ContentType {
+		return cloudevents.Parse([]byte(payload))
+	}
+
+	contentType := metadata.ContentType
+	if contentType == "" {
+		contentType = codec.ContentTypeJSON
+	}
+
+	ce := &cloudevents.Event{
+		SpecVersion:     cloudevents.SpecVersion,
+		ID:              metadata.ID,
+		Source:          source,
+		Type:            metadata.Type,
+		Time:            parseTime(metadata.Timestamp),
+		DataContentType: contentType,
+		Data:            []byte(payload),
+		Extensions:      cloudevents.ExtensionsFromHeaders(metadata.Headers),
+	}
+	if metadata.Version != "" {
+		ce.SetVersion(metadata.Version)
+	}
+
+	return ce, ce.Validate()
+}
+
+// CloudEventToMessage converts a CloudEvent to the values of a stream message in the
+// native format, e.g. to add an event received from a partner to a stream with XADD.
+func CloudEventToMessage(ce *cloudevents.Event) (map[string]any, error) {
+	if err := ce.Validate(); err != nil {
+		return nil, err
+	}
+
+	contentType := ce.DataContentType
+	if ce.HasJSONData() {
+		contentType = codec.ContentTypeJSON
+	}
+
+	metadata, err := json.Marshal(newMetadata(ce.ID, ce.Type, ce.Version(), ce.Time, ce.Headers(), contentType))
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal metadata: %w", err)
+	}
+
+	return streamMessage{metadata: string(metadata), payload: string(ce.Data)}.values(), nil
+}
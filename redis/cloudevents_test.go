@@ -0,0 +1,173 @@
+//nolint:all // Test file
+package redis_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/tclavelloux/promy-event-bus/cloudevents"
+	"github.com/tclavelloux/promy-event-bus/codec"
+	eventbus "github.com/tclavelloux/promy-event-bus/eventbus"
+	"github.com/tclavelloux/promy-event-bus/redis"
+	"github.com/tclavelloux/promy-event-bus/testutil"
+
+	goredis "github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPublisher_CloudEvents(t *testing.T) {
+	const stream = "events:test-cloudevents-publish"
+
+	client := goredis.NewClient(&goredis.Options{Addr: "localhost:6379", DB: 1})
+	defer client.Close()
+
+	ctx := context.Background()
+	require.NoError(t, client.Del(ctx, stream).Err())
+
+	publisher, err := redis.NewPublisher(
+		eventbus.RedisConfig{DSN: "redis://localhost:6379/1"},
+		redis.WithCloudEvents("https://promy.fr/promy-user"),
+	)
+	require.NoError(t, err)
+	defer publisher.Close()
+
+	event := testutil.NewTestEvent("user.registered", map[string]any{"user_id": "u-1"})
+	event.Source = ""
+	require.NoError(t, publisher.Publish(eventbus.WithCorrelationID(ctx, "corr-1"), stream, event))
+
+	messages, err := client.XRange(ctx, stream, "-", "+").Result()
+	require.NoError(t, err)
+	require.Len(t, messages, 1)
+
+	ce, err := cloudevents.Parse([]byte(messages[0].Values["payload"].(string)))
+	require.NoError(t, err)
+	assert.Equal(t, event.ID, ce.ID)
+	assert.Equal(t, "user.registered", ce.Type)
+	assert.Equal(t, "https://promy.fr/promy-user", ce.Source)
+	assert.Equal(t, codec.ContentTypeJSON, ce.DataContentType)
+	assert.Equal(t, "corr-1", ce.Extensions["correlationid"])
+
+	// The conversion helper returns the CloudEvent as published
+	converted, err := redis.MessageToCloudEvent(messages[0].Values, "unused")
+	require.NoError(t, err)
+	assert.Equal(t, ce, converted)
+}
+
+func TestSubscriber_AcceptsCloudEvents(t *testing.T) {
+	const stream = "events:test-cloudevents-subscribe"
+
+	client := goredis.NewClient(&goredis.Options{Addr: "localhost:6379", DB: 1})
+	defer client.Close()
+
+	config := eventbus.Config{Redis: eventbus.RedisConfig{DSN: "redis://localhost:6379/1"}}
+
+	subscriber, err := redis.NewSubscriber(config)
+	require.NoError(t, err)
+	defer subscriber.Close()
+
+	received := make(chan eventbus.Event, 2)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	go subscriber.Subscribe(ctx, eventbus.SubscriptionConfig{
+		Stream:        stream,
+		ConsumerGroup: "test-cloudevents-group",
+		ConsumerID:    "consumer-1",
+		Handler: func(_ context.Context, event eventbus.Event) error {
+			received <- event
+			return nil
+		},
+	})
+
+	time.Sleep(100 * time.Millisecond)
+
+	// Published by the bus in CloudEvents mode with a binary codec
+	publisher, err := redis.NewPublisher(config.Redis, redis.WithCloudEvents("promy-user"), redis.WithCodec(codec.MsgPack))
+	require.NoError(t, err)
+	defer publisher.Close()
+
+	published := testutil.NewTestEvent("user.registered", map[string]any{"user_id": "u-1"})
+	require.NoError(t, publisher.Publish(ctx, stream, published))
+
+	// Written by a partner: a structured CloudEvent without bus metadata
+	require.NoError(t, client.XAdd(ctx, &goredis.XAddArgs{
+		Stream: stream,
+		Values: map[string]any{"payload": `{
+			"specversion": "1.0",
+			"id": "retailer-1",
+			"source": "https://retailer.example",
+			"type": "com.retailer.promotion.published",
+			"time": "2026-10-18T17:31:00Z",
+			"correlationid": "corr-1",
+			"data": {"sku": "123"}
+		}`},
+	}).Err())
+
+	select {
+	case got := <-received:
+		assert.Equal(t, published.ID, got.EventID())
+		var data map[string]any
+		require.NoError(t, json.Unmarshal([]byte(got.Data()), &data))
+		assert.Equal(t, "u-1", data["user_id"])
+	case <-ctx.Done():
+		t.Fatal("timed out waiting for published event")
+	}
+
+	select {
+	case got := <-received:
+		assert.Equal(t, "retailer-1", got.EventID())
+		assert.Equal(t, "com.retailer.promotion.published", got.EventType())
+		assert.Equal(t, time.Date(2026, 10, 18, 17, 31, 0, 0, time.UTC), got.EventTime())
+		assert.Equal(t, "corr-1", eventbus.EventHeaders(got).CorrelationID())
+		assert.Equal(t, eventbus.DefaultEventVersion, eventbus.EventVersion(got))
+		assert.JSONEq(t, `{"sku":"123"}`, got.Data())
+	case <-ctx.Done():
+		t.Fatal("timed out waiting for partner event")
+	}
+}
+
+func TestCloudEventToMessage(t *testing.T) {
+	const stream = "events:test-cloudevents-convert"
+
+	client := goredis.NewClient(&goredis.Options{Addr: "localhost:6379", DB: 1})
+	defer client.Close()
+
+	ctx := context.Background()
+	require.NoError(t, client.Del(ctx, stream).Err())
+
+	ce := &cloudevents.Event{
+		SpecVersion: cloudevents.SpecVersion,
+		ID:          "retailer-1",
+		Source:      "https://retailer.example",
+		Type:        "com.retailer.promotion.published",
+		Time:        time.Date(2026, 10, 18, 17, 31, 0, 0, time.UTC),
+		Data:        []byte(`{"sku":"123"}`),
+		Extensions:  map[string]string{"correlationid": "corr-1", cloudevents.VersionExtension: "2.0"},
+	}
+
+	values, err := redis.CloudEventToMessage(ce)
+	require.NoError(t, err)
+	require.NoError(t, client.XAdd(ctx, &goredis.XAddArgs{Stream: stream, Values: values}).Err())
+
+	messages, err := client.XRange(ctx, stream, "-", "+").Result()
+	require.NoError(t, err)
+	require.Len(t, messages, 1)
+
+	var metadata map[string]any
+	require.NoError(t, json.Unmarshal([]byte(messages[0].Values["metadata"].(string)), &metadata))
+	assert.Equal(t, "retailer-1", metadata["id"])
+	assert.Equal(t, "com.retailer.promotion.published", metadata["type"])
+	assert.Equal(t, "2.0", metadata["version"])
+	assert.Equal(t, "corr-1", metadata["headers"].(map[string]any)["correlation_id"])
+	assert.Equal(t, `{"sku":"123"}`, messages[0].Values["payload"])
+
+	back, err := redis.MessageToCloudEvent(messages[0].Values, "https://retailer.example")
+	require.NoError(t, err)
+	assert.Equal(t, ce.ID, back.ID)
+	assert.Equal(t, ce.Time, back.Time)
+	assert.Equal(t, ce.Extensions, back.Extensions)
+	assert.JSONEq(t, string(ce.Data), string(back.Data))
+}
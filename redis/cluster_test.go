@@ -0,0 +1,69 @@
+//nolint:all // Test file
+package redis_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	eventbus "github.com/tclavelloux/promy-event-bus/eventbus"
+	"github.com/tclavelloux/promy-event-bus/redis"
+	"github.com/tclavelloux/promy-event-bus/testutil"
+
+	goredis "github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCluster_PublishSubscribe(t *testing.T) {
+	const stream = "events:test-cluster"
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	client := goredis.NewClusterClient(&goredis.ClusterOptions{Addrs: []string{"localhost:6379"}})
+	defer client.Close()
+
+	if err := client.ClusterSlots(ctx).Err(); err != nil {
+		t.Skipf("Redis Cluster not available: %v", err)
+	}
+	require.NoError(t, client.Del(ctx, stream).Err())
+
+	config := eventbus.Config{Redis: eventbus.RedisConfig{DSN: "redis://localhost:6379/0", Cluster: true}}
+
+	publisher, err := redis.NewPublisher(config.Redis, redis.WithIdempotentPublish(time.Minute))
+	require.NoError(t, err)
+	defer publisher.Close()
+
+	subscriber, err := redis.NewSubscriber(config)
+	require.NoError(t, err)
+	defer subscriber.Close()
+
+	received := make(chan eventbus.Event, 2)
+	go subscriber.Subscribe(ctx, eventbus.SubscriptionConfig{
+		Stream:        stream,
+		ConsumerGroup: "test-cluster-group",
+		ConsumerID:    "consumer-1",
+		Handler: func(_ context.Context, event eventbus.Event) error {
+			received <- event
+			return nil
+		},
+	})
+
+	time.Sleep(100 * time.Millisecond)
+
+	event := testutil.NewTestEvent("user.registered", map[string]any{"user_id": "user-1"})
+	first, err := publisher.PublishWithResult(ctx, stream, event)
+	require.NoError(t, err)
+	again, err := publisher.PublishWithResult(ctx, stream, event)
+	require.NoError(t, err)
+	assert.True(t, again.Duplicate)
+	assert.Equal(t, first.MessageID, again.MessageID)
+
+	select {
+	case got := <-received:
+		assert.Equal(t, event.ID, got.EventID())
+	case <-ctx.Done():
+		t.Fatal("timed out waiting for event")
+	}
+}
@@ -0,0 +1,50 @@
+package redis
+
+import (
+	"github.com/tclavelloux/promy-event-bus/cloudevents"
+	"github.com/tclavelloux/promy-event-bus/codec"
+)
+
+// metadataContentType is the metadata field naming the payload codec.
+// Messages without it are JSON.
+const metadataContentType = "content_type"
+
+// codecFor returns the codec of payloads published to stream.
+func (p *Publisher) codecFor(stream string) codec.Codec {
+	if c, ok := p.streamCodecs[stream]; ok {
+		return c
+	}
+
+	return p.codec
+}
+
+// payloadToJSON converts payload to JSON with the codec of contentType: one registered
+// with WithCodec or WithStreamCodec, or a built-in one.
+// Structured CloudEvents are unwrapped first.
+func payloadToJSON(codecs map[string]codec.Codec, contentType, eventType, payload string) (string, error) {
+	if contentType == cloudevents.ContentType {
+		data, dataContentType, err := unwrapCloudEvent(payload)
+		if err != nil {
+			return "", err
+		}
+
+		payload, contentType = data, dataContentType
+	}
+
+	c, ok := codecs[contentType]
+	if !ok {
+		builtin, err := codec.Builtin(contentType)
+		if err != nil {
+			return "", err
+		}
+
+		c = builtin
+	}
+
+	data, err := c.ToJSON(eventType, []byte(payload))
+	if err != nil {
+		return "", err
+	}
+
+	return string(data), nil
+}
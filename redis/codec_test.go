@@ -0,0 +1,240 @@
+//nolint:all // Test file
+package redis_test
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/tclavelloux/promy-event-bus/codec"
+	"github.com/tclavelloux/promy-event-bus/dlq"
+	eventbus "github.com/tclavelloux/promy-event-bus/eventbus"
+	"github.com/tclavelloux/promy-event-bus/redis"
+	"github.com/tclavelloux/promy-event-bus/streams"
+	"github.com/tclavelloux/promy-event-bus/testutil"
+
+	goredis "github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+type protoTestEvent struct {
+	*testutil.TestEvent
+}
+
+func (e protoTestEvent) ToProto() proto.Message {
+	message, _ := structpb.NewStruct(e.Payload)
+
+	return message
+}
+
+func TestPublisher_StreamCodec(t *testing.T) {
+	const (
+		binaryStream = "events:test-codec-msgpack"
+		jsonStream   = "events:test-codec-json"
+	)
+
+	client := goredis.NewClient(&goredis.Options{Addr: "localhost:6379", DB: 1})
+	defer client.Close()
+
+	ctx := context.Background()
+	require.NoError(t, client.Del(ctx, binaryStream, jsonStream).Err())
+
+	publisher, err := redis.NewPublisher(
+		eventbus.RedisConfig{DSN: "redis://localhost:6379/1"},
+		redis.WithStreamCodec(binaryStream, codec.MsgPack),
+	)
+	require.NoError(t, err)
+	defer publisher.Close()
+
+	event := testutil.NewTestEvent("user.location.updated", map[string]any{"user_id": "u-1", "lat": 48.8566})
+	require.NoError(t, publisher.Publish(ctx, binaryStream, event))
+	require.NoError(t, publisher.Publish(ctx, jsonStream, event))
+
+	for stream, contentType := range map[string]string{
+		binaryStream: codec.ContentTypeMsgPack,
+		jsonStream:   codec.ContentTypeJSON,
+	} {
+		messages, err := client.XRange(ctx, stream, "-", "+").Result()
+		require.NoError(t, err)
+		require.Len(t, messages, 1)
+
+		var metadata map[string]any
+		require.NoError(t, json.Unmarshal([]byte(messages[0].Values["metadata"].(string)), &metadata))
+		assert.Equal(t, contentType, metadata["content_type"])
+
+		payload := messages[0].Values["payload"].(string)
+		assert.Equal(t, contentType == codec.ContentTypeJSON, json.Valid([]byte(payload)))
+	}
+}
+
+func TestSubscriber_DecodesContentTypes(t *testing.T) {
+	const stream = "events:test-codec-decode"
+
+	config := eventbus.Config{Redis: eventbus.RedisConfig{DSN: "redis://localhost:6379/1"}}
+	pb := codec.NewProtobuf(map[string]proto.Message{"user.location.updated": &structpb.Struct{}})
+
+	// Only the Protobuf codec needs to be registered; JSON and MessagePack are built in.
+	subscriber, err := redis.NewSubscriber(config, redis.WithStreamCodec(stream, pb))
+	require.NoError(t, err)
+	defer subscriber.Close()
+
+	received := make(chan eventbus.Event, 3)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	go subscriber.Subscribe(ctx, eventbus.SubscriptionConfig{
+		Stream:        stream,
+		ConsumerGroup: "test-codec-group",
+		ConsumerID:    "consumer-1",
+		Handler: func(_ context.Context, event eventbus.Event) error {
+			received <- event
+			return nil
+		},
+	})
+
+	time.Sleep(100 * time.Millisecond)
+
+	payload := map[string]any{"user_id": "u-1", "lat": 48.8566}
+	for _, c := range []codec.Codec{codec.JSON, codec.MsgPack, pb} {
+		publisher, err := redis.NewPublisher(config.Redis, redis.WithCodec(c))
+		require.NoError(t, err)
+		defer publisher.Close()
+
+		event := protoTestEvent{testutil.NewTestEvent("user.location.updated", payload)}
+		require.NoError(t, publisher.Publish(ctx, stream, event))
+
+		select {
+		case got := <-received:
+			var data map[string]any
+			require.NoError(t, json.Unmarshal([]byte(got.Data()), &data), c.ContentType())
+			assert.Equal(t, "u-1", data["user_id"], c.ContentType())
+			assert.Equal(t, 48.8566, data["lat"], c.ContentType())
+		case <-ctx.Done():
+			t.Fatalf("timed out waiting for %s event", c.ContentType())
+		}
+	}
+}
+
+func TestSubscriber_DeadLettersUndecodablePayloads(t *testing.T) {
+	const stream = "events:test-codec-undecodable"
+
+	client := goredis.NewClient(&goredis.Options{Addr: "localhost:6379", DB: 1})
+	defer client.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(t, client.Del(ctx, stream).Err())
+
+	subscriber, err := redis.NewSubscriber(eventbus.Config{Redis: eventbus.RedisConfig{DSN: "redis://localhost:6379/1"}})
+	require.NoError(t, err)
+	defer subscriber.Close()
+
+	dlq := &testutil.MockPublisher{}
+	dlqEntries := make(chan *eventbus.DLQEntry, 1)
+	dlq.On("Publish", mock.Anything, mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		dlqEntries <- args.Get(2).(*eventbus.DLQEntry)
+	}).Return(nil)
+
+	handled := make(chan eventbus.Event, 1)
+	go subscriber.Subscribe(ctx, eventbus.SubscriptionConfig{
+		Stream:        stream,
+		ConsumerGroup: "test-codec-undecodable-group",
+		ConsumerID:    "consumer-1",
+		Handler: func(_ context.Context, event eventbus.Event) error {
+			handled <- event
+			return nil
+		},
+		DLQPublisher: dlq,
+		DLQService:   "test-service",
+	})
+
+	time.Sleep(100 * time.Millisecond)
+
+	// Not valid UTF-8, so it would be corrupted as a JSON string
+	const opaque = "\xff\xfe\x00opaque"
+	metadata := `{"id":"evt-1","type":"user.location.updated","version":"1.0","attempt":1,"content_type":"application/x-unknown"}`
+	require.NoError(t, client.XAdd(ctx, &goredis.XAddArgs{
+		Stream: stream,
+		Values: map[string]any{"metadata": metadata, "payload": opaque},
+	}).Err())
+
+	var entry *eventbus.DLQEntry
+	select {
+	case entry = <-dlqEntries:
+		assert.Equal(t, "evt-1", entry.OriginalEventID)
+		assert.Equal(t, base64.StdEncoding.EncodeToString([]byte(opaque)), entry.OriginalPayload)
+		assert.True(t, entry.OriginalPayloadBase64)
+		assert.Equal(t, "application/x-unknown", entry.OriginalContentType)
+		assert.Equal(t, 1, entry.AttemptsExhausted, "undecodable payloads are not retried")
+		assert.Contains(t, entry.FailureReason, "undecodable payload")
+	case <-ctx.Done():
+		t.Fatal("timed out waiting for DLQ entry")
+	}
+
+	select {
+	case event := <-handled:
+		t.Fatalf("handler called with undecodable event %s", event.EventID())
+	default:
+	}
+}
+
+func TestDLQ_ReplaysUndecodablePayloadsAsStored(t *testing.T) {
+	const (
+		stream = "events:test-codec-undecodable-replay"
+		opaque = "\xff\xfe\x00opaque"
+	)
+
+	client := goredis.NewClient(&goredis.Options{Addr: "localhost:6379", DB: 1})
+	defer client.Close()
+
+	ctx := context.Background()
+	require.NoError(t, client.Del(ctx, stream).Err())
+
+	event := &testutil.TestEvent{ID: "evt-1", Type: "user.location.updated", Payload: map[string]any{}}
+	entry := eventbus.NewDLQEntry(stream, &undecodedEvent{TestEvent: event, stored: opaque}, errors.New("undecodable payload"), "test-service", 1)
+	msgID, err := client.XAdd(ctx, &goredis.XAddArgs{
+		Stream: streams.StreamDLQ,
+		Values: map[string]any{"metadata": `{"type":"dlq.user.location.updated"}`, "payload": entry.Data()},
+	}).Result()
+	require.NoError(t, err)
+	t.Cleanup(func() { client.XDel(ctx, streams.StreamDLQ, msgID) })
+
+	msgs, err := client.XRange(ctx, streams.StreamDLQ, msgID, msgID).Result()
+	require.NoError(t, err)
+	require.Len(t, msgs, 1)
+
+	parsed, err := dlq.Parse(ctx, msgs[0], nil)
+	require.NoError(t, err)
+	require.NoError(t, dlq.Replay(ctx, client, msgID, parsed, nil))
+
+	replayed, err := client.XRange(ctx, stream, "-", "+").Result()
+	require.NoError(t, err)
+	require.Len(t, replayed, 1)
+	assert.Equal(t, opaque, replayed[0].Values["payload"], "the payload bytes are kept")
+
+	var metadata map[string]any
+	require.NoError(t, json.Unmarshal([]byte(replayed[0].Values["metadata"].(string)), &metadata))
+	assert.Equal(t, "gzip", metadata["encoding"])
+	assert.Equal(t, "application/x-unknown", metadata["content_type"])
+}
+
+// undecodedEvent is an eventbus.UndecodedEvent stored as a gzip-compressed payload of an
+// unknown content type.
+type undecodedEvent struct {
+	*testutil.TestEvent
+	stored string
+}
+
+func (e *undecodedEvent) StoredData() string               { return e.stored }
+func (e *undecodedEvent) StoredHeaders() eventbus.Headers  { return nil }
+func (e *undecodedEvent) StoredVersion() string            { return "1.0" }
+func (e *undecodedEvent) Undecoded() bool                  { return true }
+func (e *undecodedEvent) StoredClaimCheck() string         { return "" }
+func (e *undecodedEvent) StoredEncoding() (string, string) { return "gzip", "application/x-unknown" }
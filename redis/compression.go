@@ -0,0 +1,105 @@
+package redis
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Compression is a payload compression algorithm. Its value is written to the
+// "encoding" metadata field of compressed messages.
+type Compression string
+
+// Supported compression algorithms.
+const (
+	CompressionNone Compression = ""
+	CompressionGzip Compression = "gzip"
+	CompressionZstd Compression = "zstd"
+)
+
+// defaultCompressionThreshold is the payload size from which payloads are compressed.
+const defaultCompressionThreshold = 1024
+
+// metadataEncoding is the metadata field naming the payload compression.
+// Messages without it carry plain JSON.
+const metadataEncoding = "encoding"
+
+// ErrUnknownEncoding is returned for a payload compressed with an unsupported algorithm.
+var ErrUnknownEncoding = errors.New("unknown payload encoding")
+
+var (
+	zstdEncoder = sync.OnceValues(func() (*zstd.Encoder, error) { return zstd.NewWriter(nil) })
+	zstdDecoder = sync.OnceValues(func() (*zstd.Decoder, error) { return zstd.NewReader(nil) })
+)
+
+// compress returns payload compressed with algorithm, or ok=false when compressing does not make it smaller.
+func compress(algorithm Compression, payload []byte) (compressed []byte, ok bool, err error) {
+	switch algorithm {
+	case CompressionGzip:
+		var buf bytes.Buffer
+		w := gzip.NewWriter(&buf)
+		if _, err := w.Write(payload); err != nil {
+			return nil, false, err
+		}
+		if err := w.Close(); err != nil {
+			return nil, false, err
+		}
+		compressed = buf.Bytes()
+	case CompressionZstd:
+		encoder, err := zstdEncoder()
+		if err != nil {
+			return nil, false, err
+		}
+		compressed = encoder.EncodeAll(payload, nil)
+	default:
+		return nil, false, fmt.Errorf("%w: %q", ErrUnknownEncoding, algorithm)
+	}
+
+	if len(compressed) >= len(payload) {
+		return nil, false, nil
+	}
+
+	return compressed, true, nil
+}
+
+// DecodePayload returns the JSON payload of a stream message whose metadata
+// "encoding" field is encoding. An empty encoding means the payload is not compressed.
+// Subscribers call it automatically; it is exported for tools reading streams directly.
+func DecodePayload(encoding, payload string) (string, error) {
+	switch Compression(encoding) {
+	case CompressionNone:
+		return payload, nil
+	case CompressionGzip:
+		r, err := gzip.NewReader(bytes.NewReader([]byte(payload)))
+		if err != nil {
+			return "", fmt.Errorf("failed to decompress payload: %w", err)
+		}
+		defer r.Close()
+
+		data, err := io.ReadAll(r)
+		if err != nil {
+			return "", fmt.Errorf("failed to decompress payload: %w", err)
+		}
+
+		return string(data), nil
+	case CompressionZstd:
+		decoder, err := zstdDecoder()
+		if err != nil {
+			return "", err
+		}
+
+		data, err := decoder.DecodeAll([]byte(payload), nil)
+		if err != nil {
+			return "", fmt.Errorf("failed to decompress payload: %w", err)
+		}
+
+		return string(data), nil
+	default:
+		return "", fmt.Errorf("%w: %q", ErrUnknownEncoding, encoding)
+	}
+}
@@ -0,0 +1,140 @@
+//nolint:all // Test file
+package redis_test
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	eventbus "github.com/tclavelloux/promy-event-bus/eventbus"
+	"github.com/tclavelloux/promy-event-bus/redis"
+	"github.com/tclavelloux/promy-event-bus/testutil"
+
+	goredis "github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPublisher_Compression(t *testing.T) {
+	const stream = "events:test-compression"
+
+	client := goredis.NewClient(&goredis.Options{Addr: "localhost:6379", DB: 1})
+	defer client.Close()
+
+	ctx := context.Background()
+	require.NoError(t, client.Del(ctx, stream).Err())
+
+	leaflet := strings.Repeat("Patate douce 1kg - 2,99 EUR. ", 200)
+
+	plainPublisher, err := redis.NewPublisher(eventbus.RedisConfig{DSN: "redis://localhost:6379/1"})
+	require.NoError(t, err)
+	defer plainPublisher.Close()
+
+	for _, algorithm := range []redis.Compression{redis.CompressionGzip, redis.CompressionZstd} {
+		publisher, err := redis.NewPublisher(
+			eventbus.RedisConfig{DSN: "redis://localhost:6379/1"},
+			redis.WithCompression(algorithm, 1024),
+		)
+		require.NoError(t, err)
+		defer publisher.Close()
+
+		large := testutil.NewTestEvent("promotion.created", map[string]any{"leaflet": leaflet})
+		small := testutil.NewTestEvent("promotion.created", map[string]any{"promotion_id": "promo-1"})
+		require.NoError(t, publisher.Publish(ctx, stream, large))
+		require.NoError(t, publisher.Publish(ctx, stream, small))
+	}
+
+	// Producers without compression keep working alongside.
+	plain := testutil.NewTestEvent("promotion.created", map[string]any{"leaflet": leaflet})
+	require.NoError(t, plainPublisher.Publish(ctx, stream, plain))
+
+	// Small payloads stay plain JSON; large ones are compressed and marked.
+	messages, err := client.XRange(ctx, stream, "-", "+").Result()
+	require.NoError(t, err)
+	require.Len(t, messages, 5)
+
+	expected := []string{"gzip", "", "zstd", "", ""}
+	for i, msg := range messages {
+		var metadata map[string]any
+		require.NoError(t, json.Unmarshal([]byte(msg.Values["metadata"].(string)), &metadata))
+
+		encoding, _ := metadata["encoding"].(string)
+		assert.Equal(t, expected[i], encoding)
+
+		payload := msg.Values["payload"].(string)
+		assert.Equal(t, encoding == "", json.Valid([]byte(payload)))
+		if encoding != "" {
+			assert.Less(t, len(payload), len(leaflet))
+		}
+	}
+}
+
+func TestSubscriber_DecompressesPayloads(t *testing.T) {
+	const stream = "events:test-decompression"
+
+	config := eventbus.Config{Redis: eventbus.RedisConfig{DSN: "redis://localhost:6379/1"}}
+
+	subscriber, err := redis.NewSubscriber(config)
+	require.NoError(t, err)
+	defer subscriber.Close()
+
+	received := make(chan eventbus.Event, 3)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	go subscriber.Subscribe(ctx, eventbus.SubscriptionConfig{
+		Stream:        stream,
+		ConsumerGroup: "test-decompression-group",
+		ConsumerID:    "consumer-1",
+		Handler: func(_ context.Context, event eventbus.Event) error {
+			received <- event
+			return nil
+		},
+	})
+
+	time.Sleep(100 * time.Millisecond)
+
+	// Mixed producers: gzip, zstd and uncompressed.
+	var published []eventbus.Event
+	for _, opts := range [][]redis.Option{
+		{redis.WithCompression(redis.CompressionGzip, 0)},
+		{redis.WithCompression(redis.CompressionZstd, 0)},
+		nil,
+	} {
+		publisher, err := redis.NewPublisher(config.Redis, opts...)
+		require.NoError(t, err)
+		defer publisher.Close()
+
+		event := testutil.NewTestEvent("promotion.created", map[string]any{
+			"leaflet": strings.Repeat("Patate douce 1kg - 2,99 EUR. ", 200),
+		})
+		require.NoError(t, publisher.Publish(ctx, stream, event))
+		published = append(published, event)
+	}
+
+	for _, event := range published {
+		select {
+		case got := <-received:
+			want, err := json.Marshal(event)
+			require.NoError(t, err)
+			assert.Equal(t, event.EventID(), got.EventID())
+			assert.JSONEq(t, string(want), got.Data())
+		case <-ctx.Done():
+			t.Fatal("timed out waiting for events")
+		}
+	}
+}
+
+func TestDecodePayload(t *testing.T) {
+	payload, err := redis.DecodePayload("", `{"id":"1"}`)
+	require.NoError(t, err)
+	assert.Equal(t, `{"id":"1"}`, payload)
+
+	_, err = redis.DecodePayload("lz4", "data")
+	assert.ErrorIs(t, err, redis.ErrUnknownEncoding)
+
+	_, err = redis.DecodePayload("gzip", "not gzip")
+	assert.Error(t, err)
+}
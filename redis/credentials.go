@@ -0,0 +1,90 @@
+package redis
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+
+	eventbus "github.com/tclavelloux/promy-event-bus/eventbus"
+)
+
+// CredentialsProvider returns the ACL username and password of new connections, e.g.
+// from a secrets manager. It is called for every new connection, so rotated credentials
+// apply without a restart; open connections stay authenticated.
+type CredentialsProvider func() (username, password string, err error)
+
+// fileCredentials returns a provider reading the files of config on every call, starting
+// from its static username and password.
+func fileCredentials(config eventbus.RedisConfig, username, password string) CredentialsProvider {
+	return func() (string, string, error) {
+		user, pass := username, password
+
+		if config.UsernameFile != "" {
+			data, err := os.ReadFile(config.UsernameFile)
+			if err != nil {
+				return "", "", fmt.Errorf("failed to read Redis username file: %w", err)
+			}
+
+			user = strings.TrimSpace(string(data))
+		}
+
+		if config.PasswordFile != "" {
+			data, err := os.ReadFile(config.PasswordFile)
+			if err != nil {
+				return "", "", fmt.Errorf("failed to read Redis password file: %w", err)
+			}
+
+			pass = strings.TrimSpace(string(data))
+		}
+
+		return user, pass, nil
+	}
+}
+
+// staticCredentials returns the username and password of config that do not change:
+// the fields of config, then the environment variable, over those of the DSN.
+func staticCredentials(config eventbus.RedisConfig, username, password string) (string, string) {
+	if config.Username != "" {
+		username = config.Username
+	}
+	if config.Password != "" {
+		password = config.Password
+	}
+	if config.PasswordEnv != "" {
+		if env, ok := os.LookupEnv(config.PasswordEnv); ok {
+			password = env
+		}
+	}
+
+	return username, password
+}
+
+// credentials adapts a CredentialsProvider to go-redis, which cannot report errors:
+// when the provider fails, the last credentials it returned are used.
+type credentials struct {
+	provider CredentialsProvider
+	logger   *slog.Logger
+
+	mu       sync.Mutex
+	username string
+	password string
+}
+
+func (c *credentials) get() (string, string) {
+	username, password, err := c.provider()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err != nil {
+		c.logger.Warn("failed to refresh Redis credentials, using the previous ones", slog.Any(logKeyError, err))
+
+		return c.username, c.password
+	}
+
+	c.username, c.password = username, password
+
+	return username, password
+}
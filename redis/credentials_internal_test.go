@@ -0,0 +1,54 @@
+package redis
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	eventbus "github.com/tclavelloux/promy-event-bus/eventbus"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileCredentials_FollowRotation(t *testing.T) {
+	dir := t.TempDir()
+	passwordFile := filepath.Join(dir, "password")
+	require.NoError(t, os.WriteFile(passwordFile, []byte("first\n"), 0o600))
+
+	provider := fileCredentials(eventbus.RedisConfig{PasswordFile: passwordFile}, "svc-promotions", "static")
+
+	username, password, err := provider()
+	require.NoError(t, err)
+	assert.Equal(t, "svc-promotions", username)
+	assert.Equal(t, "first", password)
+
+	require.NoError(t, os.WriteFile(passwordFile, []byte("rotated\n"), 0o600))
+
+	_, password, err = provider()
+	require.NoError(t, err)
+	assert.Equal(t, "rotated", password)
+}
+
+func TestCredentials_KeepLastOnError(t *testing.T) {
+	var fail bool
+	creds := &credentials{
+		provider: func() (string, string, error) {
+			if fail {
+				return "", "", errors.New("secrets manager unavailable")
+			}
+			return "svc", "secret", nil
+		},
+		logger: newOptions(nil).logger,
+	}
+
+	username, password := creds.get()
+	assert.Equal(t, "svc", username)
+	assert.Equal(t, "secret", password)
+
+	fail = true
+	username, password = creds.get()
+	assert.Equal(t, "svc", username)
+	assert.Equal(t, "secret", password)
+}
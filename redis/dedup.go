@@ -0,0 +1,61 @@
+package redis
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// dedupKey returns the key remembering that eventID was published to stream.
+// The hash tag keeps it in the slot of the stream, so scripts can touch both with Redis Cluster.
+func dedupKey(stream, eventID string) string {
+	return "{" + hashTag(stream) + "}:dedup:" + eventID
+}
+
+// hashTag returns the part of key that Redis Cluster hashes to pick its slot: the content
+// of its first non-empty {...} section, or the whole key.
+func hashTag(key string) string {
+	if start := strings.IndexByte(key, '{'); start >= 0 {
+		if end := strings.IndexByte(key[start+1:], '}'); end > 0 {
+			return key[start+1 : start+1+end]
+		}
+	}
+
+	return key
+}
+
+// dedupWindowArg formats window for the publish scripts; "0" disables deduplication.
+func dedupWindowArg(window time.Duration) string {
+	return strconv.FormatInt(window.Milliseconds(), 10)
+}
+
+// publishIdempotentScript XADDs one entry unless its event ID was published within the window.
+//
+// KEYS[1]: stream, KEYS[2]: dedup key.
+// ARGV: window in ms, trim strategy ("", "MAXLEN" or "MINID"), trim threshold, metadata, payload.
+// Returns {id, 0} for a new entry or {original id, 1} for a duplicate.
+var publishIdempotentScript = redis.NewScript(`
+local existing = redis.call('GET', KEYS[2])
+if existing then
+  return {existing, 1}
+end
+
+local args = {KEYS[1]}
+if ARGV[2] ~= '' then
+  table.insert(args, ARGV[2])
+  table.insert(args, '~')
+  table.insert(args, ARGV[3])
+end
+table.insert(args, '*')
+table.insert(args, 'metadata')
+table.insert(args, ARGV[4])
+table.insert(args, 'payload')
+table.insert(args, ARGV[5])
+
+local id = redis.call('XADD', unpack(args))
+redis.call('SET', KEYS[2], id, 'PX', ARGV[1])
+
+return {id, 0}
+`)
@@ -0,0 +1,91 @@
+//nolint:all // Test file
+package redis_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	eventbus "github.com/tclavelloux/promy-event-bus/eventbus"
+	"github.com/tclavelloux/promy-event-bus/redis"
+	"github.com/tclavelloux/promy-event-bus/testutil"
+
+	goredis "github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPublisher_IdempotentPublish(t *testing.T) {
+	const stream = "events:test-idempotent"
+
+	client := goredis.NewClient(&goredis.Options{Addr: "localhost:6379", DB: 1})
+	defer client.Close()
+
+	publisher, err := redis.NewPublisher(
+		eventbus.RedisConfig{DSN: "redis://localhost:6379/1"},
+		redis.WithIdempotentPublish(time.Hour),
+	)
+	require.NoError(t, err)
+	defer publisher.Close()
+
+	ctx := context.Background()
+
+	t.Run("same event is published once", func(t *testing.T) {
+		require.NoError(t, client.Del(ctx, stream).Err())
+		event := testutil.NewTestEvent("subscription.started", map[string]any{"user_id": "user-1"})
+
+		first, err := publisher.PublishWithResult(ctx, stream, event)
+		require.NoError(t, err)
+		assert.False(t, first.Duplicate)
+		assert.NotEmpty(t, first.MessageID)
+
+		second, err := publisher.PublishWithResult(ctx, stream, event)
+		require.NoError(t, err)
+		assert.True(t, second.Duplicate)
+		assert.Equal(t, first.MessageID, second.MessageID)
+
+		length, err := client.XLen(ctx, stream).Result()
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), length)
+	})
+
+	t.Run("batch skips published events", func(t *testing.T) {
+		require.NoError(t, client.Del(ctx, stream).Err())
+		published := testutil.NewTestEvent("subscription.started", map[string]any{"user_id": "user-2"})
+		require.NoError(t, publisher.Publish(ctx, stream, published))
+
+		err := publisher.PublishBatch(ctx, stream, []eventbus.Event{
+			published,
+			testutil.NewTestEvent("subscription.renewed", map[string]any{"user_id": "user-2"}),
+		})
+		require.NoError(t, err)
+
+		length, err := client.XLen(ctx, stream).Result()
+		require.NoError(t, err)
+		assert.Equal(t, int64(2), length)
+	})
+}
+
+func TestPublisher_PublishWithResult_NotIdempotentByDefault(t *testing.T) {
+	const stream = "events:test-not-idempotent"
+
+	client := goredis.NewClient(&goredis.Options{Addr: "localhost:6379", DB: 1})
+	defer client.Close()
+
+	ctx := context.Background()
+	require.NoError(t, client.Del(ctx, stream).Err())
+
+	publisher, err := redis.NewPublisher(eventbus.RedisConfig{DSN: "redis://localhost:6379/1"})
+	require.NoError(t, err)
+	defer publisher.Close()
+
+	event := testutil.NewTestEvent("subscription.started", map[string]any{"user_id": "user-1"})
+
+	first, err := publisher.PublishWithResult(ctx, stream, event)
+	require.NoError(t, err)
+	second, err := publisher.PublishWithResult(ctx, stream, event)
+	require.NoError(t, err)
+
+	assert.False(t, second.Duplicate)
+	assert.NotEqual(t, first.MessageID, second.MessageID)
+}
@@ -0,0 +1,87 @@
+package redis
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/tclavelloux/promy-event-bus/codec"
+	"github.com/tclavelloux/promy-event-bus/encryption"
+	eventbus "github.com/tclavelloux/promy-event-bus/eventbus"
+	"github.com/tclavelloux/promy-event-bus/registry"
+)
+
+// encryptedFields returns the payload fields of eventType to encrypt: those given to
+// WithEncryption, then those marked pii in the schema registry.
+func encryptedFields(configured map[string][]string, eventType string) []string {
+	fields := append([]string(nil), configured[eventType]...)
+
+	if declared, ok := registry.Default().Event(eventType); ok {
+		for _, field := range declared.PIIFields() {
+			if !contains(fields, field) {
+				fields = append(fields, field)
+			}
+		}
+	}
+
+	return fields
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
+
+// encryptPayload encrypts the fields of payload selected for eventType and adds the
+// encryption headers to headers. Payloads that are already encrypted, e.g. when
+// forwarding a delivered event, are returned unchanged. Only JSON payloads can be encrypted.
+func (p *Publisher) encryptPayload(
+	ctx context.Context, eventType, contentType string, payload []byte, headers eventbus.Headers,
+) ([]byte, error) {
+	if p.keys == nil || encryption.IsEncrypted(headers) {
+		return payload, nil
+	}
+
+	fields := encryptedFields(p.encryptFields, eventType)
+	if len(fields) == 0 {
+		return payload, nil
+	}
+
+	if contentType != codec.ContentTypeJSON {
+		return nil, fmt.Errorf("%w: fields of %s cannot be encrypted in %s payloads",
+			codec.ErrUnsupportedEvent, eventType, contentType)
+	}
+
+	encrypted, encryptionHeaders, err := encryption.Encrypt(ctx, p.keys, payload, fields)
+	if err != nil {
+		return nil, err
+	}
+
+	for k, v := range encryptionHeaders {
+		headers[k] = v
+	}
+
+	return encrypted, nil
+}
+
+// decryptEvent decrypts the payload of event in place when the subscriber has keys.
+// The stored form is kept for DLQ entries. Without keys, handlers get the ciphertext.
+func (s *Subscriber) decryptEvent(ctx context.Context, event *rawEvent) error {
+	if s.keys == nil || !encryption.IsEncrypted(event.headers) {
+		return nil
+	}
+
+	payload, err := encryption.Decrypt(ctx, s.keys, []byte(event.data), event.headers)
+	if err != nil {
+		return fmt.Errorf("failed to decrypt payload: %w", err)
+	}
+
+	event.data = string(payload)
+	event.headers = encryption.StripHeaders(event.headers)
+
+	return nil
+}
@@ -0,0 +1,155 @@
+//nolint:all // Test file
+package redis_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/tclavelloux/promy-event-bus/encryption"
+	eventbus "github.com/tclavelloux/promy-event-bus/eventbus"
+	"github.com/tclavelloux/promy-event-bus/redis"
+	"github.com/tclavelloux/promy-event-bus/testutil"
+
+	goredis "github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestKeys(t *testing.T) *encryption.StaticKeys {
+	t.Helper()
+
+	keys, err := encryption.NewStaticKeys("k1", map[string][]byte{"k1": bytes.Repeat([]byte{7}, encryption.KeySize)})
+	require.NoError(t, err)
+
+	return keys
+}
+
+func TestPublisher_EncryptsFields(t *testing.T) {
+	const stream = "events:test-encryption"
+
+	client := goredis.NewClient(&goredis.Options{Addr: "localhost:6379", DB: 1})
+	defer client.Close()
+
+	ctx := context.Background()
+	require.NoError(t, client.Del(ctx, stream).Err())
+
+	publisher, err := redis.NewPublisher(
+		eventbus.RedisConfig{DSN: "redis://localhost:6379/1"},
+		redis.WithEncryption(newTestKeys(t), map[string][]string{"promotion.created": {"contact.phone"}}),
+	)
+	require.NoError(t, err)
+	defer publisher.Close()
+
+	// email is marked pii in the registry, contact.phone is declared in code
+	require.NoError(t, publisher.Publish(ctx, stream, testutil.NewTestEvent("user.registered", map[string]any{
+		"user_id": "u-1", "email": "thomas@example.com",
+	})))
+	require.NoError(t, publisher.Publish(ctx, stream, testutil.NewTestEvent("promotion.created", map[string]any{
+		"promotion_id": "promo-1", "contact": map[string]any{"phone": "+33600000000"},
+	})))
+
+	messages, err := client.XRange(ctx, stream, "-", "+").Result()
+	require.NoError(t, err)
+	require.Len(t, messages, 2)
+
+	for i, field := range []string{"email", "contact.phone"} {
+		payload := messages[i].Values["payload"].(string)
+		assert.NotContains(t, payload, "thomas@example.com")
+		assert.NotContains(t, payload, "+33600000000")
+
+		var metadata struct {
+			Headers eventbus.Headers `json:"headers"`
+		}
+		require.NoError(t, json.Unmarshal([]byte(messages[i].Values["metadata"].(string)), &metadata))
+		assert.Equal(t, "k1", metadata.Headers[encryption.HeaderKeyID])
+		assert.Equal(t, field, metadata.Headers[encryption.HeaderFields])
+	}
+}
+
+func TestSubscriber_DecryptsFields(t *testing.T) {
+	const stream = "events:test-decryption"
+
+	config := eventbus.Config{Redis: eventbus.RedisConfig{DSN: "redis://localhost:6379/1"}}
+	keys := newTestKeys(t)
+
+	publisher, err := redis.NewPublisher(config.Redis, redis.WithEncryption(keys, nil))
+	require.NoError(t, err)
+	defer publisher.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	subscribe := func(group string, opts []redis.Option, handler eventbus.EventHandler, dlq eventbus.EventPublisher) {
+		subscriber, err := redis.NewSubscriber(config, opts...)
+		require.NoError(t, err)
+		t.Cleanup(func() { subscriber.Close() })
+
+		go subscriber.Subscribe(ctx, eventbus.SubscriptionConfig{
+			Stream:        stream,
+			ConsumerGroup: group,
+			ConsumerID:    "consumer-1",
+			Handler:       handler,
+			DLQPublisher:  dlq,
+			DLQService:    "test-service",
+		})
+	}
+
+	withKey := make(chan eventbus.Event, 1)
+	subscribe("test-decryption-with-key", []redis.Option{redis.WithEncryption(keys, nil)},
+		func(_ context.Context, event eventbus.Event) error {
+			withKey <- event
+			return nil
+		}, nil)
+
+	withoutKey := make(chan eventbus.Event, 1)
+	subscribe("test-decryption-without-key", nil,
+		func(_ context.Context, event eventbus.Event) error {
+			withoutKey <- event
+			return nil
+		}, nil)
+
+	dlq := &testutil.MockPublisher{}
+	dlqEntries := make(chan *eventbus.DLQEntry, 1)
+	dlq.On("Publish", mock.Anything, mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		dlqEntries <- args.Get(2).(*eventbus.DLQEntry)
+	}).Return(nil)
+	subscribe("test-decryption-dlq", []redis.Option{redis.WithEncryption(keys, nil)},
+		func(_ context.Context, _ eventbus.Event) error { return assert.AnError }, dlq)
+
+	time.Sleep(100 * time.Millisecond)
+
+	event := testutil.NewTestEvent("user.registered", map[string]any{"user_id": "u-1", "email": "thomas@example.com"})
+	require.NoError(t, publisher.Publish(ctx, stream, event))
+
+	select {
+	case got := <-withKey:
+		assert.Contains(t, got.Data(), "thomas@example.com")
+		assert.False(t, encryption.IsEncrypted(eventbus.EventHeaders(got)))
+	case <-ctx.Done():
+		t.Fatal("timed out waiting for decrypted event")
+	}
+
+	select {
+	case got := <-withoutKey:
+		assert.NotContains(t, got.Data(), "thomas@example.com")
+		assert.True(t, encryption.IsEncrypted(eventbus.EventHeaders(got)))
+	case <-ctx.Done():
+		t.Fatal("timed out waiting for encrypted event")
+	}
+
+	select {
+	case entry := <-dlqEntries:
+		assert.NotContains(t, entry.OriginalPayload, "thomas@example.com")
+		assert.True(t, encryption.IsEncrypted(entry.OriginalHeaders))
+
+		payload, err := encryption.Decrypt(ctx, keys, []byte(entry.OriginalPayload), entry.OriginalHeaders)
+		require.NoError(t, err)
+		assert.Contains(t, string(payload), "thomas@example.com")
+	case <-ctx.Done():
+		t.Fatal("timed out waiting for DLQ entry")
+	}
+}
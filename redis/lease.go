@@ -0,0 +1,32 @@
+package redis
+
+import "github.com/redis/go-redis/v9"
+
+// leaseScript acquires or renews a lease held in a string key, such as the scheduler
+// lease or the lease of a stream partition.
+//
+// KEYS[1]: lease key. ARGV: owner, TTL in ms.
+// Returns 1 if the owner holds the lease, 0 otherwise.
+var leaseScript = redis.NewScript(`
+if redis.call('SET', KEYS[1], ARGV[1], 'NX', 'PX', ARGV[2]) then
+  return 1
+end
+
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+  redis.call('PEXPIRE', KEYS[1], ARGV[2])
+  return 1
+end
+
+return 0
+`)
+
+// releaseLeaseScript deletes a lease if the owner holds it.
+//
+// KEYS[1]: lease key. ARGV: owner.
+var releaseLeaseScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+  return redis.call('DEL', KEYS[1])
+end
+
+return 0
+`)
@@ -0,0 +1,46 @@
+package redis
+
+import (
+	"context"
+	"log/slog"
+
+	eventbus "github.com/tclavelloux/promy-event-bus/eventbus"
+)
+
+// Log attribute keys shared by publisher and subscriber records.
+const (
+	logKeyStream    = "stream"
+	logKeyGroup     = "group"
+	logKeyConsumer  = "consumer"
+	logKeyMessageID = "message_id"
+	logKeyEventID   = "event_id"
+	logKeyEventType = "event_type"
+	logKeyAttempt   = "attempt"
+	logKeyError     = "error"
+)
+
+// subscriptionLogger returns a logger annotated with the subscription identity.
+func subscriptionLogger(logger *slog.Logger, config eventbus.SubscriptionConfig) *slog.Logger {
+	return logger.With(
+		slog.String(logKeyStream, config.Stream),
+		slog.String(logKeyGroup, config.ConsumerGroup),
+		slog.String(logKeyConsumer, config.ConsumerID),
+	)
+}
+
+// eventLogger returns a logger annotated with the identity of event.
+func eventLogger(logger *slog.Logger, event eventbus.Event) *slog.Logger {
+	return logger.With(
+		slog.String(logKeyEventID, event.EventID()),
+		slog.String(logKeyEventType, event.EventType()),
+	)
+}
+
+// discardHandler drops every record. It is the default handler so the library
+// stays silent unless a logger is configured.
+type discardHandler struct{}
+
+func (discardHandler) Enabled(context.Context, slog.Level) bool  { return false }
+func (discardHandler) Handle(context.Context, slog.Record) error { return nil }
+func (h discardHandler) WithAttrs([]slog.Attr) slog.Handler      { return h }
+func (h discardHandler) WithGroup(string) slog.Handler           { return h }
@@ -0,0 +1,127 @@
+//nolint:all // Test file
+package redis_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+
+	eventbus "github.com/tclavelloux/promy-event-bus/eventbus"
+	"github.com/tclavelloux/promy-event-bus/redis"
+	"github.com/tclavelloux/promy-event-bus/testutil"
+
+	goredis "github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// logBuffer is a goroutine-safe buffer of JSON log records.
+type logBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *logBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+// find returns the first record with the given message.
+func (b *logBuffer) find(msg string) map[string]any {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, line := range bytes.Split(b.buf.Bytes(), []byte("\n")) {
+		var record map[string]any
+		if json.Unmarshal(line, &record) == nil && record["msg"] == msg {
+			return record
+		}
+	}
+	return nil
+}
+
+func TestLogging_SubscriberFailurePaths(t *testing.T) {
+	logs := &logBuffer{}
+	logger := slog.New(slog.NewJSONHandler(logs, &slog.HandlerOptions{Level: slog.LevelDebug}))
+
+	config := eventbus.Config{
+		Redis: eventbus.RedisConfig{
+			DSN: "redis://localhost:6379/1",
+		},
+	}
+
+	subscriber, err := redis.NewSubscriber(config, redis.WithLogger(logger))
+	require.NoError(t, err)
+	defer subscriber.Close()
+
+	publisher, err := redis.NewPublisher(config.Redis)
+	require.NoError(t, err)
+	defer publisher.Close()
+
+	dlqPublisher := &testutil.MockPublisher{}
+	dlqPublisher.On("Publish", mock.Anything, mock.Anything, mock.Anything).Return(eventbus.ErrPublishFailed)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	go func() {
+		subscriber.Subscribe(ctx, eventbus.SubscriptionConfig{
+			Stream:        "events:test-logging",
+			ConsumerGroup: "test-logging-group",
+			ConsumerID:    "consumer-1",
+			Handler: func(ctx context.Context, event eventbus.Event) error {
+				return assert.AnError
+			},
+			DLQPublisher: dlqPublisher,
+			DLQService:   "test-service",
+		})
+	}()
+
+	time.Sleep(100 * time.Millisecond)
+
+	t.Run("invalid metadata is logged and acknowledged", func(t *testing.T) {
+		client := goredis.NewClient(&goredis.Options{Addr: "localhost:6379", DB: 1})
+		defer client.Close()
+
+		err := client.XAdd(context.Background(), &goredis.XAddArgs{
+			Stream: "events:test-logging",
+			Values: map[string]any{"metadata": "not-json", "payload": "{}"},
+		}).Err()
+		require.NoError(t, err)
+
+		require.Eventually(t, func() bool {
+			return logs.find("message has invalid metadata, acknowledging") != nil
+		}, 5*time.Second, 50*time.Millisecond)
+
+		record := logs.find("message has invalid metadata, acknowledging")
+		assert.Equal(t, "events:test-logging", record["stream"])
+		assert.Equal(t, "test-logging-group", record["group"])
+		assert.Equal(t, "consumer-1", record["consumer"])
+		assert.NotEmpty(t, record["message_id"])
+	})
+
+	t.Run("DLQ publish failure is logged with event attributes", func(t *testing.T) {
+		event := testutil.NewTestEvent("promotion.created", map[string]any{"promotion_id": "promo-logging"})
+		err := publisher.Publish(context.Background(), "events:test-logging", event)
+		require.NoError(t, err)
+
+		require.Eventually(t, func() bool {
+			return logs.find("failed to route event to DLQ, dropping it") != nil
+		}, 8*time.Second, 50*time.Millisecond)
+
+		record := logs.find("failed to route event to DLQ, dropping it")
+		assert.Equal(t, event.EventID(), record["event_id"])
+		assert.Equal(t, "promotion.created", record["event_type"])
+		assert.Equal(t, float64(3), record["attempt"])
+		assert.Contains(t, record["error"], eventbus.ErrPublishFailed.Error())
+
+		retry := logs.find("event handler failed, retrying")
+		require.NotNil(t, retry)
+		assert.Equal(t, event.EventID(), retry["event_id"])
+	})
+}
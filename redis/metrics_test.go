@@ -0,0 +1,210 @@
+//nolint:all // Test file
+package redis_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	eventbus "github.com/tclavelloux/promy-event-bus/eventbus"
+	"github.com/tclavelloux/promy-event-bus/redis"
+	"github.com/tclavelloux/promy-event-bus/testutil"
+
+	goredis "github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingMetrics records outcomes reported through eventbus.Metrics.
+type recordingMetrics struct {
+	eventbus.NopMetrics
+
+	mu        sync.Mutex
+	published []string
+	outcomes  []eventbus.Outcome
+	retries   []int
+	dlq       int
+}
+
+func (m *recordingMetrics) EventPublished(stream, eventType string, _ time.Duration, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err == nil {
+		m.published = append(m.published, stream+"/"+eventType)
+	}
+}
+
+func (m *recordingMetrics) EventHandled(_, _, _ string, outcome eventbus.Outcome, _ time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.outcomes = append(m.outcomes, outcome)
+}
+
+func (m *recordingMetrics) EventRetried(_, _, _ string, attempt int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.retries = append(m.retries, attempt)
+}
+
+func (m *recordingMetrics) EventDeadLettered(_, _, _ string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.dlq++
+}
+
+func (m *recordingMetrics) snapshot() ([]string, []eventbus.Outcome, []int, int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]string(nil), m.published...), append([]eventbus.Outcome(nil), m.outcomes...), append([]int(nil), m.retries...), m.dlq
+}
+
+func TestMetrics_RetryAndDLQ(t *testing.T) {
+	metrics := &recordingMetrics{}
+
+	config := eventbus.Config{
+		Redis: eventbus.RedisConfig{
+			DSN: "redis://localhost:6379/1",
+		},
+	}
+
+	publisher, err := redis.NewPublisher(config.Redis, redis.WithMetrics(metrics))
+	require.NoError(t, err)
+	defer publisher.Close()
+
+	subscriber, err := redis.NewSubscriber(config, redis.WithMetrics(metrics))
+	require.NoError(t, err)
+	defer subscriber.Close()
+
+	dlqPublisher := &testutil.MockPublisher{}
+	dlqPublisher.On("Publish", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	go func() {
+		subscriber.Subscribe(ctx, eventbus.SubscriptionConfig{
+			Stream:        "events:test-metrics",
+			ConsumerGroup: "test-metrics-group",
+			ConsumerID:    "consumer-1",
+			Handler: func(ctx context.Context, event eventbus.Event) error {
+				return assert.AnError
+			},
+			DLQPublisher: dlqPublisher,
+			DLQService:   "test-service",
+		})
+	}()
+
+	time.Sleep(100 * time.Millisecond)
+
+	event := testutil.NewTestEvent("promotion.created", map[string]any{"promotion_id": "promo-metrics"})
+	err = publisher.Publish(context.Background(), "events:test-metrics", event)
+	require.NoError(t, err)
+
+	require.Eventually(t, func() bool {
+		_, _, _, dlq := metrics.snapshot()
+		return dlq == 1
+	}, 8*time.Second, 50*time.Millisecond)
+
+	published, outcomes, retries, _ := metrics.snapshot()
+	assert.Contains(t, published, "events:test-metrics/promotion.created")
+	assert.Equal(t, []eventbus.Outcome{eventbus.OutcomeRetry, eventbus.OutcomeRetry, eventbus.OutcomeDeadLettered}, outcomes)
+	assert.Equal(t, []int{2, 3}, retries)
+}
+
+func TestMetrics_FailedRetry(t *testing.T) {
+	const stream = "events:test-metrics-failed-retry"
+
+	metrics := &recordingMetrics{}
+	config := eventbus.Config{Redis: eventbus.RedisConfig{DSN: "redis://localhost:6379/1"}}
+
+	client := goredis.NewClient(&goredis.Options{Addr: "localhost:6379", DB: 1})
+	defer client.Close()
+	require.NoError(t, client.Del(context.Background(), stream).Err())
+	t.Cleanup(func() { client.Del(context.Background(), stream) })
+
+	publisher, err := redis.NewPublisher(config.Redis)
+	require.NoError(t, err)
+	defer publisher.Close()
+
+	subscriber, err := redis.NewSubscriber(config, redis.WithMetrics(metrics))
+	require.NoError(t, err)
+	defer subscriber.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	go subscriber.Subscribe(ctx, eventbus.SubscriptionConfig{
+		Stream:        stream,
+		ConsumerGroup: "test-metrics-failed-retry-group",
+		ConsumerID:    "consumer-1",
+		Handler: func(ctx context.Context, event eventbus.Event) error {
+			// Replace the stream with a string, so that re-adding the event fails
+			require.NoError(t, client.Del(ctx, stream).Err())
+			require.NoError(t, client.Set(ctx, stream, "not a stream", 0).Err())
+			return assert.AnError
+		},
+	})
+
+	time.Sleep(100 * time.Millisecond)
+	require.NoError(t, publisher.Publish(ctx, stream, testutil.NewTestEvent("promotion.created", nil)))
+
+	require.Eventually(t, func() bool {
+		_, outcomes, _, _ := metrics.snapshot()
+		return len(outcomes) == 1
+	}, 3*time.Second, 20*time.Millisecond)
+
+	_, outcomes, retries, _ := metrics.snapshot()
+	assert.Equal(t, []eventbus.Outcome{eventbus.OutcomeDropped}, outcomes)
+	assert.Empty(t, retries, "failed retries are not counted as retries")
+}
+
+func TestMetrics_FailedDLQPublish(t *testing.T) {
+	const stream = "events:test-metrics-failed-dlq"
+
+	metrics := &recordingMetrics{}
+	config := eventbus.Config{Redis: eventbus.RedisConfig{DSN: "redis://localhost:6379/1"}}
+
+	client := goredis.NewClient(&goredis.Options{Addr: "localhost:6379", DB: 1})
+	defer client.Close()
+	require.NoError(t, client.Del(context.Background(), stream).Err())
+
+	publisher, err := redis.NewPublisher(config.Redis)
+	require.NoError(t, err)
+	defer publisher.Close()
+
+	subscriber, err := redis.NewSubscriber(config, redis.WithMetrics(metrics))
+	require.NoError(t, err)
+	defer subscriber.Close()
+
+	dlqPublisher := &testutil.MockPublisher{}
+	dlqPublisher.On("Publish", mock.Anything, mock.Anything, mock.Anything).Return(eventbus.ErrPublishFailed)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	go subscriber.Subscribe(ctx, eventbus.SubscriptionConfig{
+		Stream:        stream,
+		ConsumerGroup: "test-metrics-failed-dlq-group",
+		ConsumerID:    "consumer-1",
+		Handler: func(ctx context.Context, event eventbus.Event) error {
+			// Not retried, so that the event goes to the DLQ at once
+			return eventbus.ErrUnsupportedVersion
+		},
+		DLQPublisher: dlqPublisher,
+		DLQService:   "test-service",
+	})
+
+	time.Sleep(100 * time.Millisecond)
+	require.NoError(t, publisher.Publish(ctx, stream, testutil.NewTestEvent("promotion.created", nil)))
+
+	require.Eventually(t, func() bool {
+		_, outcomes, _, _ := metrics.snapshot()
+		return len(outcomes) == 1
+	}, 3*time.Second, 20*time.Millisecond)
+
+	_, outcomes, _, dlq := metrics.snapshot()
+	assert.Equal(t, []eventbus.Outcome{eventbus.OutcomeDropped}, outcomes)
+	assert.Zero(t, dlq, "events the DLQ publisher failed on are not counted as dead-lettered")
+}
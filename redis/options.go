@@ -0,0 +1,259 @@
+package redis
+
+import (
+	"log/slog"
+	"time"
+
+	"github.com/tclavelloux/promy-event-bus/claimcheck"
+	"github.com/tclavelloux/promy-event-bus/codec"
+	"github.com/tclavelloux/promy-event-bus/encryption"
+	eventbus "github.com/tclavelloux/promy-event-bus/eventbus"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Option configures a Publisher or Subscriber.
+type Option func(*options)
+
+type options struct {
+	tracerProvider trace.TracerProvider
+	propagator     propagation.TextMapPropagator
+	metrics        eventbus.Metrics
+	logger         *slog.Logger
+	dedupWindow    time.Duration
+
+	compression          Compression
+	compressionThreshold int
+
+	keys          encryption.KeyProvider
+	encryptFields map[string][]string
+
+	codec        codec.Codec
+	streamCodecs map[string]codec.Codec
+	codecs       map[string]codec.Codec
+
+	cloudEventsSource string
+
+	blobs                claimcheck.Store
+	claimCheckThreshold  int
+	claimCheckSweepGrace time.Duration
+
+	upcasters *eventbus.Upcasters
+
+	partitionLease time.Duration
+
+	credentials CredentialsProvider
+}
+
+func newOptions(opts []Option) options {
+	o := options{
+		tracerProvider: otel.GetTracerProvider(),
+		propagator:     otel.GetTextMapPropagator(),
+		metrics:        eventbus.NopMetrics{},
+		logger:         slog.New(discardHandler{}),
+		codec:          codec.JSON,
+		streamCodecs:   make(map[string]codec.Codec),
+		codecs:         make(map[string]codec.Codec),
+		upcasters:      eventbus.DefaultUpcasters(),
+		partitionLease: defaultPartitionLease,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	return o
+}
+
+// WithTracerProvider sets the OpenTelemetry tracer provider used to create
+// producer and consumer spans.
+// Default: the global provider (otel.GetTracerProvider).
+func WithTracerProvider(provider trace.TracerProvider) Option {
+	return func(o *options) {
+		if provider != nil {
+			o.tracerProvider = provider
+		}
+	}
+}
+
+// WithPropagator sets the propagator used to inject trace context into message
+// headers on publish and extract it on consume (e.g., propagation.TraceContext{}).
+// Default: the global propagator (otel.GetTextMapPropagator).
+func WithPropagator(propagator propagation.TextMapPropagator) Option {
+	return func(o *options) {
+		if propagator != nil {
+			o.propagator = propagator
+		}
+	}
+}
+
+// WithMetrics sets the metrics sink for publish and consume instrumentation
+// (e.g., prometheus.NewMetrics).
+// Default: eventbus.NopMetrics.
+func WithMetrics(metrics eventbus.Metrics) Option {
+	return func(o *options) {
+		if metrics != nil {
+			o.metrics = metrics
+		}
+	}
+}
+
+// WithLogger sets the logger for failures that cannot be returned to the caller
+// (acknowledgements, retries, DLQ routing) and for lifecycle events.
+// Default: a logger that discards everything.
+func WithLogger(logger *slog.Logger) Option {
+	return func(o *options) {
+		if logger != nil {
+			o.logger = logger
+		}
+	}
+}
+
+// WithIdempotentPublish makes the publisher skip events whose ID was already
+// published to the same stream within window, e.g. when a caller retries after
+// a timeout. Each published event keeps a dedup key for window.
+// It only applies to publishers; retries of the subscriber are never deduplicated.
+// Default: disabled.
+func WithIdempotentPublish(window time.Duration) Option {
+	return func(o *options) {
+		if window > 0 {
+			o.dedupWindow = window
+		}
+	}
+}
+
+// WithCompression compresses payloads of at least threshold bytes with algorithm and
+// marks them with an "encoding" metadata field. Payloads that do not shrink are stored as is.
+// A threshold of 0 or less means 1 KiB.
+// Subscribers decompress transparently, whatever their own options; consumers must run a
+// version that understands the algorithm before producers enable it.
+// Default: no compression.
+func WithCompression(algorithm Compression, threshold int) Option {
+	return func(o *options) {
+		if threshold <= 0 {
+			threshold = defaultCompressionThreshold
+		}
+
+		o.compression = algorithm
+		o.compressionThreshold = threshold
+	}
+}
+
+// WithEncryption encrypts personal data in payloads with keys from keys (see package encryption).
+// Publishers encrypt the fields listed in fields for each event type (e.g.,
+// {"user.registered": {"email"}}) plus the fields marked pii in the schema registry.
+// Subscribers decrypt them before calling the handler; a message that cannot be
+// decrypted fails like a handler error. Subscribers without this option deliver the ciphertext.
+// Default: no encryption.
+func WithEncryption(keys encryption.KeyProvider, fields map[string][]string) Option {
+	return func(o *options) {
+		if keys != nil {
+			o.keys = keys
+			o.encryptFields = fields
+		}
+	}
+}
+
+// WithCodec sets the codec of published payloads (e.g., codec.MsgPack) and registers it
+// with subscribers for decoding. Its content type is stored in a "content_type" metadata field.
+// Subscribers know codec.JSON and codec.MsgPack without this option; a codec.Protobuf must be
+// registered since it holds the message types.
+// Field encryption (WithEncryption) requires JSON payloads.
+// Default: codec.JSON.
+func WithCodec(c codec.Codec) Option {
+	return func(o *options) {
+		if c != nil {
+			o.codec = c
+			o.codecs[c.ContentType()] = c
+		}
+	}
+}
+
+// WithStreamCodec is like WithCodec for the payloads published to stream only,
+// e.g. to use a compact codec for a high-volume stream.
+func WithStreamCodec(stream string, c codec.Codec) Option {
+	return func(o *options) {
+		if c != nil {
+			o.streamCodecs[stream] = c
+			o.codecs[c.ContentType()] = c
+		}
+	}
+}
+
+// WithCloudEvents makes the publisher write each payload as a CloudEvents 1.0 event in the
+// JSON format (content type "application/cloudevents+json"), for consumers outside the bus.
+// source is the CloudEvent source of events that do not carry their own (see
+// cloudevents.SourceCarrier), e.g. "https://promy.fr/promy-user". Headers become extensions.
+// Subscribers unwrap such payloads whatever their options, and also accept messages that
+// only hold a CloudEvent in their payload field, as written by non-bus producers.
+// Default: native payloads.
+func WithCloudEvents(source string) Option {
+	return func(o *options) {
+		o.cloudEventsSource = source
+	}
+}
+
+// WithClaimCheck stores payloads of at least threshold bytes (after compression) in store
+// and publishes a "claim_check" metadata field with their key instead. Subscribers fetch
+// the payload back before calling the handler; a payload that cannot be fetched fails
+// like a handler error. Publisher.SweepClaimChecks deletes blobs once their entries are
+// trimmed from the stream. A threshold of 0 or less means 64 KiB.
+// Publishers and subscribers must share the store.
+// Default: payloads always stay in the stream.
+func WithClaimCheck(store claimcheck.Store, threshold int) Option {
+	return func(o *options) {
+		if threshold <= 0 {
+			threshold = defaultClaimCheckThreshold
+		}
+
+		o.blobs = store
+		o.claimCheckThreshold = threshold
+	}
+}
+
+// WithClaimCheckSweepGrace sets how long Publisher.SweepClaimChecks keeps blobs published
+// before the oldest entry of a stream. Subscriber retries re-add entries with the blob of
+// the original one, so the grace must cover the time between publishing an event and
+// retrying it, e.g. the longest consumer lag on streams with MaxLen retention only.
+// Default: the MaxAge retention of the stream, and at least an hour.
+func WithClaimCheckSweepGrace(grace time.Duration) Option {
+	return func(o *options) {
+		if grace > 0 {
+			o.claimCheckSweepGrace = grace
+		}
+	}
+}
+
+// WithUpcasters sets the upcasters subscribers apply before calling the handler, so that
+// handlers see the latest version of each event. Events that cannot be upcast fail like
+// a handler error. DLQ entries keep the payload and version as stored in the stream.
+// Default: eventbus.DefaultUpcasters, filled by eventbus.RegisterUpcaster.
+func WithUpcasters(upcasters *eventbus.Upcasters) Option {
+	return func(o *options) {
+		o.upcasters = upcasters
+	}
+}
+
+// WithPartitionLease sets how long a subscriber keeps its membership and the partitions
+// it owns without renewal, for partitioned streams. Subscribers renew them every third of
+// ttl and rebalance partitions at that pace, so the partitions of a crashed subscriber
+// are taken over at most ttl later.
+// Default: 10s.
+func WithPartitionLease(ttl time.Duration) Option {
+	return func(o *options) {
+		if ttl > 0 {
+			o.partitionLease = ttl
+		}
+	}
+}
+
+// WithCredentialsProvider authenticates new connections with the username and password
+// returned by provider, e.g. fetched from a secrets manager, instead of those of
+// eventbus.RedisConfig. When provider fails, the last credentials it returned are used.
+// Default: the credentials of eventbus.RedisConfig.
+func WithCredentialsProvider(provider CredentialsProvider) Option {
+	return func(o *options) {
+		o.credentials = provider
+	}
+}
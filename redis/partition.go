@@ -0,0 +1,345 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"log/slog"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	eventbus "github.com/tclavelloux/promy-event-bus/eventbus"
+	"github.com/tclavelloux/promy-event-bus/registry"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const defaultPartitionLease = 10 * time.Second
+
+// partitionStream returns the name of partition i of stream, e.g. "events:promotions:3".
+func partitionStream(stream string, i int) string {
+	return stream + ":" + strconv.Itoa(i)
+}
+
+// streamPartitions resolves the number of partitions of stream: a positive override
+// in config wins, then the count declared in the schema registry. It returns 1 for
+// streams that are not partitioned.
+func streamPartitions(config eventbus.RedisConfig, stream string) int {
+	if override := config.Partitions[stream]; override > 0 {
+		return override
+	}
+
+	if declared, ok := registry.Default().Stream(stream); ok && declared.Partitions > 1 {
+		return declared.Partitions
+	}
+
+	return 1
+}
+
+// splitPartition reports whether stream is a partition of a partitioned stream and
+// returns that stream with its number of partitions.
+func splitPartition(config eventbus.RedisConfig, stream string) (string, int, bool) {
+	i := strings.LastIndexByte(stream, ':')
+	if i < 0 {
+		return "", 0, false
+	}
+
+	index, err := strconv.Atoi(stream[i+1:])
+	if err != nil || index < 0 {
+		return "", 0, false
+	}
+
+	base := stream[:i]
+	partitions := streamPartitions(config, base)
+	if partitions <= 1 || index >= partitions {
+		return "", 0, false
+	}
+
+	return base, partitions, true
+}
+
+// partitionOf hashes key into one of n partitions with FNV-1a.
+func partitionOf(key string, n int) int {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(key))
+
+	return int(h.Sum32() % uint32(n)) //nolint:gosec // n is a small positive partition count
+}
+
+// targetStream returns the stream event is written to: stream itself, or the partition
+// its partition key hashes to if stream is partitioned.
+func (p *Publisher) targetStream(stream string, event eventbus.Event) string {
+	partitions := streamPartitions(p.config, stream)
+	if partitions <= 1 {
+		return stream
+	}
+
+	return partitionStream(stream, partitionOf(eventbus.PartitionKey(event), partitions))
+}
+
+// partitionMembersKey returns the sorted set of the live members of group on stream,
+// scored by the time their membership expires in Unix ms.
+func partitionMembersKey(stream, group string) string {
+	return "eventbus:partitions:" + stream + ":" + group + ":members"
+}
+
+// partitionLeaseKey returns the key of the lease on partition i of stream for group.
+func partitionLeaseKey(stream, group string, i int) string {
+	return "eventbus:partitions:" + stream + ":" + group + ":" + strconv.Itoa(i)
+}
+
+// partitionWorker consumes one partition owned by the subscriber.
+type partitionWorker struct {
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	// renewed is when the lease on the partition was last acquired or renewed.
+	renewed time.Time
+}
+
+// stop cancels the worker and waits for it to return.
+func (w *partitionWorker) stop() {
+	w.cancel()
+	<-w.done
+}
+
+// subscribePartitions consumes the partitions of a partitioned stream that are assigned
+// to this consumer. Members of the group announce themselves in a sorted set; each one
+// owns the partitions whose index modulo the member count matches its rank, and holds a
+// lease on them so that a partition is never consumed by two members at once. Owned
+// partitions are processed one message at a time to preserve their order.
+func (s *Subscriber) subscribePartitions(ctx context.Context, subConfig eventbus.SubscriptionConfig, partitions int) error {
+	log := subscriptionLogger(s.logger, subConfig).With(slog.Int("partitions", partitions))
+	log.InfoContext(ctx, "partitioned subscription started")
+
+	workers := make(map[int]*partitionWorker)
+	errs := make(chan error, partitions)
+
+	defer s.leavePartitions(subConfig, workers, log)
+
+	ticker := time.NewTicker(s.partitionLease / 3)
+	defer ticker.Stop()
+
+	for {
+		if err := s.rebalance(ctx, subConfig, partitions, workers, errs, log); err != nil && ctx.Err() == nil {
+			log.ErrorContext(ctx, "failed to rebalance partitions", slog.Any(logKeyError, err))
+		}
+
+		select {
+		case <-ctx.Done():
+			log.InfoContext(ctx, "partitioned subscription stopped")
+
+			return ctx.Err()
+		case err := <-errs:
+			return err
+		case <-ticker.C:
+		}
+	}
+}
+
+// rebalance renews the membership of this consumer, then starts workers for the
+// partitions assigned to it whose lease it holds and stops the others.
+func (s *Subscriber) rebalance(
+	ctx context.Context,
+	subConfig eventbus.SubscriptionConfig,
+	partitions int,
+	workers map[int]*partitionWorker,
+	errs chan<- error,
+	log *slog.Logger,
+) error {
+	members, err := s.heartbeat(ctx, subConfig)
+	if err != nil {
+		s.stopExpiring(ctx, workers, log)
+
+		return err
+	}
+
+	rank := sort.SearchStrings(members, subConfig.ConsumerID)
+	ttl := s.partitionLease.Milliseconds()
+
+	var leaseErrs []error
+
+	for i := range partitions {
+		assigned := i%len(members) == rank
+		worker, running := workers[i]
+		leaseKey := partitionLeaseKey(subConfig.Stream, subConfig.ConsumerGroup, i)
+
+		if !assigned {
+			if running {
+				worker.stop()
+				delete(workers, i)
+				s.releasePartition(subConfig, i, log)
+				log.InfoContext(ctx, "partition revoked", slog.Int("partition", i))
+			}
+
+			continue
+		}
+
+		renewed := time.Now()
+		held, err := leaseScript.Run(ctx, s.client, []string{leaseKey}, subConfig.ConsumerID, ttl).Bool()
+		if err != nil {
+			leaseErrs = append(leaseErrs, fmt.Errorf("failed to acquire lease on partition %d: %w", i, err))
+			if running && s.leaseExpiring(worker) {
+				s.stopPartition(ctx, workers, i, log)
+			}
+
+			continue
+		}
+
+		switch {
+		case held && running:
+			worker.renewed = renewed
+		case held && !running:
+			workers[i] = s.startPartition(ctx, subConfig, i, errs)
+			workers[i].renewed = renewed
+			log.InfoContext(ctx, "partition assigned", slog.Int("partition", i))
+		case !held && running:
+			// The lease expired and another member took the partition over
+			worker.stop()
+			delete(workers, i)
+			log.WarnContext(ctx, "partition lease lost", slog.Int("partition", i))
+		}
+	}
+
+	return errors.Join(leaseErrs...)
+}
+
+// leaseExpiring reports whether the lease of worker expires before the next renewal.
+func (s *Subscriber) leaseExpiring(worker *partitionWorker) bool {
+	return time.Since(worker.renewed) >= s.partitionLease-s.partitionLease/3
+}
+
+// stopExpiring stops the workers whose lease could not be renewed and expires before the
+// next renewal, since another member may then take their partition over.
+func (s *Subscriber) stopExpiring(ctx context.Context, workers map[int]*partitionWorker, log *slog.Logger) {
+	for i, worker := range workers {
+		if s.leaseExpiring(worker) {
+			s.stopPartition(ctx, workers, i, log)
+		}
+	}
+}
+
+// stopPartition stops the worker of partition i, whose lease could not be renewed.
+func (s *Subscriber) stopPartition(ctx context.Context, workers map[int]*partitionWorker, i int, log *slog.Logger) {
+	workers[i].stop()
+	delete(workers, i)
+	log.WarnContext(ctx, "partition lease not renewed, partition stopped", slog.Int("partition", i))
+}
+
+// heartbeat renews the membership of this consumer, drops expired members and returns
+// the live members in name order.
+func (s *Subscriber) heartbeat(ctx context.Context, subConfig eventbus.SubscriptionConfig) ([]string, error) {
+	key := partitionMembersKey(subConfig.Stream, subConfig.ConsumerGroup)
+	now := time.Now()
+
+	var members *redis.StringSliceCmd
+
+	_, err := s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.ZAdd(ctx, key, redis.Z{Score: float64(now.Add(s.partitionLease).UnixMilli()), Member: subConfig.ConsumerID})
+		pipe.ZRemRangeByScore(ctx, key, "-inf", "("+strconv.FormatInt(now.UnixMilli(), 10))
+		members = pipe.ZRange(ctx, key, 0, -1)
+		pipe.PExpire(ctx, key, 2*s.partitionLease)
+
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to renew partition membership: %w", err)
+	}
+
+	names := members.Val()
+	sort.Strings(names)
+
+	return names, nil
+}
+
+// startPartition starts a worker consuming partition i, reporting its failure to errs.
+func (s *Subscriber) startPartition(
+	ctx context.Context, subConfig eventbus.SubscriptionConfig, i int, errs chan<- error,
+) *partitionWorker {
+	workerCtx, cancel := context.WithCancel(ctx)
+	worker := &partitionWorker{cancel: cancel, done: make(chan struct{})}
+
+	partConfig := subConfig
+	partConfig.Stream = partitionStream(subConfig.Stream, i)
+	partConfig.MaxConcurrency = 1
+
+	go func() {
+		defer close(worker.done)
+
+		if err := s.consumePartition(workerCtx, partConfig); err != nil && workerCtx.Err() == nil {
+			select {
+			case errs <- err:
+			default: // the subscription is already failing
+			}
+		}
+	}()
+
+	return worker
+}
+
+// consumePartition processes the entries of a partition still pending from its previous
+// owner, then consumes new ones.
+func (s *Subscriber) consumePartition(ctx context.Context, subConfig eventbus.SubscriptionConfig) error {
+	if err := s.createGroup(ctx, subConfig); err != nil {
+		return err
+	}
+
+	start := "0-0"
+	for {
+		messages, next, err := s.client.XAutoClaim(ctx, &redis.XAutoClaimArgs{
+			Stream:   subConfig.Stream,
+			Group:    subConfig.ConsumerGroup,
+			Consumer: subConfig.ConsumerID,
+			Start:    start,
+			Count:    int64(subConfig.BatchSize),
+		}).Result()
+		if err != nil && !errors.Is(err, redis.Nil) {
+			return fmt.Errorf("%w: failed to claim pending messages: %w", eventbus.ErrSubscriptionFailed, err)
+		}
+
+		for _, msg := range messages {
+			s.processMessage(ctx, subConfig, msg)
+		}
+
+		if next == "0-0" || next == "" {
+			break
+		}
+
+		start = next
+	}
+
+	return s.consume(ctx, subConfig)
+}
+
+// releasePartition releases the lease on partition i so another member takes it over at once.
+func (s *Subscriber) releasePartition(subConfig eventbus.SubscriptionConfig, i int, log *slog.Logger) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	leaseKey := partitionLeaseKey(subConfig.Stream, subConfig.ConsumerGroup, i)
+	if err := releaseLeaseScript.Run(ctx, s.client, []string{leaseKey}, subConfig.ConsumerID).Err(); err != nil {
+		log.WarnContext(ctx, "failed to release partition lease", slog.Int("partition", i), slog.Any(logKeyError, err))
+	}
+}
+
+// leavePartitions stops every worker, releases their leases and leaves the group,
+// so that the remaining members take the partitions over without waiting for expiry.
+func (s *Subscriber) leavePartitions(
+	subConfig eventbus.SubscriptionConfig, workers map[int]*partitionWorker, log *slog.Logger,
+) {
+	for i, worker := range workers {
+		worker.stop()
+		s.releasePartition(subConfig, i, log)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	key := partitionMembersKey(subConfig.Stream, subConfig.ConsumerGroup)
+	if err := s.client.ZRem(ctx, key, subConfig.ConsumerID).Err(); err != nil {
+		log.WarnContext(ctx, "failed to leave partitioned group", slog.Any(logKeyError, err))
+	}
+}
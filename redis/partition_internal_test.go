@@ -0,0 +1,53 @@
+package redis
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	eventbus "github.com/tclavelloux/promy-event-bus/eventbus"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRebalance_StopsPartitionsWithExpiringLeases(t *testing.T) {
+	// Redis is unreachable, so neither the membership nor the leases can be renewed
+	client := redis.NewClient(&redis.Options{Addr: "127.0.0.1:1", MaxRetries: -1})
+	defer client.Close()
+
+	s := &Subscriber{client: client, partitionLease: 300 * time.Millisecond}
+
+	newWorker := func(renewed time.Time) *partitionWorker {
+		ctx, cancel := context.WithCancel(context.Background())
+		worker := &partitionWorker{cancel: cancel, done: make(chan struct{}), renewed: renewed}
+		go func() {
+			defer close(worker.done)
+			<-ctx.Done()
+		}()
+
+		return worker
+	}
+
+	fresh := newWorker(time.Now())
+	expiring := newWorker(time.Now().Add(-250 * time.Millisecond))
+	workers := map[int]*partitionWorker{0: fresh, 1: expiring}
+	defer fresh.stop()
+
+	err := s.rebalance(context.Background(), eventbus.SubscriptionConfig{
+		Stream:        "events:test-partitions-lease",
+		ConsumerGroup: "test-partitions-lease-group",
+		ConsumerID:    "consumer-1",
+	}, 2, workers, make(chan error, 2), slog.New(slog.NewTextHandler(io.Discard, nil)))
+	require.Error(t, err)
+
+	assert.Equal(t, map[int]*partitionWorker{0: fresh}, workers, "only the worker whose lease expires before the next renewal is stopped")
+	select {
+	case <-expiring.done:
+	default:
+		t.Fatal("the expiring worker was not stopped")
+	}
+}
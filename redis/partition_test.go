@@ -0,0 +1,254 @@
+//nolint:all // Test file
+package redis_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	eventbus "github.com/tclavelloux/promy-event-bus/eventbus"
+	"github.com/tclavelloux/promy-event-bus/redis"
+	"github.com/tclavelloux/promy-event-bus/testutil"
+
+	goredis "github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newPartitionClient(t *testing.T, stream, group string, partitions int) *goredis.Client {
+	t.Helper()
+
+	client := goredis.NewClient(&goredis.Options{Addr: "localhost:6379", DB: 1})
+	t.Cleanup(func() { client.Close() })
+
+	keys := []string{stream, "eventbus:partitions:" + stream + ":" + group + ":members"}
+	for i := range partitions {
+		keys = append(keys, fmt.Sprintf("%s:%d", stream, i), fmt.Sprintf("eventbus:partitions:%s:%s:%d", stream, group, i))
+	}
+	require.NoError(t, client.Del(context.Background(), keys...).Err())
+
+	return client
+}
+
+func TestPublisher_Partitions(t *testing.T) {
+	const stream = "events:test-partitions-publish"
+
+	client := newPartitionClient(t, stream, "", 4)
+	ctx := context.Background()
+
+	publisher, err := redis.NewPublisher(eventbus.RedisConfig{
+		DSN:        "redis://localhost:6379/1",
+		Partitions: map[string]int{stream: 4},
+	})
+	require.NoError(t, err)
+	defer publisher.Close()
+
+	for i := range 20 {
+		event := testutil.NewTestEvent("promotion.updated", map[string]any{"seq": i})
+		event.Key = fmt.Sprintf("promo-%d", i%5)
+		require.NoError(t, publisher.Publish(ctx, stream, event))
+	}
+	require.NoError(t, publisher.PublishBatch(ctx, stream, []eventbus.Event{
+		testutil.NewTestEvent("promotion.updated", nil),
+		testutil.NewTestEvent("promotion.updated", nil),
+	}))
+
+	length, err := client.XLen(ctx, stream).Result()
+	require.NoError(t, err)
+	assert.Zero(t, length, "events go to the partitions only")
+
+	total := int64(0)
+	for i := range 4 {
+		length, err := client.XLen(ctx, fmt.Sprintf("%s:%d", stream, i)).Result()
+		require.NoError(t, err)
+		total += length
+	}
+	assert.Equal(t, int64(22), total)
+}
+
+func TestSubscriber_Partitions(t *testing.T) {
+	const (
+		stream     = "events:test-partitions"
+		group      = "test-partitions-group"
+		partitions = 4
+	)
+
+	newPartitionClient(t, stream, group, partitions)
+	config := eventbus.Config{Redis: eventbus.RedisConfig{
+		DSN:        "redis://localhost:6379/1",
+		Partitions: map[string]int{stream: partitions},
+	}}
+
+	publisher, err := redis.NewPublisher(config.Redis)
+	require.NoError(t, err)
+	defer publisher.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	type delivery struct {
+		consumer string
+		key      string
+		seq      int
+	}
+	deliveries := make(chan delivery, 100)
+
+	subscribe := func(ctx context.Context, consumer string) {
+		subscriber, err := redis.NewSubscriber(config, redis.WithPartitionLease(300*time.Millisecond))
+		require.NoError(t, err)
+		t.Cleanup(func() { subscriber.Close() })
+
+		go subscriber.Subscribe(ctx, eventbus.SubscriptionConfig{
+			Stream:        stream,
+			ConsumerGroup: group,
+			ConsumerID:    consumer,
+			BlockDuration: 100 * time.Millisecond,
+			Handler: func(_ context.Context, event eventbus.Event) error {
+				var data struct {
+					Key string `json:"key"`
+					Seq int    `json:"seq"`
+				}
+				require.NoError(t, json.Unmarshal([]byte(event.Data()), &data))
+				deliveries <- delivery{consumer: consumer, key: data.Key, seq: data.Seq}
+				return nil
+			},
+		})
+	}
+
+	firstCtx, stopFirst := context.WithCancel(ctx)
+	defer stopFirst()
+	subscribe(firstCtx, "consumer-1")
+	subscribe(ctx, "consumer-2")
+
+	// Wait for the partitions to be balanced
+	time.Sleep(time.Second)
+
+	publish := func(from, to int) {
+		for seq := from; seq < to; seq++ {
+			key := fmt.Sprintf("promo-%d", seq%8)
+			event := testutil.NewTestEvent("promotion.updated", map[string]any{"key": key, "seq": seq})
+			event.Key = key
+			require.NoError(t, publisher.Publish(ctx, stream, event))
+		}
+	}
+
+	receive := func(n int) []delivery {
+		got := make([]delivery, 0, n)
+		for len(got) < n {
+			select {
+			case d := <-deliveries:
+				got = append(got, d)
+			case <-ctx.Done():
+				t.Fatalf("timed out after %d of %d deliveries", len(got), n)
+			}
+		}
+		return got
+	}
+
+	publish(0, 40)
+	got := receive(40)
+
+	consumers := map[string]bool{}
+	owner := map[string]string{}
+	last := map[string]int{}
+	for _, d := range got {
+		consumers[d.consumer] = true
+		if prev, ok := owner[d.key]; ok {
+			assert.Equal(t, prev, d.consumer, "a key is consumed by the owner of its partition")
+		}
+		owner[d.key] = d.consumer
+		if prev, ok := last[d.key]; ok {
+			assert.Greater(t, d.seq, prev, "events of a key are consumed in order")
+		}
+		last[d.key] = d.seq
+	}
+	assert.Len(t, consumers, 2, "partitions are balanced across consumers")
+
+	// The remaining consumer takes every partition over
+	stopFirst()
+	time.Sleep(time.Second)
+
+	publish(40, 56)
+	for _, d := range receive(16) {
+		assert.Equal(t, "consumer-2", d.consumer)
+	}
+
+	select {
+	case d := <-deliveries:
+		t.Fatalf("unexpected delivery %+v", d)
+	case <-time.After(200 * time.Millisecond):
+	}
+}
+
+func TestSubscriber_PartitionRetriesInOrder(t *testing.T) {
+	const (
+		stream     = "events:test-partitions-retry"
+		group      = "test-partitions-retry-group"
+		partitions = 2
+	)
+
+	client := newPartitionClient(t, stream, group, partitions)
+	config := eventbus.Config{Redis: eventbus.RedisConfig{
+		DSN:        "redis://localhost:6379/1",
+		Partitions: map[string]int{stream: partitions},
+	}}
+
+	publisher, err := redis.NewPublisher(config.Redis)
+	require.NoError(t, err)
+	defer publisher.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	for seq := range 3 {
+		event := testutil.NewTestEvent("promotion.updated", map[string]any{"seq": seq})
+		event.Key = "promo-1"
+		require.NoError(t, publisher.Publish(ctx, stream, event))
+	}
+
+	subscriber, err := redis.NewSubscriber(config, redis.WithPartitionLease(300*time.Millisecond))
+	require.NoError(t, err)
+	defer subscriber.Close()
+
+	seqs := make(chan int, 10)
+	failures := 2
+	go subscriber.Subscribe(ctx, eventbus.SubscriptionConfig{
+		Stream:        stream,
+		ConsumerGroup: group,
+		ConsumerID:    "consumer-1",
+		BlockDuration: 100 * time.Millisecond,
+		Handler: func(_ context.Context, event eventbus.Event) error {
+			var data struct {
+				Seq int `json:"seq"`
+			}
+			require.NoError(t, json.Unmarshal([]byte(event.Data()), &data))
+			seqs <- data.Seq
+			if data.Seq == 0 && failures > 0 {
+				failures--
+				return fmt.Errorf("attempt failed")
+			}
+			return nil
+		},
+	})
+
+	got := make([]int, 0, 5)
+	for len(got) < 5 {
+		select {
+		case seq := <-seqs:
+			got = append(got, seq)
+		case <-ctx.Done():
+			t.Fatalf("timed out after deliveries %v", got)
+		}
+	}
+	assert.Equal(t, []int{0, 0, 0, 1, 2}, got, "later events of the partition wait for the retries")
+
+	total := int64(0)
+	for i := range partitions {
+		length, err := client.XLen(ctx, fmt.Sprintf("%s:%d", stream, i)).Result()
+		require.NoError(t, err)
+		total += length
+	}
+	assert.Equal(t, int64(3), total, "retries are not re-added to the partition")
+}
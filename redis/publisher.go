@@ -4,8 +4,13 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"log/slog"
 	"time"
 
+	"github.com/tclavelloux/promy-event-bus/claimcheck"
+	"github.com/tclavelloux/promy-event-bus/cloudevents"
+	"github.com/tclavelloux/promy-event-bus/codec"
+	"github.com/tclavelloux/promy-event-bus/encryption"
 	eventbus "github.com/tclavelloux/promy-event-bus/eventbus"
 
 	"github.com/redis/go-redis/v9"
@@ -18,158 +23,358 @@ const (
 
 // Publisher implements EventPublisher for Redis Streams.
 type Publisher struct {
-	client *redis.Client
+	client redis.UniversalClient
 	config eventbus.RedisConfig
+
+	// ownsClient is set when the publisher built client, and so closes it.
+	ownsClient bool
+
+	tracing tracing
+	metrics eventbus.Metrics
+	logger  *slog.Logger
+
+	// dedupWindow enables idempotent publishing when positive (see WithIdempotentPublish).
+	dedupWindow time.Duration
+
+	compression          Compression
+	compressionThreshold int
+
+	keys          encryption.KeyProvider
+	encryptFields map[string][]string
+
+	codec        codec.Codec
+	streamCodecs map[string]codec.Codec
+
+	// cloudEventsSource enables structured CloudEvents payloads when set (see WithCloudEvents).
+	cloudEventsSource string
+
+	blobs                claimcheck.Store
+	claimCheckThreshold  int
+	claimCheckSweepGrace time.Duration
 }
 
-// NewPublisher creates a new Redis publisher.
-func NewPublisher(config eventbus.RedisConfig) (*Publisher, error) {
-	opts, err := redis.ParseURL(config.DSN)
-	if err != nil {
-		return nil, fmt.Errorf("invalid Redis DSN: %w", err)
-	}
+// NewPublisher creates a new Redis publisher (see eventbus.RedisConfig for Sentinel and Cluster).
+func NewPublisher(config eventbus.RedisConfig, opts ...Option) (*Publisher, error) {
+	o := newOptions(opts)
 
-	// Apply configuration
-	if config.PoolSize > 0 {
-		opts.PoolSize = config.PoolSize
-	}
-	if config.MaxRetries > 0 {
-		opts.MaxRetries = config.MaxRetries
-	}
-	if config.MinRetryBackoff > 0 {
-		opts.MinRetryBackoff = config.MinRetryBackoff
-	}
-	if config.MaxRetryBackoff > 0 {
-		opts.MaxRetryBackoff = config.MaxRetryBackoff
-	}
-	if config.DialTimeout > 0 {
-		opts.DialTimeout = config.DialTimeout
-	}
-	if config.ReadTimeout > 0 {
-		opts.ReadTimeout = config.ReadTimeout
-	}
-	if config.WriteTimeout > 0 {
-		opts.WriteTimeout = config.WriteTimeout
+	client, err := newClient(config, o)
+	if err != nil {
+		return nil, err
 	}
 
-	client := redis.NewClient(opts)
+	p := newPublisher(client, config, o)
+	p.ownsClient = true
 
-	// Test connection
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
+	return p, nil
+}
 
-	if err := client.Ping(ctx).Err(); err != nil {
-		return nil, fmt.Errorf("failed to connect to Redis: %w", err)
-	}
+// NewPublisherFromClient creates a publisher over an existing client, e.g. one shared
+// with a Subscriber or the rest of the service. It does not ping Redis, and Close leaves
+// client open.
+func NewPublisherFromClient(client redis.UniversalClient, config eventbus.RedisConfig, opts ...Option) *Publisher {
+	return newPublisher(client, config, newOptions(opts))
+}
 
+func newPublisher(client redis.UniversalClient, config eventbus.RedisConfig, o options) *Publisher {
 	return &Publisher{
-		client: client,
-		config: config,
-	}, nil
+		client:  client,
+		config:  config,
+		tracing: newTracing(o),
+		metrics: o.metrics,
+		logger:  o.logger,
+
+		dedupWindow: o.dedupWindow,
+
+		compression:          o.compression,
+		compressionThreshold: o.compressionThreshold,
+
+		keys:          o.keys,
+		encryptFields: o.encryptFields,
+
+		codec:        o.codec,
+		streamCodecs: o.streamCodecs,
+
+		cloudEventsSource: o.cloudEventsSource,
+
+		blobs:                o.blobs,
+		claimCheckThreshold:  o.claimCheckThreshold,
+		claimCheckSweepGrace: o.claimCheckSweepGrace,
+	}
 }
 
 // Publish publishes a single event to Redis Streams.
 func (p *Publisher) Publish(ctx context.Context, stream string, event eventbus.Event) error {
-	// Validate struct tags first (required fields, formats, constraints)
-	if err := eventbus.ValidateStruct(event); err != nil {
-		return err
-	}
+	_, err := p.PublishWithResult(ctx, stream, event)
 
-	// Validate event business rules (event-specific validation)
-	if err := event.Validate(); err != nil {
-		return err
-	}
+	return err
+}
+
+// PublishWithResult publishes a single event and reports the ID of the stream entry.
+// With WithIdempotentPublish, an event whose ID was already published to stream within
+// the window is not written again: the result holds the original message ID and Duplicate is set.
+// If stream is partitioned, the event goes to the partition of its partition key
+// (see eventbus.PartitionKeyCarrier).
+func (p *Publisher) PublishWithResult(
+	ctx context.Context, stream string, event eventbus.Event,
+) (result eventbus.PublishResult, err error) {
+	start := time.Now()
+	ctx, span := p.tracing.startPublish(ctx, stream, []eventbus.Event{event})
+	defer func() {
+		endSpan(span, err)
+		p.observe(ctx, []eventbus.StreamEvent{{Stream: stream, Event: event}}, start, err)
+	}()
 
-	// Serialize metadata
-	metadata := map[string]any{
-		"id":        event.EventID(),
-		"type":      event.EventType(),
-		"timestamp": event.EventTime().Format(time.RFC3339),
-		"version":   "1.0",
-		"attempt":   1,
+	if err := validateEvent(event); err != nil {
+		return eventbus.PublishResult{}, err
 	}
-	metadataJSON, err := json.Marshal(metadata)
+
+	msg, err := p.encodeMessage(ctx, stream, event, time.Now())
 	if err != nil {
-		return fmt.Errorf("failed to marshal metadata: %w", err)
+		return eventbus.PublishResult{}, err
 	}
 
-	// Serialize payload
-	payloadJSON, err := json.Marshal(event)
+	target := p.targetStream(stream, event)
+	retention := streamRetention(p.config, target)
+	if p.dedupWindow > 0 {
+		result, err = p.publishIdempotent(ctx, target, event.EventID(), msg, retention)
+	} else {
+		result.MessageID, err = p.publishOnce(ctx, target, msg, retention)
+	}
 	if err != nil {
-		return fmt.Errorf("failed to marshal payload: %w", err)
+		return eventbus.PublishResult{}, fmt.Errorf("%w: %w", eventbus.ErrPublishFailed, err)
 	}
 
-	// Publish to Redis Stream
+	span.SetAttributes(
+		attrMessagingMessageID.String(result.MessageID),
+		attrEventDuplicate.Bool(result.Duplicate),
+	)
+
+	return result, nil
+}
+
+// publishOnce appends msg to stream with XADD.
+func (p *Publisher) publishOnce(
+	ctx context.Context, stream string, msg streamMessage, retention eventbus.StreamRetention,
+) (string, error) {
 	args := &redis.XAddArgs{
 		Stream: stream,
-		Values: map[string]any{
-			fieldMetadata: string(metadataJSON),
-			fieldPayload:  string(payloadJSON),
-		},
+		Values: msg.values(),
 	}
+	applyRetention(args, retention)
+
+	return p.client.XAdd(ctx, args).Result()
+}
 
-	if err := p.client.XAdd(ctx, args).Err(); err != nil {
-		return fmt.Errorf("%w: %w", eventbus.ErrPublishFailed, err)
+// publishIdempotent appends msg to stream unless eventID was published within the dedup window.
+func (p *Publisher) publishIdempotent(
+	ctx context.Context, stream, eventID string, msg streamMessage, retention eventbus.StreamRetention,
+) (eventbus.PublishResult, error) {
+	strategy, threshold := trimArgs(retention)
+
+	reply, err := publishIdempotentScript.Run(ctx, p.client,
+		[]string{stream, dedupKey(stream, eventID)},
+		dedupWindowArg(p.dedupWindow), strategy, threshold, msg.metadata, msg.payload,
+	).Slice()
+	if err != nil {
+		return eventbus.PublishResult{}, err
 	}
 
-	return nil
+	result := scriptResult(reply[0], reply[1])
+	if result.Duplicate {
+		p.deleteDuplicateClaimCheck(ctx, stream, result.MessageID, msg)
+	}
+
+	return result, nil
 }
 
-// PublishBatch publishes multiple events in a pipeline.
-func (p *Publisher) PublishBatch(ctx context.Context, stream string, events []eventbus.Event) error {
+// PublishBatch publishes multiple events to stream atomically.
+// If any event is invalid or cannot be written, nothing is published and a
+// *eventbus.BatchError reports the result of each event.
+// With WithIdempotentPublish, events already published within the window are skipped.
+func (p *Publisher) PublishBatch(ctx context.Context, stream string, events []eventbus.Event) (err error) {
 	if len(events) == 0 {
 		return nil
 	}
 
-	// Validate all events first before publishing batch
-	for _, event := range events {
-		if err := eventbus.ValidateStruct(event); err != nil {
-			return err
-		}
-		if err := event.Validate(); err != nil {
-			return err
-		}
+	batch := make([]eventbus.StreamEvent, len(events))
+	for i, event := range events {
+		batch[i] = eventbus.StreamEvent{Stream: stream, Event: event}
 	}
 
-	pipe := p.client.Pipeline()
+	start := time.Now()
+	ctx, span := p.tracing.startPublish(ctx, stream, events)
+	defer func() {
+		endSpan(span, err)
+		p.observe(ctx, batch, start, err)
+	}()
+
+	_, err = p.publishAtomic(ctx, batch)
+
+	return err
+}
 
-	for _, event := range events {
-		metadata := map[string]any{
-			"id":        event.EventID(),
-			"type":      event.EventType(),
-			"timestamp": event.EventTime().Format(time.RFC3339),
-			"version":   "1.0",
-			"attempt":   1,
+// PublishMulti publishes events to their streams atomically, e.g. a
+// subscription.started event together with the user event it implies.
+// If any event is invalid or cannot be written, nothing is published and a
+// *eventbus.BatchError reports the result of each event.
+//
+// With Redis Cluster, all streams of one call must hash to the same slot, which
+// partitions of a partitioned stream do not.
+func (p *Publisher) PublishMulti(ctx context.Context, events []eventbus.StreamEvent) (err error) {
+	if len(events) == 0 {
+		return nil
+	}
+
+	batch := make([]eventbus.Event, len(events))
+	for i, se := range events {
+		batch[i] = se.Event
+	}
+
+	start := time.Now()
+	ctx, span := p.tracing.startPublish(ctx, "", batch)
+	defer func() {
+		endSpan(span, err)
+		p.observe(ctx, events, start, err)
+	}()
+
+	_, err = p.publishAtomic(ctx, events)
+
+	return err
+}
+
+// observe records metrics and logs for the outcome of publishing events.
+func (p *Publisher) observe(ctx context.Context, events []eventbus.StreamEvent, start time.Time, err error) {
+	duration := time.Since(start)
+
+	for _, se := range events {
+		p.metrics.EventPublished(se.Stream, se.Event.EventType(), duration, err)
+
+		log := eventLogger(p.logger, se.Event).With(slog.String(logKeyStream, se.Stream))
+		if err != nil {
+			log.ErrorContext(ctx, "failed to publish event", slog.Any(logKeyError, err))
+		} else {
+			log.DebugContext(ctx, "event published")
 		}
-		metadataJSON, err := json.Marshal(metadata)
+	}
+}
+
+// validateEvent checks struct tags first (required fields, formats, constraints),
+// then event business rules (event-specific validation).
+func validateEvent(event eventbus.Event) error {
+	if err := eventbus.ValidateStruct(event); err != nil {
+		return err
+	}
+
+	return event.Validate()
+}
+
+// streamMessage is the wire form of an event: the metadata and payload fields of a stream entry.
+type streamMessage struct {
+	metadata string
+	payload  string
+
+	// claimKey is the blob key of a claim-checked payload, or "" if payload holds it.
+	claimKey string
+}
+
+func (m streamMessage) values() map[string]any {
+	return map[string]any{
+		fieldMetadata: m.metadata,
+		fieldPayload:  m.payload,
+	}
+}
+
+// encodeMessage serializes event into its message for stream.
+// Headers are resolved from ctx and the event (see eventbus.OutgoingHeaders) and
+// carry the trace context of ctx. The payload is encoded with the codec of stream,
+// then fields are encrypted, then the payload is wrapped in a CloudEvent if enabled,
+// compressed and finally moved to the claim-check store if it is still too large.
+// publishAt is when the message is added to the stream.
+func (p *Publisher) encodeMessage(
+	ctx context.Context, stream string, event eventbus.Event, publishAt time.Time,
+) (streamMessage, error) {
+	headers := eventbus.OutgoingHeaders(ctx, event)
+	p.tracing.inject(ctx, headers)
+
+	payloadCodec := p.codecFor(stream)
+	contentType := payloadCodec.ContentType()
+
+	payload, err := payloadCodec.Marshal(event)
+	if err != nil {
+		return streamMessage{}, fmt.Errorf("failed to marshal payload: %w", err)
+	}
+
+	payload, err = p.encryptPayload(ctx, event.EventType(), contentType, payload, headers)
+	if err != nil {
+		return streamMessage{}, fmt.Errorf("failed to encrypt payload: %w", err)
+	}
+
+	if p.cloudEventsSource != "" {
+		payload, err = p.wrapCloudEvent(event, contentType, payload, headers)
 		if err != nil {
-			return fmt.Errorf("failed to marshal metadata: %w", err)
+			return streamMessage{}, fmt.Errorf("failed to build CloudEvent: %w", err)
 		}
 
-		payloadJSON, err := json.Marshal(event)
+		contentType = cloudevents.ContentType
+	}
+
+	metadata := newMetadata(
+		event.EventID(), event.EventType(), eventbus.EventVersion(event), event.EventTime(), headers, contentType,
+	)
+
+	if p.compression != CompressionNone && len(payload) >= p.compressionThreshold {
+		compressed, ok, err := compress(p.compression, payload)
 		if err != nil {
-			return fmt.Errorf("failed to marshal payload: %w", err)
+			return streamMessage{}, fmt.Errorf("failed to compress payload: %w", err)
 		}
+		if ok {
+			payload = compressed
+			metadata[metadataEncoding] = string(p.compression)
+		}
+	}
 
-		pipe.XAdd(ctx, &redis.XAddArgs{
-			Stream: stream,
-			Values: map[string]any{
-				fieldMetadata: string(metadataJSON),
-				fieldPayload:  string(payloadJSON),
-			},
-		})
+	claimKey, err := p.claimCheck(ctx, stream, event.EventID(), payload, publishAt)
+	if err != nil {
+		return streamMessage{}, fmt.Errorf("failed to store claim-checked payload: %w", err)
+	}
+	if claimKey != "" {
+		metadata[metadataClaimCheck] = claimKey
+		payload = nil
 	}
 
-	_, err := pipe.Exec(ctx)
+	metadataJSON, err := json.Marshal(metadata)
 	if err != nil {
-		return fmt.Errorf("%w: %w", eventbus.ErrPublishFailed, err)
+		return streamMessage{}, fmt.Errorf("failed to marshal metadata: %w", err)
 	}
 
-	return nil
+	return streamMessage{
+		metadata: string(metadataJSON),
+		payload:  string(payload),
+		claimKey: claimKey,
+	}, nil
+}
+
+// newMetadata returns the metadata of a new message.
+func newMetadata(
+	id, eventType, version string, timestamp time.Time, headers eventbus.Headers, contentType string,
+) map[string]any {
+	return map[string]any{
+		"id":                id,
+		"type":              eventType,
+		"timestamp":         timestamp.Format(time.RFC3339),
+		"version":           version,
+		"attempt":           1,
+		"headers":           headers,
+		metadataContentType: contentType,
+	}
 }
 
-// Close closes the Redis connection.
+// Close closes the Redis connection, unless the client was provided by the caller.
 func (p *Publisher) Close() error {
+	if !p.ownsClient {
+		return nil
+	}
+
 	return p.client.Close()
 }
 
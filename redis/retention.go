@@ -0,0 +1,70 @@
+package redis
+
+import (
+	"strconv"
+	"time"
+
+	eventbus "github.com/tclavelloux/promy-event-bus/eventbus"
+	"github.com/tclavelloux/promy-event-bus/registry"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// streamRetention resolves the retention of stream: a non-zero override in
+// config wins, then the retention declared in the schema registry. A partition gets
+// the retention of its stream, with MaxLen split evenly across partitions.
+func streamRetention(config eventbus.RedisConfig, stream string) eventbus.StreamRetention {
+	if base, partitions, ok := splitPartition(config, stream); ok {
+		retention := streamRetention(config, base)
+		if retention.MaxLen > 0 {
+			retention.MaxLen = max(1, (retention.MaxLen+int64(partitions)-1)/int64(partitions))
+		}
+
+		return retention
+	}
+
+	if override := config.Retention[stream]; !override.IsZero() {
+		return override
+	}
+
+	if declared, ok := registry.Default().Stream(stream); ok {
+		return eventbus.StreamRetention{
+			MaxLen: declared.Retention.MaxLen,
+			MaxAge: declared.Retention.MaxAge,
+		}
+	}
+
+	return eventbus.StreamRetention{}
+}
+
+// Trim strategies of XADD.
+const (
+	trimMaxLen = "MAXLEN"
+	trimMinID  = "MINID"
+)
+
+// trimArgs returns the XADD trim strategy and threshold for retention,
+// or empty strings when the stream is not trimmed.
+func trimArgs(retention eventbus.StreamRetention) (strategy, threshold string) {
+	switch {
+	case retention.MaxLen > 0:
+		return trimMaxLen, strconv.FormatInt(retention.MaxLen, 10)
+	case retention.MaxAge > 0:
+		return trimMinID, strconv.FormatInt(time.Now().Add(-retention.MaxAge).UnixMilli(), 10)
+	default:
+		return "", ""
+	}
+}
+
+// applyRetention sets approximate trimming on args according to retention.
+func applyRetention(args *redis.XAddArgs, retention eventbus.StreamRetention) {
+	strategy, threshold := trimArgs(retention)
+	switch strategy {
+	case trimMaxLen:
+		args.MaxLen = retention.MaxLen
+		args.Approx = true
+	case trimMinID:
+		args.MinID = threshold
+		args.Approx = true
+	}
+}
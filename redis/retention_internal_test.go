@@ -0,0 +1,75 @@
+package redis
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	eventbus "github.com/tclavelloux/promy-event-bus/eventbus"
+	"github.com/tclavelloux/promy-event-bus/streams"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStreamRetention(t *testing.T) {
+	t.Run("uses registry retention", func(t *testing.T) {
+		retention := streamRetention(eventbus.RedisConfig{}, streams.StreamUsers)
+
+		assert.Equal(t, 720*time.Hour, retention.MaxAge)
+	})
+
+	t.Run("override wins over registry", func(t *testing.T) {
+		config := eventbus.RedisConfig{
+			Retention: map[string]eventbus.StreamRetention{
+				streams.StreamUsers: {MaxLen: 1000},
+			},
+		}
+
+		assert.Equal(t, eventbus.StreamRetention{MaxLen: 1000}, streamRetention(config, streams.StreamUsers))
+	})
+
+	t.Run("unknown stream is not trimmed", func(t *testing.T) {
+		assert.True(t, streamRetention(eventbus.RedisConfig{}, "events:unknown").IsZero())
+	})
+
+	t.Run("partitions split max length", func(t *testing.T) {
+		config := eventbus.RedisConfig{
+			Retention:  map[string]eventbus.StreamRetention{"events:test": {MaxLen: 1000}},
+			Partitions: map[string]int{"events:test": 3},
+		}
+
+		assert.Equal(t, eventbus.StreamRetention{MaxLen: 334}, streamRetention(config, "events:test:2"))
+		assert.True(t, streamRetention(config, "events:test:3").IsZero(), "not a partition")
+	})
+}
+
+func TestApplyRetention(t *testing.T) {
+	t.Run("max length", func(t *testing.T) {
+		args := &redis.XAddArgs{}
+		applyRetention(args, eventbus.StreamRetention{MaxLen: 1000})
+
+		assert.Equal(t, int64(1000), args.MaxLen)
+		assert.True(t, args.Approx)
+		assert.Empty(t, args.MinID)
+	})
+
+	t.Run("max age", func(t *testing.T) {
+		args := &redis.XAddArgs{}
+		applyRetention(args, eventbus.StreamRetention{MaxAge: time.Hour})
+
+		minID, err := strconv.ParseInt(args.MinID, 10, 64)
+		require.NoError(t, err)
+		assert.InDelta(t, time.Now().Add(-time.Hour).UnixMilli(), minID, 1000)
+		assert.True(t, args.Approx)
+		assert.Zero(t, args.MaxLen)
+	})
+
+	t.Run("no retention", func(t *testing.T) {
+		args := &redis.XAddArgs{}
+		applyRetention(args, eventbus.StreamRetention{})
+
+		assert.Equal(t, &redis.XAddArgs{}, args)
+	})
+}
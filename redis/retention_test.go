@@ -0,0 +1,45 @@
+//nolint:all // Test file
+package redis_test
+
+import (
+	"context"
+	"testing"
+
+	eventbus "github.com/tclavelloux/promy-event-bus/eventbus"
+	"github.com/tclavelloux/promy-event-bus/redis"
+	"github.com/tclavelloux/promy-event-bus/testutil"
+
+	goredis "github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPublisher_Retention(t *testing.T) {
+	const stream = "events:test-retention"
+
+	client := goredis.NewClient(&goredis.Options{Addr: "localhost:6379", DB: 1})
+	defer client.Close()
+
+	ctx := context.Background()
+	require.NoError(t, client.Del(ctx, stream).Err())
+
+	publisher, err := redis.NewPublisher(eventbus.RedisConfig{
+		DSN: "redis://localhost:6379/1",
+		Retention: map[string]eventbus.StreamRetention{
+			stream: {MaxLen: 10},
+		},
+	})
+	require.NoError(t, err)
+	defer publisher.Close()
+
+	const published = 500
+	for i := 0; i < published; i++ {
+		event := testutil.NewTestEvent("promotion.created", map[string]any{"promotion_id": "promo-retention"})
+		require.NoError(t, publisher.Publish(ctx, stream, event))
+	}
+
+	// Trimming is approximate: Redis only drops whole macro nodes.
+	length, err := client.XLen(ctx, stream).Result()
+	require.NoError(t, err)
+	assert.Less(t, length, int64(published))
+}
@@ -0,0 +1,353 @@
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+
+	eventbus "github.com/tclavelloux/promy-event-bus/eventbus"
+)
+
+// Schedule keys. The hash tag keeps them in one slot, so scripts can touch them
+// together with Redis Cluster.
+const (
+	// scheduleDueKey is a sorted set of scheduled event IDs scored by due time in Unix ms.
+	scheduleDueKey = "{eventbus:schedule}:due"
+
+	// scheduleEventsKey is a hash of the scheduled messages by event ID.
+	scheduleEventsKey = "{eventbus:schedule}:events"
+
+	// scheduleLeaderKey holds the owner of the scheduler lease.
+	scheduleLeaderKey = "{eventbus:schedule}:leader"
+)
+
+// Default Scheduler settings.
+const (
+	defaultScheduleBatchSize    = 100
+	defaultSchedulePollInterval = time.Second
+	defaultScheduleLeaseTTL     = 30 * time.Second
+	defaultScheduleRetryDelay   = 10 * time.Second
+)
+
+// scheduledMessage is an encoded message waiting for its due time.
+type scheduledMessage struct {
+	Stream     string `json:"stream"`
+	Metadata   string `json:"metadata"`
+	Payload    string `json:"payload"`
+	ClaimCheck string `json:"claim_check,omitempty"`
+}
+
+// PublishAt schedules event to be published to stream at the given time. The event is
+// validated and encoded now, with the headers of ctx, and stored in Redis until a
+// Scheduler adds it to stream. Scheduling an event ID again replaces its schedule.
+func (p *Publisher) PublishAt(ctx context.Context, stream string, event eventbus.Event, at time.Time) error {
+	if err := validateEvent(event); err != nil {
+		return err
+	}
+
+	// Claim-checked payloads are keyed by the time they reach the stream.
+	publishAt := at
+	if now := time.Now(); publishAt.Before(now) {
+		publishAt = now
+	}
+
+	msg, err := p.encodeMessage(ctx, stream, event, publishAt)
+	if err != nil {
+		return err
+	}
+
+	target := p.targetStream(stream, event)
+	data, err := json.Marshal(scheduledMessage{
+		Stream: target, Metadata: msg.metadata, Payload: msg.payload, ClaimCheck: msg.claimKey,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal scheduled message: %w", err)
+	}
+
+	_, err = p.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, scheduleEventsKey, event.EventID(), data)
+		pipe.ZAdd(ctx, scheduleDueKey, redis.Z{Score: float64(at.UnixMilli()), Member: event.EventID()})
+
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("%w: failed to schedule event: %w", eventbus.ErrPublishFailed, err)
+	}
+
+	eventLogger(p.logger, event).DebugContext(ctx, "event scheduled",
+		slog.String(logKeyStream, stream), slog.Time("due", at))
+
+	return nil
+}
+
+// PublishAfter schedules event to be published to stream once delay has elapsed (see PublishAt).
+func (p *Publisher) PublishAfter(ctx context.Context, stream string, event eventbus.Event, delay time.Duration) error {
+	return p.PublishAt(ctx, stream, event, time.Now().Add(delay))
+}
+
+// CancelScheduled removes the scheduled event with the given ID and reports whether
+// it was still scheduled. An event the Scheduler is already moving may still be published.
+func (p *Publisher) CancelScheduled(ctx context.Context, eventID string) (bool, error) {
+	var removed *redis.IntCmd
+
+	_, err := p.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		removed = pipe.ZRem(ctx, scheduleDueKey, eventID)
+		pipe.HDel(ctx, scheduleEventsKey, eventID)
+
+		return nil
+	})
+	if err != nil {
+		return false, fmt.Errorf("failed to cancel scheduled event: %w", err)
+	}
+
+	return removed.Val() > 0, nil
+}
+
+// SchedulerConfig configures a Scheduler.
+type SchedulerConfig struct {
+	// Owner identifies this scheduler instance in the lease.
+	// Default: "<hostname>-<random UUID>"
+	Owner string
+
+	// BatchSize is the maximum number of due events moved per pass.
+	// Default: 100
+	BatchSize int
+
+	// PollInterval is the pause between passes when no more events are due or the
+	// lease is held by another scheduler. It bounds how late events are published.
+	// Default: 1s
+	PollInterval time.Duration
+
+	// LeaseTTL is how long the lease stays valid without renewal. Only the lease
+	// owner moves events, so another scheduler takes over at most LeaseTTL after a crash.
+	// It must be longer than a pass. Default: 30s
+	LeaseTTL time.Duration
+
+	// RetryDelay postpones a due event that failed to move, so that it does not hold up
+	// the events due after it. Default: 10s
+	RetryDelay time.Duration
+}
+
+// Scheduler moves events scheduled with PublishAt and PublishAfter to their streams once
+// they are due.
+//
+// Only the scheduler holding the lease moves events, so every service instance can run
+// one. Events are moved in due order. Delivery is at-least-once: a scheduler that
+// crashes between adding an event to its stream and removing it from the schedule
+// publishes it again, unless the publisher uses WithIdempotentPublish.
+type Scheduler struct {
+	publisher *Publisher
+	config    SchedulerConfig
+	logger    *slog.Logger
+}
+
+// NewScheduler creates a Scheduler publishing through publisher, with its retention,
+// deduplication and logger.
+func NewScheduler(publisher *Publisher, config SchedulerConfig) *Scheduler {
+	if config.Owner == "" {
+		hostname, _ := os.Hostname()
+		config.Owner = hostname + "-" + uuid.NewString()
+	}
+	if config.BatchSize <= 0 {
+		config.BatchSize = defaultScheduleBatchSize
+	}
+	if config.PollInterval <= 0 {
+		config.PollInterval = defaultSchedulePollInterval
+	}
+	if config.LeaseTTL <= 0 {
+		config.LeaseTTL = defaultScheduleLeaseTTL
+	}
+	if config.RetryDelay <= 0 {
+		config.RetryDelay = defaultScheduleRetryDelay
+	}
+
+	return &Scheduler{
+		publisher: publisher,
+		config:    config,
+		logger:    publisher.logger.With(slog.String("scheduler", config.Owner)),
+	}
+}
+
+// Run moves due events until ctx is cancelled, then releases the lease.
+// Pass failures are logged and retried.
+func (s *Scheduler) Run(ctx context.Context) error {
+	defer s.release()
+
+	for {
+		moved, err := s.MoveOnce(ctx)
+		if err != nil && ctx.Err() == nil {
+			s.logger.ErrorContext(ctx, "scheduler pass failed", slog.Any(logKeyError, err))
+		}
+
+		// Keep going without pause while full batches are due.
+		wait := s.config.PollInterval
+		if err == nil && moved == s.config.BatchSize {
+			wait = 0
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(wait):
+		}
+	}
+}
+
+// MoveOnce runs a single pass: it acquires the lease, then publishes the events that
+// are due, oldest first. Events that fail are postponed by RetryDelay and the pass goes
+// on; their errors are returned together. It returns the number of events published.
+func (s *Scheduler) MoveOnce(ctx context.Context) (int, error) {
+	client := s.publisher.client
+
+	leader, err := leaseScript.Run(ctx, client, []string{scheduleLeaderKey},
+		s.config.Owner, s.config.LeaseTTL.Milliseconds()).Bool()
+	if err != nil {
+		return 0, fmt.Errorf("failed to acquire scheduler lease: %w", err)
+	}
+	if !leader {
+		return 0, nil
+	}
+
+	due, err := client.ZRangeArgsWithScores(ctx, redis.ZRangeArgs{
+		Key:     scheduleDueKey,
+		ByScore: true,
+		Start:   "-inf",
+		Stop:    strconv.FormatInt(time.Now().UnixMilli(), 10),
+		Count:   int64(s.config.BatchSize),
+	}).Result()
+	if err != nil {
+		return 0, fmt.Errorf("failed to read due events: %w", err)
+	}
+
+	moved := 0
+	var errs []error
+	for _, z := range due {
+		eventID, _ := z.Member.(string)
+		if err := s.move(ctx, eventID, z.Score); err != nil {
+			errs = append(errs, err, s.postpone(ctx, eventID, z.Score))
+
+			continue
+		}
+
+		moved++
+	}
+
+	return moved, errors.Join(errs...)
+}
+
+// postpone moves the due time of eventID, due at score, RetryDelay ahead, unless it was
+// rescheduled meanwhile.
+func (s *Scheduler) postpone(ctx context.Context, eventID string, score float64) error {
+	err := postponeScript.Run(ctx, s.publisher.client, []string{scheduleDueKey}, eventID,
+		strconv.FormatFloat(score, 'f', -1, 64), time.Now().Add(s.config.RetryDelay).UnixMilli()).Err()
+	if err != nil {
+		return fmt.Errorf("failed to postpone scheduled event %s: %w", eventID, err)
+	}
+
+	return nil
+}
+
+// move publishes the scheduled event eventID, due at score, and removes it from the
+// schedule unless it was rescheduled meanwhile.
+func (s *Scheduler) move(ctx context.Context, eventID string, score float64) error {
+	p := s.publisher
+
+	data, err := p.client.HGet(ctx, scheduleEventsKey, eventID).Bytes()
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return fmt.Errorf("failed to read scheduled event %s: %w", eventID, err)
+	}
+
+	if err == nil {
+		var msg scheduledMessage
+		if err := json.Unmarshal(data, &msg); err != nil {
+			// Keep the schedule moving; the message can never be published.
+			s.logger.ErrorContext(ctx, "dropping invalid scheduled message",
+				slog.String(logKeyEventID, eventID), slog.Any(logKeyError, err))
+		} else if err := s.publish(ctx, eventID, msg); err != nil {
+			return fmt.Errorf("failed to publish scheduled event %s: %w", eventID, err)
+		}
+	}
+
+	err = unscheduleScript.Run(ctx, p.client, []string{scheduleDueKey, scheduleEventsKey},
+		eventID, strconv.FormatFloat(score, 'f', -1, 64)).Err()
+	if err != nil {
+		return fmt.Errorf("failed to unschedule event %s: %w", eventID, err)
+	}
+
+	return nil
+}
+
+// publish adds a scheduled message to its stream.
+func (s *Scheduler) publish(ctx context.Context, eventID string, scheduled scheduledMessage) error {
+	p := s.publisher
+	msg := streamMessage{metadata: scheduled.Metadata, payload: scheduled.Payload, claimKey: scheduled.ClaimCheck}
+	retention := streamRetention(p.config, scheduled.Stream)
+
+	var (
+		messageID string
+		err       error
+	)
+	if p.dedupWindow > 0 {
+		var result eventbus.PublishResult
+		result, err = p.publishIdempotent(ctx, scheduled.Stream, eventID, msg, retention)
+		messageID = result.MessageID
+	} else {
+		messageID, err = p.publishOnce(ctx, scheduled.Stream, msg, retention)
+	}
+	if err != nil {
+		return err
+	}
+
+	s.logger.DebugContext(ctx, "scheduled event published",
+		slog.String(logKeyEventID, eventID),
+		slog.String(logKeyStream, scheduled.Stream),
+		slog.String(logKeyMessageID, messageID))
+
+	return nil
+}
+
+// release gives up the lease if this scheduler holds it, so another one takes over at once.
+func (s *Scheduler) release() {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	err := releaseLeaseScript.Run(ctx, s.publisher.client, []string{scheduleLeaderKey}, s.config.Owner).Err()
+	if err != nil {
+		s.logger.WarnContext(ctx, "failed to release scheduler lease", slog.Any(logKeyError, err))
+	}
+}
+
+// unscheduleScript removes a moved event from the schedule, unless it was rescheduled
+// (its due time changed) after it was read.
+//
+// KEYS[1]: due set, KEYS[2]: events hash. ARGV: event ID, due time read.
+var unscheduleScript = redis.NewScript(`
+local score = redis.call('ZSCORE', KEYS[1], ARGV[1])
+if score and tonumber(score) == tonumber(ARGV[2]) then
+  redis.call('ZREM', KEYS[1], ARGV[1])
+  redis.call('HDEL', KEYS[2], ARGV[1])
+end
+
+return 0
+`)
+
+// postponeScript moves the due time of an event that failed to move, unless it was
+// rescheduled (its due time changed) after it was read.
+//
+// KEYS[1]: due set. ARGV: event ID, due time read, new due time.
+var postponeScript = redis.NewScript(`
+local score = redis.call('ZSCORE', KEYS[1], ARGV[1])
+if score and tonumber(score) == tonumber(ARGV[2]) then
+  redis.call('ZADD', KEYS[1], ARGV[3], ARGV[1])
+end
+
+return 0
+`)
@@ -0,0 +1,224 @@
+//nolint:all // Test file
+package redis_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	eventbus "github.com/tclavelloux/promy-event-bus/eventbus"
+	"github.com/tclavelloux/promy-event-bus/redis"
+	"github.com/tclavelloux/promy-event-bus/testutil"
+
+	goredis "github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newScheduleClient(t *testing.T, streams ...string) *goredis.Client {
+	t.Helper()
+
+	client := goredis.NewClient(&goredis.Options{Addr: "localhost:6379", DB: 1})
+	t.Cleanup(func() { client.Close() })
+
+	keys := append([]string{"{eventbus:schedule}:due", "{eventbus:schedule}:events", "{eventbus:schedule}:leader"}, streams...)
+	require.NoError(t, client.Del(context.Background(), keys...).Err())
+
+	return client
+}
+
+func TestScheduler_MovesDueEvents(t *testing.T) {
+	const stream = "events:test-schedule"
+
+	client := newScheduleClient(t, stream)
+	ctx := context.Background()
+	config := eventbus.RedisConfig{DSN: "redis://localhost:6379/1"}
+
+	// Scheduled by one instance...
+	publisher, err := redis.NewPublisher(config)
+	require.NoError(t, err)
+
+	later := testutil.NewTestEvent("subscription.expiring", map[string]any{"subscription_id": "sub-1"})
+	soon := testutil.NewTestEvent("promotion.started", map[string]any{"promotion_id": "promo-1"})
+	require.NoError(t, publisher.PublishAfter(ctx, stream, later, time.Hour))
+	require.NoError(t, publisher.PublishAt(eventbus.WithCorrelationID(ctx, "corr-1"), stream, soon, time.Now().Add(-time.Second)))
+	require.NoError(t, publisher.Close())
+
+	// ...and moved by another one after a restart
+	mover, err := redis.NewPublisher(config)
+	require.NoError(t, err)
+	defer mover.Close()
+
+	scheduler := redis.NewScheduler(mover, redis.SchedulerConfig{})
+
+	moved, err := scheduler.MoveOnce(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, moved)
+
+	messages, err := client.XRange(ctx, stream, "-", "+").Result()
+	require.NoError(t, err)
+	require.Len(t, messages, 1)
+
+	var metadata struct {
+		ID      string           `json:"id"`
+		Type    string           `json:"type"`
+		Headers eventbus.Headers `json:"headers"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(messages[0].Values["metadata"].(string)), &metadata))
+	assert.Equal(t, soon.ID, metadata.ID)
+	assert.Equal(t, "promotion.started", metadata.Type)
+	assert.Equal(t, "corr-1", metadata.Headers.CorrelationID())
+
+	// Nothing else is due
+	moved, err = scheduler.MoveOnce(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, moved)
+
+	scheduled, err := client.ZRange(ctx, "{eventbus:schedule}:due", 0, -1).Result()
+	require.NoError(t, err)
+	assert.Equal(t, []string{later.ID}, scheduled)
+}
+
+func TestScheduler_SkipsFailingEvents(t *testing.T) {
+	const (
+		stream = "events:test-schedule"
+		broken = "events:test-schedule-broken"
+	)
+
+	client := newScheduleClient(t, stream, broken)
+	ctx := context.Background()
+
+	publisher, err := redis.NewPublisher(eventbus.RedisConfig{DSN: "redis://localhost:6379/1"})
+	require.NoError(t, err)
+	defer publisher.Close()
+
+	// The first due event goes to a key that is not a stream, so adding it fails
+	require.NoError(t, client.Set(ctx, broken, "not a stream", 0).Err())
+	failing := testutil.NewTestEvent("promotion.started", map[string]any{"promotion_id": "promo-1"})
+	next := testutil.NewTestEvent("promotion.started", map[string]any{"promotion_id": "promo-2"})
+	require.NoError(t, publisher.PublishAt(ctx, broken, failing, time.Now().Add(-2*time.Second)))
+	require.NoError(t, publisher.PublishAt(ctx, stream, next, time.Now().Add(-time.Second)))
+
+	scheduler := redis.NewScheduler(publisher, redis.SchedulerConfig{RetryDelay: time.Minute})
+
+	moved, err := scheduler.MoveOnce(ctx)
+	assert.ErrorContains(t, err, failing.ID)
+	assert.Equal(t, 1, moved, "later due events are moved")
+
+	length, err := client.XLen(ctx, stream).Result()
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), length)
+
+	due, err := client.ZScore(ctx, "{eventbus:schedule}:due", failing.ID).Result()
+	require.NoError(t, err)
+	assert.Greater(t, due, float64(time.Now().Add(50*time.Second).UnixMilli()), "the failing event is postponed")
+
+	moved, err = scheduler.MoveOnce(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, moved)
+}
+
+func TestPublisher_CancelScheduled(t *testing.T) {
+	const stream = "events:test-schedule-cancel"
+
+	client := newScheduleClient(t, stream)
+	ctx := context.Background()
+
+	publisher, err := redis.NewPublisher(eventbus.RedisConfig{DSN: "redis://localhost:6379/1"})
+	require.NoError(t, err)
+	defer publisher.Close()
+
+	event := testutil.NewTestEvent("subscription.expiring", map[string]any{"subscription_id": "sub-1"})
+	require.NoError(t, publisher.PublishAt(ctx, stream, event, time.Now()))
+
+	cancelled, err := publisher.CancelScheduled(ctx, event.ID)
+	require.NoError(t, err)
+	assert.True(t, cancelled)
+
+	cancelled, err = publisher.CancelScheduled(ctx, event.ID)
+	require.NoError(t, err)
+	assert.False(t, cancelled)
+
+	moved, err := redis.NewScheduler(publisher, redis.SchedulerConfig{}).MoveOnce(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, moved)
+
+	length, err := client.XLen(ctx, stream).Result()
+	require.NoError(t, err)
+	assert.Zero(t, length)
+}
+
+func TestScheduler_Lease(t *testing.T) {
+	const stream = "events:test-schedule-lease"
+
+	newScheduleClient(t, stream)
+	ctx := context.Background()
+
+	publisher, err := redis.NewPublisher(eventbus.RedisConfig{DSN: "redis://localhost:6379/1"})
+	require.NoError(t, err)
+	defer publisher.Close()
+
+	leader := redis.NewScheduler(publisher, redis.SchedulerConfig{Owner: "leader"})
+	follower := redis.NewScheduler(publisher, redis.SchedulerConfig{Owner: "follower"})
+
+	_, err = leader.MoveOnce(ctx)
+	require.NoError(t, err)
+
+	require.NoError(t, publisher.PublishAt(ctx, stream, testutil.NewTestEvent("promotion.started", nil), time.Now()))
+
+	moved, err := follower.MoveOnce(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, moved, "the follower must not move events while the leader holds the lease")
+
+	// The leader releases its lease when it stops
+	runCtx, cancel := context.WithCancel(ctx)
+	cancel()
+	assert.ErrorIs(t, leader.Run(runCtx), context.Canceled)
+
+	moved, err = follower.MoveOnce(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, moved)
+}
+
+func TestScheduler_Run(t *testing.T) {
+	const stream = "events:test-schedule-run"
+
+	newScheduleClient(t, stream)
+	config := eventbus.Config{Redis: eventbus.RedisConfig{DSN: "redis://localhost:6379/1"}}
+
+	publisher, err := redis.NewPublisher(config.Redis)
+	require.NoError(t, err)
+	defer publisher.Close()
+
+	subscriber, err := redis.NewSubscriber(config)
+	require.NoError(t, err)
+	defer subscriber.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	received := make(chan eventbus.Event, 1)
+	go subscriber.Subscribe(ctx, eventbus.SubscriptionConfig{
+		Stream:        stream,
+		ConsumerGroup: "test-schedule-group",
+		ConsumerID:    "consumer-1",
+		Handler: func(_ context.Context, event eventbus.Event) error {
+			received <- event
+			return nil
+		},
+	})
+	go redis.NewScheduler(publisher, redis.SchedulerConfig{PollInterval: 50 * time.Millisecond}).Run(ctx)
+
+	event := testutil.NewTestEvent("subscription.expiring", map[string]any{"subscription_id": "sub-1"})
+	scheduledAt := time.Now()
+	require.NoError(t, publisher.PublishAfter(ctx, stream, event, 300*time.Millisecond))
+
+	select {
+	case got := <-received:
+		assert.Equal(t, event.ID, got.EventID())
+		assert.GreaterOrEqual(t, time.Since(scheduledAt), 300*time.Millisecond)
+	case <-ctx.Done():
+		t.Fatal("timed out waiting for scheduled event")
+	}
+}
@@ -5,10 +5,15 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"log/slog"
+	"maps"
 	"math"
 	"sync"
 	"time"
 
+	"github.com/tclavelloux/promy-event-bus/claimcheck"
+	"github.com/tclavelloux/promy-event-bus/codec"
+	"github.com/tclavelloux/promy-event-bus/encryption"
 	eventbus "github.com/tclavelloux/promy-event-bus/eventbus"
 	"github.com/tclavelloux/promy-event-bus/streams"
 
@@ -16,61 +21,76 @@ import (
 	"golang.org/x/sync/semaphore"
 )
 
+// errUndecodable fails events whose payload cannot be decoded, e.g. of an unknown content
+// type or encoding. They are dead-lettered without retries.
+var errUndecodable = errors.New("undecodable payload")
+
 // Subscriber implements EventSubscriber for Redis Streams.
 type Subscriber struct {
-	client *redis.Client
+	client redis.UniversalClient
 	config eventbus.Config
+
+	// ownsClient is set when the subscriber built client, and so closes it.
+	ownsClient bool
+
+	tracing tracing
+	metrics eventbus.Metrics
+	logger  *slog.Logger
+	keys    encryption.KeyProvider
+	codecs  map[string]codec.Codec
+	blobs   claimcheck.Store
+
+	upcasters *eventbus.Upcasters
+
+	// partitionLease is the lease TTL of partitions of partitioned streams (see WithPartitionLease).
+	partitionLease time.Duration
 }
 
-// NewSubscriber creates a new Redis subscriber.
-func NewSubscriber(config eventbus.Config) (*Subscriber, error) {
-	opts, err := redis.ParseURL(config.Redis.DSN)
-	if err != nil {
-		return nil, fmt.Errorf("invalid Redis DSN: %w", err)
-	}
+// NewSubscriber creates a new Redis subscriber (see eventbus.RedisConfig for Sentinel and Cluster).
+func NewSubscriber(config eventbus.Config, opts ...Option) (*Subscriber, error) {
+	o := newOptions(opts)
 
-	// Apply configuration
-	if config.Redis.PoolSize > 0 {
-		opts.PoolSize = config.Redis.PoolSize
-	}
-	if config.Redis.MaxRetries > 0 {
-		opts.MaxRetries = config.Redis.MaxRetries
-	}
-	if config.Redis.MinRetryBackoff > 0 {
-		opts.MinRetryBackoff = config.Redis.MinRetryBackoff
-	}
-	if config.Redis.MaxRetryBackoff > 0 {
-		opts.MaxRetryBackoff = config.Redis.MaxRetryBackoff
-	}
-	if config.Redis.DialTimeout > 0 {
-		opts.DialTimeout = config.Redis.DialTimeout
-	}
-	if config.Redis.ReadTimeout > 0 {
-		opts.ReadTimeout = config.Redis.ReadTimeout
-	}
-	if config.Redis.WriteTimeout > 0 {
-		opts.WriteTimeout = config.Redis.WriteTimeout
+	client, err := newClient(config.Redis, o)
+	if err != nil {
+		return nil, err
 	}
 
-	client := redis.NewClient(opts)
+	s := newSubscriber(client, config, o)
+	s.ownsClient = true
 
-	// Test connection
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
+	return s, nil
+}
 
-	if err := client.Ping(ctx).Err(); err != nil {
-		return nil, fmt.Errorf("failed to connect to Redis: %w", err)
-	}
+// NewSubscriberFromClient creates a subscriber over an existing client, e.g. one shared
+// with a Publisher or the rest of the service. It does not ping Redis, and Close leaves
+// client open.
+func NewSubscriberFromClient(client redis.UniversalClient, config eventbus.Config, opts ...Option) *Subscriber {
+	return newSubscriber(client, config, newOptions(opts))
+}
 
+func newSubscriber(client redis.UniversalClient, config eventbus.Config, o options) *Subscriber {
 	return &Subscriber{
-		client: client,
-		config: config,
-	}, nil
+		client:  client,
+		config:  config,
+		tracing: newTracing(o),
+		metrics: o.metrics,
+		logger:  o.logger,
+		keys:    o.keys,
+		codecs:  o.codecs,
+		blobs:   o.blobs,
+
+		upcasters: o.upcasters,
+
+		partitionLease: o.partitionLease,
+	}
 }
 
 // Subscribe starts consuming events from Redis Streams.
 //
-//nolint:cyclop // Event loop functions naturally have higher complexity
+// If the stream is partitioned (see eventbus.RedisConfig.Partitions), its partitions are
+// balanced across the consumers of the group and each owned partition is processed one
+// message at a time, in order, so MaxConcurrency is ignored. ConsumerID must then be
+// unique within the group.
 func (s *Subscriber) Subscribe(ctx context.Context, subConfig eventbus.SubscriptionConfig) error {
 	// Set defaults
 	if subConfig.MaxConcurrency <= 0 {
@@ -83,18 +103,54 @@ func (s *Subscriber) Subscribe(ctx context.Context, subConfig eventbus.Subscript
 		subConfig.BlockDuration = 1 * time.Second
 	}
 
+	if partitions := streamPartitions(s.config.Redis, subConfig.Stream); partitions > 1 {
+		return s.subscribePartitions(ctx, subConfig, partitions)
+	}
+
+	if err := s.createGroup(ctx, subConfig); err != nil {
+		return err
+	}
+
+	return s.consume(ctx, subConfig)
+}
+
+// createGroup creates the consumer group of subConfig if it doesn't exist.
+func (s *Subscriber) createGroup(ctx context.Context, subConfig eventbus.SubscriptionConfig) error {
+	log := subscriptionLogger(s.logger, subConfig)
+
 	// Create consumer group if it doesn't exist
 	err := s.client.XGroupCreateMkStream(ctx, subConfig.Stream, subConfig.ConsumerGroup, "0").Err()
-	if err != nil && err.Error() != "BUSYGROUP Consumer Group name already exists" {
+	switch {
+	case err == nil:
+		log.InfoContext(ctx, "consumer group created")
+	case err.Error() == "BUSYGROUP Consumer Group name already exists":
+		log.DebugContext(ctx, "consumer group already exists")
+	default:
+		log.ErrorContext(ctx, "failed to create consumer group", slog.Any(logKeyError, err))
+
 		return fmt.Errorf("%w: failed to create consumer group: %w", eventbus.ErrSubscriptionFailed, err)
 	}
 
+	return nil
+}
+
+// consume reads and processes new messages of the stream until ctx is cancelled.
+//
+//nolint:cyclop // Event loop functions naturally have higher complexity
+func (s *Subscriber) consume(ctx context.Context, subConfig eventbus.SubscriptionConfig) error {
+	log := subscriptionLogger(s.logger, subConfig)
+
+	log.InfoContext(ctx, "subscription started",
+		slog.Int("batch_size", subConfig.BatchSize), slog.Int("max_concurrency", subConfig.MaxConcurrency))
+
 	// Semaphore for concurrency control
 	sem := semaphore.NewWeighted(int64(subConfig.MaxConcurrency))
 
 	for {
 		select {
 		case <-ctx.Done():
+			log.InfoContext(ctx, "subscription stopped")
+
 			return ctx.Err()
 		default:
 			// Read events from stream
@@ -110,6 +166,13 @@ func (s *Subscriber) Subscribe(ctx context.Context, subConfig eventbus.Subscript
 				if errors.Is(err, redis.Nil) {
 					continue // No messages available
 				}
+				if ctx.Err() != nil {
+					log.InfoContext(ctx, "subscription stopped")
+
+					return ctx.Err()
+				}
+
+				log.ErrorContext(ctx, "failed to read from stream", slog.Any(logKeyError, err))
 
 				return fmt.Errorf("%w: failed to read from stream: %w", eventbus.ErrSubscriptionFailed, err)
 			}
@@ -139,21 +202,46 @@ func (s *Subscriber) Subscribe(ctx context.Context, subConfig eventbus.Subscript
 
 // processMessage processes a single message with retry logic.
 func (s *Subscriber) processMessage(ctx context.Context, config eventbus.SubscriptionConfig, msg redis.XMessage) {
+	for {
+		next, again := s.handleMessage(ctx, config, msg)
+		if !again {
+			return
+		}
+
+		msg = next
+	}
+}
+
+// handleMessage processes one attempt of msg. Failed events of a partition are retried
+// in place rather than re-added to the stream, to keep the order of the partition: it then
+// returns msg with its next attempt and true, and msg is left pending.
+func (s *Subscriber) handleMessage(
+	ctx context.Context,
+	config eventbus.SubscriptionConfig,
+	msg redis.XMessage,
+) (redis.XMessage, bool) {
+	log := subscriptionLogger(s.logger, config).With(slog.String(logKeyMessageID, msg.ID))
+
 	// Parse metadata
 	var metadata map[string]any
 	metadataStr, ok := msg.Values["metadata"].(string)
+	if !ok {
+		metadataStr, ok = cloudEventMetadata(msg.Values)
+	}
 	if !ok {
 		// Invalid message format, acknowledge to prevent reprocessing
-		s.client.XAck(ctx, config.Stream, config.ConsumerGroup, msg.ID)
+		log.WarnContext(ctx, "message has no metadata field, acknowledging")
+		s.ack(ctx, config, msg.ID, log)
 
-		return
+		return msg, false
 	}
 
 	if err := json.Unmarshal([]byte(metadataStr), &metadata); err != nil {
 		// Invalid metadata, acknowledge to prevent reprocessing
-		s.client.XAck(ctx, config.Stream, config.ConsumerGroup, msg.ID)
+		log.WarnContext(ctx, "message has invalid metadata, acknowledging", slog.Any(logKeyError, err))
+		s.ack(ctx, config, msg.ID, log)
 
-		return
+		return msg, false
 	}
 
 	// Get attempt count
@@ -173,51 +261,192 @@ func (s *Subscriber) processMessage(ctx context.Context, config eventbus.Subscri
 	// Services will need to deserialize based on event type
 	id, _ := metadata["id"].(string)
 	eventType, _ := metadata["type"].(string)
+	version, _ := metadata["version"].(string)
 	timestampStr, _ := metadata["timestamp"].(string)
 	payload, _ := msg.Values["payload"].(string)
 
+	// Failing to fetch a claim-checked payload, to decode, decrypt or upcast it fails like
+	// the handler, since the cause may be transient. Undecodable payloads are not retried.
+	claimKey, _ := metadata[metadataClaimCheck].(string)
+	encoding, _ := metadata[metadataEncoding].(string)
+	contentType, _ := metadata[metadataContentType].(string)
+	storedPayload := payload
+	payload, prepareErr := s.fetchClaimCheck(ctx, claimKey, payload)
+
+	if prepareErr == nil {
+		decoded, err := DecodePayload(encoding, payload)
+		if err == nil {
+			decoded, err = payloadToJSON(s.codecs, contentType, eventType, decoded)
+		}
+		if err != nil {
+			prepareErr = fmt.Errorf("%w: %w", errUndecodable, err)
+		} else {
+			payload = decoded
+		}
+	}
+
+	headers := parseHeaders(metadata["headers"])
 	event := &rawEvent{
-		id:        id,
-		eventType: eventType,
-		timestamp: parseTime(timestampStr),
-		data:      payload,
+		id:            id,
+		eventType:     eventType,
+		version:       version,
+		timestamp:     parseTime(timestampStr),
+		data:          payload,
+		headers:       headers,
+		storedData:    payload,
+		storedHeaders: headers,
+		storedVersion: version,
+	}
+	if prepareErr != nil {
+		// The DLQ entry keeps the payload as stored, or references the blob, so that the
+		// event can be replayed
+		event.undecoded, event.storedData = true, storedPayload
+		event.storedClaimCheck, event.storedEncoding, event.storedContentType = claimKey, encoding, contentType
+	}
+	if prepareErr == nil {
+		prepareErr = s.decryptEvent(ctx, event)
+	}
+	if prepareErr == nil {
+		prepareErr = s.upcastEvent(event)
+	}
+	log = eventLogger(log, event).With(slog.Int(logKeyAttempt, attempt))
+
+	// Events published by the handler inherit the correlation ID and are caused by this event
+	processCtx = eventbus.ContextWithCause(processCtx, event)
+
+	s.metrics.InFlight(config.Stream, config.ConsumerGroup, 1)
+	defer s.metrics.InFlight(config.Stream, config.ConsumerGroup, -1)
+
+	if !event.timestamp.IsZero() {
+		s.metrics.EndToEndLatency(config.Stream, config.ConsumerGroup, eventType, time.Since(event.timestamp))
 	}
 
-	if handlerErr := config.Handler(processCtx, event); handlerErr != nil {
+	processCtx, span := s.tracing.startProcess(processCtx, config, msg.ID, event, attempt)
+	start := time.Now()
+	var handlerErr error
+	if prepareErr != nil {
+		handlerErr = prepareErr
+	} else {
+		handlerErr = config.Handler(processCtx, event)
+	}
+	handlerDuration := time.Since(start)
+	endSpan(span, handlerErr)
+
+	if handlerErr != nil {
 		// Handle retry logic
-		if attempt < 3 { // Max 3 attempts
+		if attempt < 3 && retryable(handlerErr) { // Max 3 attempts
 			// Calculate backoff
 			backoff := calculateBackoff(attempt)
-			time.Sleep(backoff)
+			log.WarnContext(ctx, "event handler failed, retrying",
+				slog.Any(logKeyError, handlerErr), slog.Duration("backoff", backoff))
+			if !sleep(ctx, backoff) {
+				// Stopping: the message is left pending, like a failed acknowledgement
+				return msg, false
+			}
 
-			// Increment attempt and re-publish for retry
-			metadata["attempt"] = attempt + 1
-			metadataJSON, err := json.Marshal(metadata)
-			if err != nil {
-				// Log error but continue (acknowledge message)
-				return
+			// Partitions retry in place, so that later events of the partition wait
+			_, _, inPlace := splitPartition(s.config.Redis, config.Stream)
+			next, err := withAttempt(msg, metadata, attempt+1)
+			if err == nil && !inPlace {
+				err = s.retry(ctx, config, next, attempt+1, log)
 			}
 
-			// Re-add to stream for retry
-			s.client.XAdd(ctx, &redis.XAddArgs{
-				Stream: config.Stream,
-				Values: map[string]any{
-					fieldMetadata: string(metadataJSON),
-					fieldPayload:  msg.Values[fieldPayload],
-				},
-			})
+			if err != nil {
+				s.metrics.EventHandled(config.Stream, config.ConsumerGroup, eventType, eventbus.OutcomeDropped, handlerDuration)
+				log.ErrorContext(ctx, "failed to retry event, dropping it",
+					slog.Any(logKeyError, err), slog.String("handler_error", handlerErr.Error()))
+			} else {
+				s.metrics.EventHandled(config.Stream, config.ConsumerGroup, eventType, eventbus.OutcomeRetry, handlerDuration)
+				s.metrics.EventRetried(config.Stream, config.ConsumerGroup, eventType, attempt+1)
+
+				if inPlace {
+					return next, true
+				}
+			}
 		} else if config.DLQPublisher != nil {
 			dlqEntry := eventbus.NewDLQEntry(config.Stream, event, handlerErr, config.DLQService, attempt)
-			_ = config.DLQPublisher.Publish(ctx, streams.StreamDLQ, dlqEntry)
+			if err := config.DLQPublisher.Publish(eventbus.ContextWithCause(ctx, event), streams.StreamDLQ, dlqEntry); err != nil {
+				s.metrics.EventHandled(config.Stream, config.ConsumerGroup, eventType, eventbus.OutcomeDropped, handlerDuration)
+				log.ErrorContext(ctx, "failed to route event to DLQ, dropping it",
+					slog.Any(logKeyError, err), slog.String("handler_error", handlerErr.Error()))
+			} else {
+				s.metrics.EventHandled(config.Stream, config.ConsumerGroup, eventType, eventbus.OutcomeDeadLettered, handlerDuration)
+				s.metrics.EventDeadLettered(config.Stream, config.ConsumerGroup, eventType)
+				log.WarnContext(ctx, "event handler failed on last attempt, routed to DLQ",
+					slog.Any(logKeyError, handlerErr), slog.String("dlq_entry_id", dlqEntry.EventID()))
+			}
+		} else {
+			s.metrics.EventHandled(config.Stream, config.ConsumerGroup, eventType, eventbus.OutcomeDropped, handlerDuration)
+			log.ErrorContext(ctx, "event handler failed on last attempt, dropping event (no DLQ configured)",
+				slog.Any(logKeyError, handlerErr))
 		}
 
-		s.client.XAck(ctx, config.Stream, config.ConsumerGroup, msg.ID)
+		s.ack(ctx, config, msg.ID, log)
 
-		return
+		return msg, false
 	}
 
+	s.metrics.EventHandled(config.Stream, config.ConsumerGroup, eventType, eventbus.OutcomeSuccess, handlerDuration)
+
 	// Acknowledge successful processing
-	s.client.XAck(ctx, config.Stream, config.ConsumerGroup, msg.ID)
+	s.ack(ctx, config, msg.ID, log)
+
+	return msg, false
+}
+
+// retry re-adds msg, holding the metadata of the given attempt, to the stream.
+func (s *Subscriber) retry(
+	ctx context.Context,
+	config eventbus.SubscriptionConfig,
+	msg redis.XMessage,
+	attempt int,
+	log *slog.Logger,
+) error {
+	args := &redis.XAddArgs{
+		Stream: config.Stream,
+		Values: map[string]any{
+			fieldMetadata: msg.Values[fieldMetadata],
+			fieldPayload:  msg.Values[fieldPayload],
+		},
+	}
+	applyRetention(args, streamRetention(s.config.Redis, config.Stream))
+
+	retryID, err := s.client.XAdd(ctx, args).Result()
+	if err != nil {
+		return fmt.Errorf("failed to re-add event for retry: %w", err)
+	}
+
+	log.DebugContext(ctx, "event re-added for retry",
+		slog.Int("next_attempt", attempt), slog.String("retry_message_id", retryID))
+
+	return nil
+}
+
+// retryable reports whether a failed event may succeed on another attempt. Events of an
+// unsupported version or with an undecodable payload fail on every attempt.
+func retryable(err error) bool {
+	return !errors.Is(err, eventbus.ErrUnsupportedVersion) && !errors.Is(err, errUndecodable)
+}
+
+// withAttempt returns a copy of msg whose metadata has the given attempt number.
+func withAttempt(msg redis.XMessage, metadata map[string]any, attempt int) (redis.XMessage, error) {
+	metadata["attempt"] = attempt
+	metadataJSON, err := json.Marshal(metadata)
+	if err != nil {
+		return msg, fmt.Errorf("failed to marshal metadata for retry: %w", err)
+	}
+
+	values := maps.Clone(msg.Values)
+	values[fieldMetadata] = string(metadataJSON)
+
+	return redis.XMessage{ID: msg.ID, Values: values}, nil
+}
+
+// ack acknowledges msgID, logging failures.
+func (s *Subscriber) ack(ctx context.Context, config eventbus.SubscriptionConfig, msgID string, log *slog.Logger) {
+	if err := s.client.XAck(ctx, config.Stream, config.ConsumerGroup, msgID).Err(); err != nil {
+		log.ErrorContext(ctx, "failed to acknowledge message", slog.Any(logKeyError, err))
+	}
 }
 
 // calculateBackoff calculates exponential backoff.
@@ -243,8 +472,30 @@ func parseTime(timestamp string) time.Time {
 	return t
 }
 
-// Close closes the Redis connection.
+// parseHeaders converts the decoded "headers" metadata value into eventbus.Headers.
+// Non-string values are ignored.
+func parseHeaders(value any) eventbus.Headers {
+	raw, ok := value.(map[string]any)
+	if !ok || len(raw) == 0 {
+		return nil
+	}
+
+	headers := make(eventbus.Headers, len(raw))
+	for k, v := range raw {
+		if str, ok := v.(string); ok {
+			headers[k] = str
+		}
+	}
+
+	return headers
+}
+
+// Close closes the Redis connection, unless the client was provided by the caller.
 func (s *Subscriber) Close() error {
+	if !s.ownsClient {
+		return nil
+	}
+
 	return s.client.Close()
 }
 
@@ -257,8 +508,23 @@ func (s *Subscriber) Health(ctx context.Context) error {
 type rawEvent struct {
 	id        string
 	eventType string
+	version   string
 	timestamp time.Time
 	data      string
+	headers   eventbus.Headers
+
+	// storedData, storedHeaders and storedVersion are the payload, headers and version
+	// as stored in the stream, before decryption and upcasting.
+	storedData    string
+	storedHeaders eventbus.Headers
+	storedVersion string
+
+	// undecoded is set if the payload could not be fetched or decoded. storedClaimCheck
+	// is then its claim-check key, and storedEncoding and storedContentType describe it.
+	undecoded         bool
+	storedClaimCheck  string
+	storedEncoding    string
+	storedContentType string
 }
 
 func (e *rawEvent) EventType() string    { return e.eventType }
@@ -266,3 +532,44 @@ func (e *rawEvent) EventID() string      { return e.id }
 func (e *rawEvent) EventTime() time.Time { return e.timestamp }
 func (e *rawEvent) Data() string         { return e.data }
 func (e *rawEvent) Validate() error      { return nil }
+
+// EventVersion returns the schema version of the event, after upcasting.
+func (e *rawEvent) EventVersion() string { return e.version }
+
+// Headers returns the headers the event was published with.
+func (e *rawEvent) Headers() eventbus.Headers { return e.headers }
+
+// StoredData returns the payload as stored in the stream, before decryption and upcasting.
+func (e *rawEvent) StoredData() string { return e.storedData }
+
+// StoredHeaders returns the headers as stored in the stream, before decryption.
+func (e *rawEvent) StoredHeaders() eventbus.Headers { return e.storedHeaders }
+
+// StoredVersion returns the version the event was stored with, before upcasting.
+func (e *rawEvent) StoredVersion() string { return e.storedVersion }
+
+// Undecoded reports whether the payload could not be fetched or decoded.
+func (e *rawEvent) Undecoded() bool { return e.undecoded }
+
+// StoredClaimCheck returns the claim-check key of the stored payload.
+func (e *rawEvent) StoredClaimCheck() string { return e.storedClaimCheck }
+
+// StoredEncoding returns the compression encoding and content type of the stored payload.
+func (e *rawEvent) StoredEncoding() (string, string) { return e.storedEncoding, e.storedContentType }
+
+// sleep waits for d and reports whether ctx is still active.
+func sleep(ctx context.Context, d time.Duration) bool {
+	if d <= 0 {
+		return ctx.Err() == nil
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
@@ -12,7 +12,9 @@ import (
 	"github.com/tclavelloux/promy-event-bus/redis"
 	"github.com/tclavelloux/promy-event-bus/testutil"
 
+	goredis "github.com/redis/go-redis/v9"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
 )
 
@@ -363,3 +365,157 @@ func TestSubscriber_EventData(t *testing.T) {
 		assert.Equal(t, "test@example.com", parsed["email"])
 	})
 }
+
+func TestSubscriber_Headers(t *testing.T) {
+	config := eventbus.Config{
+		Redis: eventbus.RedisConfig{
+			DSN: "redis://localhost:6379/1",
+		},
+	}
+
+	t.Run("delivers headers and propagates correlation to events published by the handler", func(t *testing.T) {
+		subscriber, err := redis.NewSubscriber(config)
+		require.NoError(t, err)
+		defer subscriber.Close()
+
+		publisher, err := redis.NewPublisher(config.Redis)
+		require.NoError(t, err)
+		defer publisher.Close()
+
+		child := testutil.NewTestEvent("product.identified", map[string]any{"product_id": "prod-1"})
+		parentHeaders := make(chan eventbus.Headers, 1)
+		childHeaders := make(chan eventbus.Headers, 1)
+
+		parentHandler := func(ctx context.Context, event eventbus.Event) error {
+			parentHeaders <- eventbus.EventHeaders(event)
+			return publisher.Publish(ctx, "events:test-headers-child", child)
+		}
+		childHandler := func(ctx context.Context, event eventbus.Event) error {
+			childHeaders <- eventbus.EventHeaders(event)
+			return nil
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		go func() {
+			subscriber.Subscribe(ctx, eventbus.SubscriptionConfig{
+				Stream:        "events:test-headers",
+				ConsumerGroup: "test-headers-group",
+				ConsumerID:    "consumer-1",
+				Handler:       parentHandler,
+			})
+		}()
+		go func() {
+			subscriber.Subscribe(ctx, eventbus.SubscriptionConfig{
+				Stream:        "events:test-headers-child",
+				ConsumerGroup: "test-headers-group",
+				ConsumerID:    "consumer-1",
+				Handler:       childHandler,
+			})
+		}()
+
+		time.Sleep(100 * time.Millisecond)
+
+		parent := testutil.NewTestEvent("promotion.created", map[string]any{"promotion_id": "promo-1"})
+		publishCtx := eventbus.WithHeaders(context.Background(), eventbus.Headers{
+			eventbus.HeaderCorrelationID: "http-request-1",
+			"tenant":                     "t-1",
+		})
+		err = publisher.Publish(publishCtx, "events:test-headers", parent)
+		require.NoError(t, err)
+
+		select {
+		case headers := <-parentHeaders:
+			assert.Equal(t, "http-request-1", headers.CorrelationID())
+			assert.Equal(t, "t-1", headers["tenant"])
+		case <-ctx.Done():
+			t.Fatal("timeout waiting for parent event")
+		}
+
+		select {
+		case headers := <-childHeaders:
+			assert.Equal(t, "http-request-1", headers.CorrelationID())
+			assert.Equal(t, parent.EventID(), headers.CausationID())
+		case <-ctx.Done():
+			t.Fatal("timeout waiting for child event")
+		}
+	})
+}
+
+func TestSubscriber_Versions(t *testing.T) {
+	const stream = "events:test-versions"
+
+	client := goredis.NewClient(&goredis.Options{Addr: "localhost:6379", DB: 1})
+	defer client.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(t, client.Del(ctx, stream).Err())
+
+	config := eventbus.Config{Redis: eventbus.RedisConfig{DSN: "redis://localhost:6379/1"}}
+
+	publisher, err := redis.NewPublisher(config.Redis)
+	require.NoError(t, err)
+	defer publisher.Close()
+
+	subscriber, err := redis.NewSubscriber(config)
+	require.NoError(t, err)
+	defer subscriber.Close()
+
+	dlq := &testutil.MockPublisher{}
+	dlqEntries := make(chan *eventbus.DLQEntry, 1)
+	dlq.On("Publish", mock.Anything, mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		dlqEntries <- args.Get(2).(*eventbus.DLQEntry)
+	}).Return(nil)
+
+	v2 := make(chan eventbus.Event, 1)
+	go subscriber.Subscribe(ctx, eventbus.SubscriptionConfig{
+		Stream:        stream,
+		ConsumerGroup: "test-versions-group",
+		ConsumerID:    "consumer-1",
+		Handler: eventbus.RouteByVersion(map[string]eventbus.EventHandler{
+			"2.0": func(_ context.Context, event eventbus.Event) error {
+				v2 <- event
+				return nil
+			},
+		}),
+		DLQPublisher: dlq,
+		DLQService:   "test-service",
+	})
+
+	time.Sleep(100 * time.Millisecond)
+
+	current := testutil.NewTestEvent("promotion.created", map[string]any{"promotion_id": "promo-1"})
+	current.Version = "2.0"
+	legacy := testutil.NewTestEvent("promotion.created", map[string]any{"promotion_id": "promo-2"})
+	require.NoError(t, publisher.Publish(ctx, stream, current))
+	require.NoError(t, publisher.Publish(ctx, stream, legacy))
+
+	messages, err := client.XRange(ctx, stream, "-", "+").Result()
+	require.NoError(t, err)
+	require.GreaterOrEqual(t, len(messages), 2)
+
+	var metadata map[string]any
+	require.NoError(t, json.Unmarshal([]byte(messages[0].Values["metadata"].(string)), &metadata))
+	assert.Equal(t, "2.0", metadata["version"])
+
+	select {
+	case got := <-v2:
+		assert.Equal(t, current.ID, got.EventID())
+		assert.Equal(t, "2.0", eventbus.EventVersion(got))
+	case <-ctx.Done():
+		t.Fatal("timed out waiting for v2 event")
+	}
+
+	// Unsupported versions are dead-lettered on the first attempt
+	select {
+	case entry := <-dlqEntries:
+		assert.Equal(t, legacy.ID, entry.OriginalEventID)
+		assert.Equal(t, "1.0", entry.OriginalVersion)
+		assert.Equal(t, 1, entry.AttemptsExhausted)
+		assert.Contains(t, entry.FailureReason, eventbus.ErrUnsupportedVersion.Error())
+	case <-ctx.Done():
+		t.Fatal("timed out waiting for DLQ entry")
+	}
+}
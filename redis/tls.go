@@ -0,0 +1,76 @@
+package redis
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+
+	eventbus "github.com/tclavelloux/promy-event-bus/eventbus"
+)
+
+// tlsVersions maps the TLS versions accepted in RedisTLSConfig.MinVersion.
+var tlsVersions = map[string]uint16{
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// tlsConfig returns the TLS configuration of config, starting from base (the TLS
+// configuration of a "rediss://" DSN, or nil). It returns base if config sets nothing.
+func tlsConfig(config eventbus.RedisTLSConfig, base *tls.Config) (*tls.Config, error) {
+	if config.IsZero() {
+		return base, nil
+	}
+
+	cfg := &tls.Config{MinVersion: tls.VersionTLS12}
+	if base != nil {
+		cfg = base.Clone()
+		cfg.MinVersion = max(cfg.MinVersion, tls.VersionTLS12)
+	}
+
+	if config.MinVersion != "" {
+		version, ok := tlsVersions[config.MinVersion]
+		if !ok {
+			return nil, fmt.Errorf("invalid Redis TLS config: min_version must be 1.2 or 1.3, got %q", config.MinVersion)
+		}
+
+		cfg.MinVersion = version
+	}
+
+	if config.ServerName != "" {
+		cfg.ServerName = config.ServerName
+	}
+	cfg.InsecureSkipVerify = config.InsecureSkipVerify //nolint:gosec // opt-in for local testing
+
+	if config.CAFile != "" {
+		pem, err := os.ReadFile(config.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("invalid Redis TLS config: failed to read CA file: %w", err)
+		}
+
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("invalid Redis TLS config: no certificate in CA file %s", config.CAFile)
+		}
+
+		cfg.RootCAs = pool
+	}
+
+	if config.CertFile != "" || config.KeyFile != "" {
+		// Load once to fail fast on a bad pair, then on every handshake to follow rotations
+		if _, err := tls.LoadX509KeyPair(config.CertFile, config.KeyFile); err != nil {
+			return nil, fmt.Errorf("invalid Redis TLS config: failed to load client certificate: %w", err)
+		}
+
+		cfg.GetClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			cert, err := tls.LoadX509KeyPair(config.CertFile, config.KeyFile)
+			if err != nil {
+				return nil, fmt.Errorf("failed to load Redis client certificate: %w", err)
+			}
+
+			return &cert, nil
+		}
+	}
+
+	return cfg, nil
+}
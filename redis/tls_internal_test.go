@@ -0,0 +1,106 @@
+package redis
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	eventbus "github.com/tclavelloux/promy-event-bus/eventbus"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeCertificate writes a self-signed certificate and its key as PEM files in dir.
+func writeCertificate(t *testing.T, dir, name string) (certFile, keyFile string) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	certFile = filepath.Join(dir, name+".crt")
+	keyFile = filepath.Join(dir, name+".key")
+	require.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600))
+	require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600))
+
+	return certFile, keyFile
+}
+
+func TestTLSConfig(t *testing.T) {
+	dir := t.TempDir()
+	caFile, _ := writeCertificate(t, dir, "ca")
+	certFile, keyFile := writeCertificate(t, dir, "svc-promotions")
+
+	t.Run("not configured", func(t *testing.T) {
+		cfg, err := tlsConfig(eventbus.RedisTLSConfig{}, nil)
+		require.NoError(t, err)
+		assert.Nil(t, cfg)
+	})
+
+	t.Run("custom CA and client certificate", func(t *testing.T) {
+		cfg, err := tlsConfig(eventbus.RedisTLSConfig{
+			CAFile:     caFile,
+			CertFile:   certFile,
+			KeyFile:    keyFile,
+			ServerName: "redis.internal",
+			MinVersion: "1.3",
+		}, nil)
+		require.NoError(t, err)
+
+		assert.Equal(t, "redis.internal", cfg.ServerName)
+		assert.Equal(t, uint16(tls.VersionTLS13), cfg.MinVersion)
+		assert.NotNil(t, cfg.RootCAs)
+
+		cert, err := cfg.GetClientCertificate(&tls.CertificateRequestInfo{})
+		require.NoError(t, err)
+		leaf, err := x509.ParseCertificate(cert.Certificate[0])
+		require.NoError(t, err)
+		assert.Equal(t, "svc-promotions", leaf.Subject.CommonName)
+	})
+
+	t.Run("extends the DSN configuration", func(t *testing.T) {
+		base := &tls.Config{ServerName: "redis.example.com"}
+
+		cfg, err := tlsConfig(eventbus.RedisTLSConfig{CAFile: caFile}, base)
+		require.NoError(t, err)
+		assert.Equal(t, "redis.example.com", cfg.ServerName)
+		assert.Equal(t, uint16(tls.VersionTLS12), cfg.MinVersion)
+		assert.Empty(t, base.RootCAs, "the DSN configuration is not modified")
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		for name, config := range map[string]eventbus.RedisTLSConfig{
+			"min version":  {MinVersion: "1.1"},
+			"missing CA":   {CAFile: filepath.Join(dir, "missing.crt")},
+			"not a CA":     {CAFile: keyFile},
+			"missing key":  {CertFile: certFile},
+			"mismatch key": {CertFile: caFile, KeyFile: keyFile},
+		} {
+			_, err := tlsConfig(config, nil)
+			assert.Error(t, err, name)
+		}
+	})
+}
@@ -0,0 +1,126 @@
+package redis
+
+import (
+	"context"
+	"fmt"
+
+	eventbus "github.com/tclavelloux/promy-event-bus/eventbus"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const instrumentationName = "github.com/tclavelloux/promy-event-bus/redis"
+
+// Messaging semantic convention attributes, plus event bus specific ones.
+const (
+	attrMessagingSystem        = attribute.Key("messaging.system")
+	attrMessagingDestination   = attribute.Key("messaging.destination.name")
+	attrMessagingOperationType = attribute.Key("messaging.operation.type")
+	attrMessagingConsumerGroup = attribute.Key("messaging.consumer.group.name")
+	attrMessagingClientID      = attribute.Key("messaging.client.id")
+	attrMessagingMessageID     = attribute.Key("messaging.message.id")
+	attrMessagingBatchCount    = attribute.Key("messaging.batch.message_count")
+	attrEventID                = attribute.Key("eventbus.event.id")
+	attrEventType              = attribute.Key("eventbus.event.type")
+	attrEventDuplicate         = attribute.Key("eventbus.event.duplicate")
+	attrDeliveryAttempt        = attribute.Key("eventbus.delivery.attempt")
+)
+
+const (
+	messagingSystemRedis      = "redis"
+	messagingOperationPublish = "publish"
+	messagingOperationProcess = "process"
+)
+
+// tracing creates producer and consumer spans and moves trace context through message headers.
+type tracing struct {
+	tracer     trace.Tracer
+	propagator propagation.TextMapPropagator
+}
+
+func newTracing(o options) tracing {
+	return tracing{
+		tracer:     o.tracerProvider.Tracer(instrumentationName),
+		propagator: o.propagator,
+	}
+}
+
+// startPublish starts a producer span for publishing events to stream.
+// An empty stream means the events go to several streams.
+// Event attributes are only set when a single event is published.
+func (t tracing) startPublish(ctx context.Context, stream string, events []eventbus.Event) (context.Context, trace.Span) {
+	name := messagingOperationPublish
+	attrs := []attribute.KeyValue{
+		attrMessagingSystem.String(messagingSystemRedis),
+		attrMessagingOperationType.String(messagingOperationPublish),
+	}
+	if stream != "" {
+		name = fmt.Sprintf("%s %s", stream, messagingOperationPublish)
+		attrs = append(attrs, attrMessagingDestination.String(stream))
+	}
+	if len(events) == 1 {
+		attrs = append(attrs,
+			attrEventID.String(events[0].EventID()),
+			attrEventType.String(events[0].EventType()),
+		)
+	} else {
+		attrs = append(attrs, attrMessagingBatchCount.Int(len(events)))
+	}
+
+	return t.tracer.Start(ctx, name,
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(attrs...),
+	)
+}
+
+// inject writes the trace context of ctx into headers.
+func (t tracing) inject(ctx context.Context, headers eventbus.Headers) {
+	t.propagator.Inject(ctx, propagation.MapCarrier(headers))
+}
+
+// startProcess starts a consumer span for handling event. The span continues the
+// trace found in the event headers and links to the producer span.
+func (t tracing) startProcess(
+	ctx context.Context,
+	config eventbus.SubscriptionConfig,
+	messageID string,
+	event eventbus.Event,
+	attempt int,
+) (context.Context, trace.Span) {
+	carrier := propagation.MapCarrier(eventbus.EventHeaders(event))
+	producer := trace.SpanContextFromContext(t.propagator.Extract(context.Background(), carrier))
+
+	opts := []trace.SpanStartOption{
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(
+			attrMessagingSystem.String(messagingSystemRedis),
+			attrMessagingDestination.String(config.Stream),
+			attrMessagingOperationType.String(messagingOperationProcess),
+			attrMessagingConsumerGroup.String(config.ConsumerGroup),
+			attrMessagingClientID.String(config.ConsumerID),
+			attrMessagingMessageID.String(messageID),
+			attrEventID.String(event.EventID()),
+			attrEventType.String(event.EventType()),
+			attrDeliveryAttempt.Int(attempt),
+		),
+	}
+	if producer.IsValid() {
+		ctx = trace.ContextWithRemoteSpanContext(ctx, producer)
+		opts = append(opts, trace.WithLinks(trace.Link{SpanContext: producer}))
+	}
+
+	return t.tracer.Start(ctx, fmt.Sprintf("%s %s", config.Stream, messagingOperationProcess), opts...)
+}
+
+// endSpan records err on span, if any, and ends it.
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+
+	span.End()
+}
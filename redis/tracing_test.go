@@ -0,0 +1,108 @@
+//nolint:all // Test file
+package redis_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	eventbus "github.com/tclavelloux/promy-event-bus/eventbus"
+	"github.com/tclavelloux/promy-event-bus/redis"
+	"github.com/tclavelloux/promy-event-bus/testutil"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestTracing_PublishToConsume(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	defer provider.Shutdown(context.Background())
+
+	opts := []redis.Option{
+		redis.WithTracerProvider(provider),
+		redis.WithPropagator(propagation.TraceContext{}),
+	}
+
+	config := eventbus.Config{
+		Redis: eventbus.RedisConfig{
+			DSN: "redis://localhost:6379/1",
+		},
+	}
+
+	publisher, err := redis.NewPublisher(config.Redis, opts...)
+	require.NoError(t, err)
+	defer publisher.Close()
+
+	subscriber, err := redis.NewSubscriber(config, opts...)
+	require.NoError(t, err)
+	defer subscriber.Close()
+
+	handled := make(chan trace.SpanContext, 1)
+	handler := func(ctx context.Context, event eventbus.Event) error {
+		handled <- trace.SpanContextFromContext(ctx)
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	go func() {
+		subscriber.Subscribe(ctx, eventbus.SubscriptionConfig{
+			Stream:        "events:test-tracing",
+			ConsumerGroup: "test-tracing-group",
+			ConsumerID:    "consumer-1",
+			Handler:       handler,
+		})
+	}()
+
+	time.Sleep(100 * time.Millisecond)
+
+	tracer := provider.Tracer("test")
+	requestCtx, requestSpan := tracer.Start(context.Background(), "POST /subscriptions")
+
+	event := testutil.NewTestEvent("subscription.started", map[string]any{"subscription_id": "sub-1"})
+	err = publisher.Publish(requestCtx, "events:test-tracing", event)
+	require.NoError(t, err)
+	requestSpan.End()
+
+	var handlerSpan trace.SpanContext
+	select {
+	case handlerSpan = <-handled:
+	case <-ctx.Done():
+		t.Fatal("timeout waiting for event")
+	}
+
+	assert.Equal(t, requestSpan.SpanContext().TraceID(), handlerSpan.TraceID(), "handler should continue the request trace")
+
+	require.Eventually(t, func() bool { return len(exporter.GetSpans()) >= 3 }, time.Second, 10*time.Millisecond)
+
+	var producer, consumer tracetest.SpanStub
+	for _, span := range exporter.GetSpans() {
+		switch span.SpanKind {
+		case trace.SpanKindProducer:
+			producer = span
+		case trace.SpanKindConsumer:
+			consumer = span
+		}
+	}
+
+	assert.Equal(t, "events:test-tracing publish", producer.Name)
+	assert.Equal(t, requestSpan.SpanContext().SpanID(), producer.Parent.SpanID())
+	assert.Contains(t, producer.Attributes, attribute.String("messaging.destination.name", "events:test-tracing"))
+	assert.Contains(t, producer.Attributes, attribute.String("eventbus.event.type", "subscription.started"))
+
+	assert.Equal(t, "events:test-tracing process", consumer.Name)
+	assert.Equal(t, producer.SpanContext.SpanID(), consumer.Parent.SpanID())
+	assert.Equal(t, handlerSpan.SpanID(), consumer.SpanContext.SpanID())
+	require.Len(t, consumer.Links, 1)
+	assert.Equal(t, producer.SpanContext.SpanID(), consumer.Links[0].SpanContext.SpanID())
+	assert.Contains(t, consumer.Attributes, attribute.String("messaging.consumer.group.name", "test-tracing-group"))
+	assert.Contains(t, consumer.Attributes, attribute.String("eventbus.event.type", "subscription.started"))
+	assert.Contains(t, consumer.Attributes, attribute.Int("eventbus.delivery.attempt", 1))
+}
@@ -0,0 +1,21 @@
+package redis
+
+import eventbus "github.com/tclavelloux/promy-event-bus/eventbus"
+
+// upcastEvent upgrades the payload of event in place to the latest version known to the
+// subscriber's upcasters. The stored form is kept for DLQ entries.
+func (s *Subscriber) upcastEvent(event *rawEvent) error {
+	if s.upcasters == nil {
+		return nil
+	}
+
+	payload, version, err := s.upcasters.Upcast(event.eventType, eventbus.EventVersion(event), []byte(event.data))
+	if err != nil {
+		return err
+	}
+
+	event.data = string(payload)
+	event.version = version
+
+	return nil
+}
@@ -0,0 +1,94 @@
+//nolint:all // Test file
+package redis_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	eventbus "github.com/tclavelloux/promy-event-bus/eventbus"
+	"github.com/tclavelloux/promy-event-bus/redis"
+	"github.com/tclavelloux/promy-event-bus/testutil"
+
+	goredis "github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSubscriber_Upcasts(t *testing.T) {
+	const stream = "events:test-upcast"
+
+	client := goredis.NewClient(&goredis.Options{Addr: "localhost:6379", DB: 1})
+	defer client.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	require.NoError(t, client.Del(ctx, stream).Err())
+
+	config := eventbus.Config{Redis: eventbus.RedisConfig{DSN: "redis://localhost:6379/1"}}
+
+	upcasters := eventbus.NewUpcasters()
+	upcasters.Register("promotion.created", "1.0", "2.0", func(raw []byte) ([]byte, error) {
+		var payload map[string]any
+		if err := json.Unmarshal(raw, &payload); err != nil {
+			return nil, err
+		}
+		payload["currency"] = "EUR"
+		return json.Marshal(payload)
+	})
+
+	publisher, err := redis.NewPublisher(config.Redis)
+	require.NoError(t, err)
+	defer publisher.Close()
+
+	subscriber, err := redis.NewSubscriber(config, redis.WithUpcasters(upcasters))
+	require.NoError(t, err)
+	defer subscriber.Close()
+
+	dlq := &testutil.MockPublisher{}
+	dlqEntries := make(chan *eventbus.DLQEntry, 1)
+	dlq.On("Publish", mock.Anything, mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		dlqEntries <- args.Get(2).(*eventbus.DLQEntry)
+	}).Return(nil)
+
+	received := make(chan eventbus.Event, 3)
+	go subscriber.Subscribe(ctx, eventbus.SubscriptionConfig{
+		Stream:        stream,
+		ConsumerGroup: "test-upcast-group",
+		ConsumerID:    "consumer-1",
+		Handler: func(_ context.Context, event eventbus.Event) error {
+			received <- event
+			return assert.AnError
+		},
+		DLQPublisher: dlq,
+		DLQService:   "test-service",
+	})
+
+	time.Sleep(100 * time.Millisecond)
+
+	event := testutil.NewTestEvent("promotion.created", map[string]any{"promotion_id": "promo-1"})
+	require.NoError(t, publisher.Publish(ctx, stream, event))
+
+	select {
+	case got := <-received:
+		assert.Equal(t, "2.0", eventbus.EventVersion(got))
+
+		var payload map[string]any
+		require.NoError(t, json.Unmarshal([]byte(got.Data()), &payload))
+		assert.Equal(t, "EUR", payload["currency"])
+		assert.Equal(t, "2.0", payload["version"])
+	case <-ctx.Done():
+		t.Fatal("timed out waiting for upcast event")
+	}
+
+	// The DLQ keeps the event as published
+	select {
+	case entry := <-dlqEntries:
+		assert.Equal(t, "1.0", entry.OriginalVersion)
+		assert.NotContains(t, entry.OriginalPayload, "currency")
+	case <-ctx.Done():
+		t.Fatal("timed out waiting for DLQ entry")
+	}
+}
@@ -0,0 +1,229 @@
+// Package registry exposes the event schema registry (registry/streams/) to Go code.
+// The YAML contracts are embedded at build time; scripts/validate-registry.sh
+// remains the CI gate for their content.
+package registry
+
+import (
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+//go:embed streams
+var embedded embed.FS
+
+// ErrInvalidRegistry is returned when a registry file cannot be parsed or breaks a rule.
+var ErrInvalidRegistry = errors.New("invalid registry")
+
+// Stream describes a stream declared in streams/<domain>/stream.yaml.
+type Stream struct {
+	Name        string    `yaml:"stream"`
+	Owner       string    `yaml:"owner"`
+	Description string    `yaml:"description"`
+	Retention   Retention `yaml:"retention"`
+
+	// Partitions is the number of partitions the stream is split into, named
+	// "<stream>:<i>". 0 or 1 means a single stream.
+	Partitions int `yaml:"partitions"`
+
+	// Events declared in streams/<domain>/events/, keyed by event name.
+	Events map[string]Event `yaml:"-"`
+}
+
+// Retention declares how many entries a stream keeps. At most one field is set;
+// a zero Retention means the stream is never trimmed.
+type Retention struct {
+	// MaxLen caps the stream at approximately this many entries.
+	MaxLen int64 `yaml:"max_len"`
+
+	// MaxAge drops entries older than approximately this duration.
+	MaxAge time.Duration `yaml:"max_age"`
+}
+
+// IsZero reports whether no retention is declared.
+func (r Retention) IsZero() bool {
+	return r.MaxLen == 0 && r.MaxAge == 0
+}
+
+// Validate checks that at most one limit is set and that it is positive.
+func (r Retention) Validate() error {
+	if r.MaxLen < 0 {
+		return fmt.Errorf("%w: retention max_len must be positive, got %d", ErrInvalidRegistry, r.MaxLen)
+	}
+	if r.MaxAge < 0 {
+		return fmt.Errorf("%w: retention max_age must be positive, got %s", ErrInvalidRegistry, r.MaxAge)
+	}
+	if r.MaxLen > 0 && r.MaxAge > 0 {
+		return fmt.Errorf("%w: retention must set either max_len or max_age, not both", ErrInvalidRegistry)
+	}
+
+	return nil
+}
+
+// Event describes an event contract declared in streams/<domain>/events/<name>.yaml.
+type Event struct {
+	Name        string           `yaml:"name"`
+	Tier        int              `yaml:"tier"`
+	Version     string           `yaml:"version"` // Current schema version (e.g., "2.0")
+	Description string           `yaml:"description"`
+	Fields      map[string]Field `yaml:"fields"`
+}
+
+// Field describes one payload field of an event contract.
+type Field struct {
+	Type        string `yaml:"type"`
+	Format      string `yaml:"format"`
+	Required    bool   `yaml:"required"`
+	Description string `yaml:"description"`
+
+	// PII marks personal data that publishers encrypt before it reaches Redis.
+	PII bool `yaml:"pii"`
+}
+
+// PIIFields returns the names of the fields marked pii, sorted.
+func (e Event) PIIFields() []string {
+	var fields []string
+	for name, field := range e.Fields {
+		if field.PII {
+			fields = append(fields, name)
+		}
+	}
+
+	sort.Strings(fields)
+
+	return fields
+}
+
+// Registry is a parsed schema registry.
+type Registry struct {
+	streams map[string]Stream
+}
+
+var (
+	defaultRegistry *Registry
+	defaultOnce     sync.Once
+)
+
+// Default returns the registry embedded in this module.
+// It panics if the embedded files are invalid, which CI prevents.
+func Default() *Registry {
+	defaultOnce.Do(func() {
+		sub, err := fs.Sub(embedded, "streams")
+		if err != nil {
+			panic(err)
+		}
+
+		defaultRegistry, err = Parse(sub)
+		if err != nil {
+			panic(err)
+		}
+	})
+
+	return defaultRegistry
+}
+
+// Parse reads a registry laid out as <domain>/stream.yaml and <domain>/events/<name>.yaml.
+func Parse(fsys fs.FS) (*Registry, error) {
+	streamFiles, err := fs.Glob(fsys, "*/stream.yaml")
+	if err != nil {
+		return nil, err
+	}
+
+	r := &Registry{streams: make(map[string]Stream, len(streamFiles))}
+
+	for _, streamFile := range streamFiles {
+		stream, err := parseStream(fsys, streamFile)
+		if err != nil {
+			return nil, err
+		}
+
+		r.streams[stream.Name] = stream
+	}
+
+	return r, nil
+}
+
+func parseStream(fsys fs.FS, streamFile string) (Stream, error) {
+	var stream Stream
+	if err := decodeFile(fsys, streamFile, &stream); err != nil {
+		return Stream{}, err
+	}
+
+	if stream.Name == "" {
+		return Stream{}, fmt.Errorf("%w: %s: 'stream' is missing", ErrInvalidRegistry, streamFile)
+	}
+	if err := stream.Retention.Validate(); err != nil {
+		return Stream{}, fmt.Errorf("%s: %w", streamFile, err)
+	}
+	if stream.Partitions < 0 {
+		return Stream{}, fmt.Errorf("%w: %s: partitions must be positive, got %d",
+			ErrInvalidRegistry, streamFile, stream.Partitions)
+	}
+
+	eventFiles, err := fs.Glob(fsys, path.Join(path.Dir(streamFile), "events", "*.yaml"))
+	if err != nil {
+		return Stream{}, err
+	}
+
+	stream.Events = make(map[string]Event, len(eventFiles))
+	for _, eventFile := range eventFiles {
+		var event Event
+		if err := decodeFile(fsys, eventFile, &event); err != nil {
+			return Stream{}, err
+		}
+
+		stream.Events[event.Name] = event
+	}
+
+	return stream, nil
+}
+
+func decodeFile(fsys fs.FS, name string, v any) error {
+	data, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		return err
+	}
+
+	if err := yaml.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("%w: %s: %w", ErrInvalidRegistry, name, err)
+	}
+
+	return nil
+}
+
+// Stream returns the stream declared with the given name (e.g., "events:users").
+func (r *Registry) Stream(name string) (Stream, bool) {
+	stream, ok := r.streams[name]
+
+	return stream, ok
+}
+
+// Event returns the event declared with the given name (e.g., "user.registered"), whatever its stream.
+func (r *Registry) Event(name string) (Event, bool) {
+	for _, stream := range r.streams {
+		if event, ok := stream.Events[name]; ok {
+			return event, true
+		}
+	}
+
+	return Event{}, false
+}
+
+// Streams returns all declared streams sorted by name.
+func (r *Registry) Streams() []Stream {
+	streams := make([]Stream, 0, len(r.streams))
+	for _, stream := range r.streams {
+		streams = append(streams, stream)
+	}
+
+	sort.Slice(streams, func(i, j int) bool { return streams[i].Name < streams[j].Name })
+
+	return streams
+}
@@ -0,0 +1,114 @@
+package registry_test
+
+import (
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tclavelloux/promy-event-bus/registry"
+	"github.com/tclavelloux/promy-event-bus/streams"
+)
+
+func TestDefault_DeclaresAllStreams(t *testing.T) {
+	reg := registry.Default()
+
+	for _, name := range []string{
+		streams.StreamUsers,
+		streams.StreamSubscriptions,
+		streams.StreamPromotions,
+		streams.StreamProducts,
+		streams.StreamIdentifications,
+		streams.StreamDLQ,
+	} {
+		stream, ok := reg.Stream(name)
+		require.True(t, ok, "stream %s should be declared", name)
+		assert.NotEmpty(t, stream.Owner)
+		assert.NoError(t, stream.Retention.Validate())
+		assert.False(t, stream.Retention.IsZero(), "stream %s should declare retention", name)
+	}
+}
+
+func TestDefault_LoadsEvents(t *testing.T) {
+	stream, ok := registry.Default().Stream(streams.StreamUsers)
+	require.True(t, ok)
+
+	event, ok := stream.Events["user.registered"]
+	require.True(t, ok)
+	assert.Equal(t, 1, event.Tier)
+	assert.Equal(t, "1.0", event.Version)
+	assert.True(t, event.Fields["email"].Required)
+	assert.Equal(t, "email", event.Fields["email"].Format)
+}
+
+func TestRegistry_EventPIIFields(t *testing.T) {
+	event, ok := registry.Default().Event("user.registered")
+	require.True(t, ok)
+	assert.Equal(t, []string{"email"}, event.PIIFields())
+
+	_, ok = registry.Default().Event("user.unknown")
+	assert.False(t, ok)
+}
+
+func TestParse_Retention(t *testing.T) {
+	tests := []struct {
+		name      string
+		retention string
+		want      registry.Retention
+		wantErr   bool
+	}{
+		{"max_len", "retention:\n  max_len: 1000\n", registry.Retention{MaxLen: 1000}, false},
+		{"max_age", "retention:\n  max_age: 72h\n", registry.Retention{MaxAge: 72 * time.Hour}, false},
+		{"none", "", registry.Retention{}, false},
+		{"both", "retention:\n  max_len: 1000\n  max_age: 72h\n", registry.Retention{}, true},
+		{"negative max_len", "retention:\n  max_len: -1\n", registry.Retention{}, true},
+		{"unparsable max_age", "retention:\n  max_age: forever\n", registry.Retention{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fsys := fstest.MapFS{
+				"test/stream.yaml": {Data: []byte("stream: events:test\nowner: test\n" + tt.retention)},
+			}
+
+			reg, err := registry.Parse(fsys)
+			if tt.wantErr {
+				assert.ErrorIs(t, err, registry.ErrInvalidRegistry)
+				return
+			}
+			require.NoError(t, err)
+
+			stream, ok := reg.Stream("events:test")
+			require.True(t, ok)
+			assert.Equal(t, tt.want, stream.Retention)
+		})
+	}
+}
+
+func TestParse_Partitions(t *testing.T) {
+	parse := func(partitions string) (*registry.Registry, error) {
+		return registry.Parse(fstest.MapFS{
+			"test/stream.yaml": {Data: []byte("stream: events:test\nowner: test\n" + partitions)},
+		})
+	}
+
+	reg, err := parse("partitions: 8\n")
+	require.NoError(t, err)
+
+	stream, ok := reg.Stream("events:test")
+	require.True(t, ok)
+	assert.Equal(t, 8, stream.Partitions)
+
+	_, err = parse("partitions: -2\n")
+	assert.ErrorIs(t, err, registry.ErrInvalidRegistry)
+}
+
+func TestStreams_Sorted(t *testing.T) {
+	all := registry.Default().Streams()
+
+	require.NotEmpty(t, all)
+	for i := 1; i < len(all); i++ {
+		assert.Less(t, all[i-1].Name, all[i].Name)
+	}
+}
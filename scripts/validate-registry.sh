@@ -10,11 +10,50 @@ error() {
 
 VALID_TYPES="string number boolean object array"
 VALID_FORMATS="uuid email date-time uri"
+VALID_RETENTION_KEYS="max_len max_age"
 
 # Validate stream directories
 for stream_dir in registry/streams/*/; do
-  if [ ! -f "${stream_dir}stream.yaml" ]; then
+  stream_file="${stream_dir}stream.yaml"
+  if [ ! -f "$stream_file" ]; then
     error "$stream_dir -- missing stream.yaml"
+    continue
+  fi
+
+  # Rule: retention (if present) sets exactly one of max_len or max_age
+  retention=$(yq -r '.retention' "$stream_file")
+  if [ -n "$retention" ] && [ "$retention" != "null" ]; then
+    retention_keys=$(yq -r '.retention | keys | .[]' "$stream_file" 2>/dev/null || true)
+    for key in $retention_keys; do
+      if ! echo "$VALID_RETENTION_KEYS" | grep -qw "$key"; then
+        error "$stream_file -- unknown retention key '$key' (allowed: $VALID_RETENTION_KEYS)"
+      fi
+    done
+
+    max_len=$(yq -r '.retention.max_len' "$stream_file")
+    max_age=$(yq -r '.retention.max_age' "$stream_file")
+
+    if [ "$max_len" != "null" ] && [ "$max_age" != "null" ]; then
+      error "$stream_file -- retention must set either 'max_len' or 'max_age', not both"
+    elif [ "$max_len" = "null" ] && [ "$max_age" = "null" ]; then
+      error "$stream_file -- retention must set 'max_len' or 'max_age'"
+    fi
+
+    # Rule: max_len is a positive integer
+    if [ "$max_len" != "null" ] && ! echo "$max_len" | grep -qE '^[1-9][0-9]*$'; then
+      error "$stream_file -- retention 'max_len' must be a positive integer, got '$max_len'"
+    fi
+
+    # Rule: max_age is a positive Go duration (e.g. 720h, 90m)
+    if [ "$max_age" != "null" ] && ! echo "$max_age" | grep -qE '^([0-9]+(\.[0-9]+)?(ns|us|ms|s|m|h))+$'; then
+      error "$stream_file -- retention 'max_age' must be a duration such as '720h', got '$max_age'"
+    fi
+  fi
+
+  # Rule: partitions (if present) is a positive integer
+  partitions=$(yq -r '.partitions' "$stream_file")
+  if [ "$partitions" != "null" ] && ! echo "$partitions" | grep -qE '^[1-9][0-9]*$'; then
+    error "$stream_file -- 'partitions' must be a positive integer, got '$partitions'"
   fi
 done
 
@@ -47,6 +86,12 @@ for event_file in registry/streams/*/events/*.yaml; do
     error "$event_file -- 'tier' must be 1 or 2, got '$tier'"
   fi
 
+  # Rule: version is MAJOR.MINOR (e.g. "1.0", "2.1")
+  version=$(yq -r '.version' "$event_file")
+  if ! echo "$version" | grep -qE '^[0-9]+\.[0-9]+$'; then
+    error "$event_file -- 'version' must be MAJOR.MINOR (e.g. \"1.0\"), got '$version'"
+  fi
+
   # Rule: description present and non-empty
   description=$(yq -r '.description' "$event_file")
   if [ -z "$description" ] || [ "$description" = "null" ]; then
@@ -103,6 +148,12 @@ for event_file in registry/streams/*/events/*.yaml; do
         error "$event_file -- field '$field' has invalid format '$field_format' (allowed: $VALID_FORMATS)"
       fi
     fi
+
+    # Rule: pii (if present) is a boolean
+    field_pii=$(yq -r ".fields.\"$field\".pii" "$event_file")
+    if [ -n "$field_pii" ] && [ "$field_pii" != "null" ] && [ "$field_pii" != "true" ] && [ "$field_pii" != "false" ]; then
+      error "$event_file -- field '$field' has invalid pii '$field_pii' (allowed: true, false)"
+    fi
   done
 done
 
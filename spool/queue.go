@@ -0,0 +1,548 @@
+package spool
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	eventbus "github.com/tclavelloux/promy-event-bus/eventbus"
+)
+
+const (
+	segmentExt   = ".seg"
+	cursorFile   = "cursor"
+	rejectedFile = "rejected.jsonl"
+
+	// frameHeaderSize is the length and CRC-32 prefix of each record.
+	frameHeaderSize = 8
+)
+
+// record is a spooled event and the stream it is published to.
+type record struct {
+	Stream string
+	Event  *eventbus.Envelope
+
+	// end is the position after the record, set by peek.
+	end position
+}
+
+// recordJSON is the on-disk form of a record. Envelope marshals to its payload only,
+// so its fields are spelled out here.
+type recordJSON struct {
+	Stream  string           `json:"stream"`
+	ID      string           `json:"id"`
+	Type    string           `json:"type"`
+	Version string           `json:"version,omitempty"`
+	Time    time.Time        `json:"time"`
+	Key     string           `json:"partition_key,omitempty"`
+	Headers eventbus.Headers `json:"headers,omitempty"`
+	Payload json.RawMessage  `json:"payload"`
+}
+
+// position locates a record: the segment number and byte offset in it.
+type position struct {
+	Segment uint64 `json:"segment"`
+	Offset  int64  `json:"offset"`
+}
+
+// queue is a FIFO of records stored in append-only segment files.
+// Records are framed as a big-endian uint32 length, a CRC-32 (IEEE) of the data, then the JSON data.
+// The read position is persisted in the cursor file; fully read segments are deleted.
+type queue struct {
+	dir         string
+	segmentSize int64
+	maxBytes    int64
+	sync        bool
+
+	mu       sync.Mutex
+	segments []uint64 // in order; the last one is written to
+	writer   *os.File
+	written  int64 // size of the last segment
+	cursor   position
+	count    int   // unread records
+	size     int64 // bytes of all segments on disk
+}
+
+func openQueue(dir string, segmentSize, maxBytes int64, sync bool) (*queue, error) {
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, fmt.Errorf("failed to create spool directory: %w", err)
+	}
+
+	q := &queue{dir: dir, segmentSize: segmentSize, maxBytes: maxBytes, sync: sync}
+
+	if err := q.load(); err != nil {
+		return nil, err
+	}
+
+	return q, nil
+}
+
+// load restores segments and cursor, truncates a torn last record and counts unread records.
+func (q *queue) load() error {
+	entries, err := os.ReadDir(q.dir)
+	if err != nil {
+		return fmt.Errorf("failed to read spool directory: %w", err)
+	}
+
+	for _, entry := range entries {
+		name, ok := strings.CutSuffix(entry.Name(), segmentExt)
+		if !ok {
+			continue
+		}
+
+		n, err := strconv.ParseUint(name, 10, 64)
+		if err != nil {
+			continue
+		}
+
+		q.segments = append(q.segments, n)
+	}
+	sort.Slice(q.segments, func(i, j int) bool { return q.segments[i] < q.segments[j] })
+
+	if err := q.loadCursor(); err != nil {
+		return err
+	}
+
+	for _, n := range q.segments {
+		valid, count, err := q.scan(n)
+		if err != nil {
+			return err
+		}
+
+		if n == q.segments[len(q.segments)-1] {
+			// A crash can leave a partial record at the end of the last segment.
+			if err := os.Truncate(q.segmentPath(n), valid); err != nil {
+				return fmt.Errorf("failed to repair spool segment: %w", err)
+			}
+			q.written = valid
+		}
+
+		q.size += valid
+		q.count += count
+	}
+
+	return nil
+}
+
+func (q *queue) loadCursor() error {
+	data, err := os.ReadFile(filepath.Join(q.dir, cursorFile))
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		if len(q.segments) > 0 {
+			q.cursor = position{Segment: q.segments[0]}
+		}
+
+		return nil
+	case err != nil:
+		return fmt.Errorf("failed to read spool cursor: %w", err)
+	}
+
+	if err := json.Unmarshal(data, &q.cursor); err != nil {
+		return fmt.Errorf("%w: cursor: %w", ErrCorrupt, err)
+	}
+
+	return nil
+}
+
+// scan returns the size of the valid prefix of segment n and the number of unread records in it.
+func (q *queue) scan(n uint64) (int64, int, error) {
+	f, err := os.Open(q.segmentPath(n))
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to open spool segment: %w", err)
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+	var (
+		offset int64
+		count  int
+	)
+	for {
+		size, err := skipFrame(r)
+		if err != nil {
+			// Everything after the first invalid frame is a torn write.
+			return offset, count, nil //nolint:nilerr // a torn tail is not an error
+		}
+
+		if n > q.cursor.Segment || (n == q.cursor.Segment && offset >= q.cursor.Offset) {
+			count++
+		}
+		offset += size
+	}
+}
+
+// push appends records, creating a new segment when the current one is full.
+func (q *queue) push(records []record) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	frames := make([][]byte, len(records))
+	var total int64
+	for i, rec := range records {
+		frame, err := encodeFrame(rec)
+		if err != nil {
+			return err
+		}
+
+		frames[i] = frame
+		total += int64(len(frame))
+	}
+
+	if q.maxBytes > 0 && q.size+total > q.maxBytes {
+		return ErrFull
+	}
+
+	if q.writer == nil || q.written >= q.segmentSize {
+		if err := q.rotate(); err != nil {
+			return err
+		}
+	}
+
+	for _, frame := range frames {
+		if _, err := q.writer.Write(frame); err != nil {
+			return fmt.Errorf("failed to write spool segment: %w", err)
+		}
+	}
+
+	if q.sync {
+		if err := q.writer.Sync(); err != nil {
+			return fmt.Errorf("failed to sync spool segment: %w", err)
+		}
+	}
+
+	q.written += total
+	q.size += total
+	q.count += len(records)
+
+	return nil
+}
+
+// rotate makes the last segment the write target, or starts a new one once it is full.
+func (q *queue) rotate() error {
+	if q.writer != nil {
+		if err := q.writer.Close(); err != nil {
+			return fmt.Errorf("failed to close spool segment: %w", err)
+		}
+		q.writer = nil
+	}
+
+	if len(q.segments) == 0 || q.written >= q.segmentSize {
+		next := max(q.cursor.Segment, 1)
+		if len(q.segments) > 0 {
+			next = q.segments[len(q.segments)-1] + 1
+		} else {
+			q.cursor = position{Segment: next}
+		}
+
+		q.segments = append(q.segments, next)
+		q.written = 0
+	}
+
+	f, err := os.OpenFile(q.segmentPath(q.segments[len(q.segments)-1]), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o640)
+	if err != nil {
+		return fmt.Errorf("failed to open spool segment: %w", err)
+	}
+	q.writer = f
+
+	return nil
+}
+
+// peek returns up to limit unread records in order.
+func (q *queue) peek(limit int) ([]record, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	var records []record
+	pos := q.cursor
+
+	for _, n := range q.segments {
+		if n < pos.Segment || len(records) >= limit {
+			continue
+		}
+		if n > pos.Segment {
+			pos = position{Segment: n}
+		}
+
+		end := q.segmentEnd(n)
+		f, err := os.Open(q.segmentPath(n))
+		if err != nil {
+			return nil, fmt.Errorf("failed to open spool segment: %w", err)
+		}
+
+		if _, err := f.Seek(pos.Offset, io.SeekStart); err != nil {
+			f.Close()
+
+			return nil, fmt.Errorf("failed to read spool segment: %w", err)
+		}
+
+		r := bufio.NewReader(io.LimitReader(f, end-pos.Offset))
+		for len(records) < limit && pos.Offset < end {
+			rec, size, err := decodeFrame(r)
+			if err != nil {
+				f.Close()
+
+				return nil, err
+			}
+
+			pos.Offset += size
+			rec.end = pos
+			records = append(records, rec)
+		}
+
+		f.Close()
+	}
+
+	return records, nil
+}
+
+// commit marks the first n unread records as read; last is the n-th record returned by peek.
+func (q *queue) commit(last record, n int) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	pos := last.end
+	q.cursor = pos
+	q.count -= n
+
+	// Delete segments read to the end, except the one being written to.
+	for len(q.segments) > 1 && (q.segments[0] < pos.Segment ||
+		(q.segments[0] == pos.Segment && pos.Offset >= q.segmentEnd(pos.Segment))) {
+		if err := q.remove(q.segments[0]); err != nil {
+			return err
+		}
+		if q.cursor.Segment < q.segments[0] {
+			q.cursor = position{Segment: q.segments[0]}
+		}
+	}
+
+	// Once everything is read, start over with an empty segment.
+	if q.count == 0 && len(q.segments) == 1 && q.written > 0 {
+		if q.writer != nil {
+			if err := q.writer.Close(); err != nil {
+				return fmt.Errorf("failed to close spool segment: %w", err)
+			}
+			q.writer = nil
+		}
+
+		segment := q.segments[0]
+		if err := q.remove(segment); err != nil {
+			return err
+		}
+
+		q.written = 0
+		q.cursor = position{Segment: segment + 1}
+	}
+
+	return q.saveCursor()
+}
+
+// segmentEnd returns the number of bytes written to segment n.
+func (q *queue) segmentEnd(n uint64) int64 {
+	if n == q.segments[len(q.segments)-1] {
+		return q.written
+	}
+
+	info, err := os.Stat(q.segmentPath(n))
+	if err != nil {
+		return 0
+	}
+
+	return info.Size()
+}
+
+func (q *queue) remove(n uint64) error {
+	size := q.segmentEnd(n)
+	if err := os.Remove(q.segmentPath(n)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to delete spool segment: %w", err)
+	}
+
+	q.size -= size
+	q.segments = q.segments[1:]
+
+	return nil
+}
+
+// saveCursor atomically replaces the cursor file.
+func (q *queue) saveCursor() error {
+	data, err := json.Marshal(q.cursor)
+	if err != nil {
+		return fmt.Errorf("failed to marshal spool cursor: %w", err)
+	}
+
+	tmp := filepath.Join(q.dir, cursorFile+".tmp")
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o640)
+	if err != nil {
+		return fmt.Errorf("failed to write spool cursor: %w", err)
+	}
+
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+
+		return fmt.Errorf("failed to write spool cursor: %w", err)
+	}
+	if q.sync {
+		if err := f.Sync(); err != nil {
+			f.Close()
+
+			return fmt.Errorf("failed to sync spool cursor: %w", err)
+		}
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to write spool cursor: %w", err)
+	}
+
+	if err := os.Rename(tmp, filepath.Join(q.dir, cursorFile)); err != nil {
+		return fmt.Errorf("failed to write spool cursor: %w", err)
+	}
+
+	return nil
+}
+
+// reject appends rec and the reason it cannot be published to the rejected file, one JSON
+// object per line. It does not commit rec.
+func (q *queue) reject(rec record, reason error) error {
+	data, err := json.Marshal(struct {
+		recordJSON
+		Error string `json:"error"`
+	}{recordJSON: toRecordJSON(rec), Error: reason.Error()})
+	if err != nil {
+		return fmt.Errorf("failed to marshal rejected spool record: %w", err)
+	}
+
+	f, err := os.OpenFile(filepath.Join(q.dir, rejectedFile), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o640)
+	if err != nil {
+		return fmt.Errorf("failed to open rejected spool records: %w", err)
+	}
+
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		f.Close()
+
+		return fmt.Errorf("failed to write rejected spool record: %w", err)
+	}
+	if q.sync {
+		if err := f.Sync(); err != nil {
+			f.Close()
+
+			return fmt.Errorf("failed to sync rejected spool records: %w", err)
+		}
+	}
+
+	return f.Close()
+}
+
+// depth returns the number of unread records and the bytes used on disk.
+func (q *queue) depth() (int, int64) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	return q.count, q.size
+}
+
+func (q *queue) close() error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.writer == nil {
+		return nil
+	}
+
+	err := q.writer.Close()
+	q.writer = nil
+
+	return err
+}
+
+func (q *queue) segmentPath(n uint64) string {
+	return filepath.Join(q.dir, fmt.Sprintf("%020d%s", n, segmentExt))
+}
+
+func toRecordJSON(rec record) recordJSON {
+	return recordJSON{
+		Stream:  rec.Stream,
+		ID:      rec.Event.ID,
+		Type:    rec.Event.Type,
+		Version: rec.Event.Version,
+		Time:    rec.Event.Time,
+		Key:     rec.Event.Key,
+		Headers: rec.Event.Header,
+		Payload: rec.Event.Payload,
+	}
+}
+
+func encodeFrame(rec record) ([]byte, error) {
+	data, err := json.Marshal(toRecordJSON(rec))
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal spool record: %w", err)
+	}
+
+	frame := make([]byte, frameHeaderSize+len(data))
+	binary.BigEndian.PutUint32(frame[0:4], uint32(len(data))) //nolint:gosec // records are far below 4 GiB
+	binary.BigEndian.PutUint32(frame[4:8], crc32.ChecksumIEEE(data))
+	copy(frame[frameHeaderSize:], data)
+
+	return frame, nil
+}
+
+// readFrame reads one frame and returns its checked data.
+func readFrame(r io.Reader) ([]byte, error) {
+	var header [frameHeaderSize]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return nil, err
+	}
+
+	data := make([]byte, binary.BigEndian.Uint32(header[0:4]))
+	if _, err := io.ReadFull(r, data); err != nil {
+		return nil, err
+	}
+
+	if crc32.ChecksumIEEE(data) != binary.BigEndian.Uint32(header[4:8]) {
+		return nil, fmt.Errorf("%w: checksum mismatch", ErrCorrupt)
+	}
+
+	return data, nil
+}
+
+func skipFrame(r io.Reader) (int64, error) {
+	data, err := readFrame(r)
+	if err != nil {
+		return 0, err
+	}
+
+	return int64(frameHeaderSize + len(data)), nil
+}
+
+func decodeFrame(r io.Reader) (record, int64, error) {
+	data, err := readFrame(r)
+	if err != nil {
+		return record{}, 0, fmt.Errorf("%w: %w", ErrCorrupt, err)
+	}
+
+	var stored recordJSON
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return record{}, 0, fmt.Errorf("%w: %w", ErrCorrupt, err)
+	}
+
+	return record{
+		Stream: stored.Stream,
+		Event: &eventbus.Envelope{
+			ID:      stored.ID,
+			Type:    stored.Type,
+			Version: stored.Version,
+			Time:    stored.Time,
+			Key:     stored.Key,
+			Header:  stored.Headers,
+			Payload: stored.Payload,
+		},
+	}, int64(frameHeaderSize + len(data)), nil
+}
@@ -0,0 +1,152 @@
+package spool
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tclavelloux/promy-event-bus/eventbus"
+	"github.com/tclavelloux/promy-event-bus/testutil"
+)
+
+func newRecord(t *testing.T, stream string) record {
+	t.Helper()
+
+	envelope, err := eventbus.NewEnvelope(context.Background(), testutil.NewTestEvent("promotion.viewed", map[string]any{"promotion_id": "promo-1"}))
+	require.NoError(t, err)
+
+	return record{Stream: stream, Event: envelope}
+}
+
+func segmentFiles(t *testing.T, dir string) []string {
+	t.Helper()
+
+	files, err := filepath.Glob(filepath.Join(dir, "*"+segmentExt))
+	require.NoError(t, err)
+
+	return files
+}
+
+func TestQueue_PushPeekCommit(t *testing.T) {
+	dir := t.TempDir()
+	q, err := openQueue(dir, 1024, 0, true)
+	require.NoError(t, err)
+	defer q.close()
+
+	var pushed []record
+	for i := 0; i < 20; i++ {
+		rec := newRecord(t, "events:promotions")
+		pushed = append(pushed, rec)
+		require.NoError(t, q.push([]record{rec}))
+	}
+
+	count, _ := q.depth()
+	assert.Equal(t, 20, count)
+	assert.Greater(t, len(segmentFiles(t, dir)), 1, "small segments rotate")
+
+	records, err := q.peek(5)
+	require.NoError(t, err)
+	require.Len(t, records, 5)
+	for i, rec := range records {
+		assert.Equal(t, pushed[i].Event.ID, rec.Event.ID)
+		assert.Equal(t, pushed[i].Event.Header, rec.Event.Header)
+		assert.JSONEq(t, string(pushed[i].Event.Payload), string(rec.Event.Payload))
+	}
+
+	require.NoError(t, q.commit(records[4], 5))
+	count, _ = q.depth()
+	assert.Equal(t, 15, count)
+
+	records, err = q.peek(100)
+	require.NoError(t, err)
+	require.Len(t, records, 15)
+	assert.Equal(t, pushed[5].Event.ID, records[0].Event.ID)
+
+	require.NoError(t, q.commit(records[14], 15))
+	count, size := q.depth()
+	assert.Zero(t, count)
+	assert.Zero(t, size)
+	assert.Empty(t, segmentFiles(t, dir), "drained segments are deleted")
+
+	// The queue keeps working after being drained.
+	require.NoError(t, q.push([]record{newRecord(t, "events:promotions")}))
+	count, _ = q.depth()
+	assert.Equal(t, 1, count)
+}
+
+func TestQueue_Reopen(t *testing.T) {
+	dir := t.TempDir()
+	q, err := openQueue(dir, 1024, 0, false)
+	require.NoError(t, err)
+
+	var pushed []record
+	for i := 0; i < 10; i++ {
+		rec := newRecord(t, "events:promotions")
+		pushed = append(pushed, rec)
+		require.NoError(t, q.push([]record{rec}))
+	}
+
+	records, err := q.peek(3)
+	require.NoError(t, err)
+	require.NoError(t, q.commit(records[2], 3))
+	require.NoError(t, q.close())
+
+	q, err = openQueue(dir, 1024, 0, false)
+	require.NoError(t, err)
+	defer q.close()
+
+	count, _ := q.depth()
+	assert.Equal(t, 7, count)
+
+	records, err = q.peek(100)
+	require.NoError(t, err)
+	require.Len(t, records, 7)
+	assert.Equal(t, pushed[3].Event.ID, records[0].Event.ID)
+}
+
+func TestQueue_RepairsTornWrite(t *testing.T) {
+	dir := t.TempDir()
+	q, err := openQueue(dir, 1<<20, 0, false)
+	require.NoError(t, err)
+	require.NoError(t, q.push([]record{newRecord(t, "events:promotions"), newRecord(t, "events:promotions")}))
+	require.NoError(t, q.close())
+
+	// Simulate a crash in the middle of writing a third record.
+	files := segmentFiles(t, dir)
+	require.Len(t, files, 1)
+	f, err := os.OpenFile(files[0], os.O_WRONLY|os.O_APPEND, 0o640)
+	require.NoError(t, err)
+	_, err = f.Write([]byte{0, 0, 1, 0, 1, 2})
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	q, err = openQueue(dir, 1<<20, 0, false)
+	require.NoError(t, err)
+	defer q.close()
+
+	count, _ := q.depth()
+	assert.Equal(t, 2, count)
+
+	require.NoError(t, q.push([]record{newRecord(t, "events:promotions")}))
+	records, err := q.peek(100)
+	require.NoError(t, err)
+	assert.Len(t, records, 3)
+}
+
+func TestQueue_MaxBytes(t *testing.T) {
+	q, err := openQueue(t.TempDir(), 1<<20, 600, false)
+	require.NoError(t, err)
+	defer q.close()
+
+	var pushErr error
+	for i := 0; i < 10 && pushErr == nil; i++ {
+		pushErr = q.push([]record{newRecord(t, "events:promotions")})
+	}
+
+	assert.ErrorIs(t, pushErr, ErrFull)
+	_, size := q.depth()
+	assert.LessOrEqual(t, size, int64(600))
+}
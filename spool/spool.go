@@ -0,0 +1,343 @@
+// Package spool keeps events on local disk while the event bus is unavailable.
+//
+// A Publisher wraps an eventbus.EventPublisher. When publishing fails because the bus is
+// unavailable, the events are appended to a spool directory instead of being lost, and a
+// background worker publishes them again, in order, once the wrapped publisher's Health
+// check succeeds. While events are spooled, new events are spooled behind them so the
+// order is kept. Spooled events that fail for another reason are moved to the
+// rejected.jsonl file of the spool directory, so that they do not block the others.
+//
+// The spool is a set of append-only segment files; fully published segments are deleted.
+// Delivery is at-least-once: a crash between publishing and recording progress publishes
+// some events again.
+package spool
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"sync"
+	"time"
+
+	eventbus "github.com/tclavelloux/promy-event-bus/eventbus"
+)
+
+var (
+	// ErrFull is returned when appending would make the spool exceed Config.MaxBytes.
+	ErrFull = errors.New("spool full")
+
+	// ErrCorrupt is returned when a spool file cannot be decoded.
+	ErrCorrupt = errors.New("corrupt spool")
+)
+
+// Default Publisher settings.
+const (
+	defaultSegmentSize   = 16 << 20
+	defaultMaxBytes      = 1 << 30
+	defaultRetryInterval = 5 * time.Second
+	defaultBatchSize     = 100
+	defaultTimeout       = 5 * time.Second
+)
+
+// Metrics receives the spool depth after every change.
+// prometheus.Metrics implements it.
+type Metrics interface {
+	SpoolDepth(events int, bytes int64)
+}
+
+// Config configures a Publisher.
+type Config struct {
+	// Dir is the spool directory. It is created if missing and must not be shared
+	// between processes. Required.
+	Dir string
+
+	// SegmentSize is the size above which a new segment file is started.
+	// Default: 16 MiB
+	SegmentSize int64
+
+	// MaxBytes caps the disk usage of the spool. Events that do not fit are rejected
+	// with ErrFull. Default: 1 GiB
+	MaxBytes int64
+
+	// Sync fsyncs every append and progress update. Without it, a machine crash can
+	// lose recently spooled events; a process crash cannot.
+	Sync bool
+
+	// RetryInterval is how often the worker checks Health while events are spooled.
+	// Default: 5s
+	RetryInterval time.Duration
+
+	// BatchSize is the maximum number of spooled events published per PublishBatch call.
+	// Default: 100
+	BatchSize int
+
+	// PublishTimeout bounds each Health and PublishBatch call of the worker.
+	// Default: 5s
+	PublishTimeout time.Duration
+
+	// Unavailable reports whether a publish error means the bus is unavailable, so that
+	// the events are spooled. Other errors are returned to the caller, and spooled events
+	// failing with them are rejected.
+	// Default: network errors, timeouts and eventbus.ErrConnectionClosed
+	Unavailable func(err error) bool
+
+	// Metrics receives the spool depth. Optional.
+	Metrics Metrics
+
+	// Logger receives worker failures. Default: discard.
+	Logger *slog.Logger
+}
+
+// Publisher is an eventbus.EventPublisher that spools events it cannot publish.
+type Publisher struct {
+	publisher eventbus.EventPublisher
+	config    Config
+	queue     *queue
+	logger    *slog.Logger
+
+	// mu orders publishing against spooling, so new events never overtake spooled ones.
+	mu sync.Mutex
+
+	closing chan struct{}
+	done    chan struct{}
+	once    sync.Once
+}
+
+// NewPublisher opens the spool in config.Dir and starts publishing its events through publisher.
+func NewPublisher(publisher eventbus.EventPublisher, config Config) (*Publisher, error) {
+	if config.Dir == "" {
+		return nil, errors.New("spool directory is required")
+	}
+	if config.SegmentSize <= 0 {
+		config.SegmentSize = defaultSegmentSize
+	}
+	if config.MaxBytes <= 0 {
+		config.MaxBytes = defaultMaxBytes
+	}
+	if config.RetryInterval <= 0 {
+		config.RetryInterval = defaultRetryInterval
+	}
+	if config.BatchSize <= 0 {
+		config.BatchSize = defaultBatchSize
+	}
+	if config.PublishTimeout <= 0 {
+		config.PublishTimeout = defaultTimeout
+	}
+	if config.Unavailable == nil {
+		config.Unavailable = unavailable
+	}
+
+	logger := config.Logger
+	if logger == nil {
+		logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+	}
+
+	q, err := openQueue(config.Dir, config.SegmentSize, config.MaxBytes, config.Sync)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &Publisher{
+		publisher: publisher,
+		config:    config,
+		queue:     q,
+		logger:    logger.With(slog.String("spool", config.Dir)),
+		closing:   make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+	p.reportDepth()
+
+	go p.run()
+
+	return p, nil
+}
+
+// Publish publishes event, or spools it if the bus is unavailable.
+// A nil error means the event was published or spooled.
+func (p *Publisher) Publish(ctx context.Context, stream string, event eventbus.Event) error {
+	return p.PublishBatch(ctx, stream, []eventbus.Event{event})
+}
+
+// PublishBatch publishes events, or spools all of them if the bus is unavailable.
+// Other errors, such as invalid events, are returned and the events are not spooled.
+func (p *Publisher) PublishBatch(ctx context.Context, stream string, events []eventbus.Event) error {
+	if len(events) == 0 {
+		return nil
+	}
+
+	records := make([]record, len(events))
+	for i, event := range events {
+		envelope, err := eventbus.NewEnvelope(ctx, event)
+		if err != nil {
+			return err
+		}
+
+		records[i] = record{Stream: stream, Event: envelope}
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if pending, _ := p.queue.depth(); pending == 0 {
+		err := p.publisher.PublishBatch(ctx, stream, events)
+		if err == nil || !p.config.Unavailable(err) {
+			return err
+		}
+
+		p.logger.WarnContext(ctx, "publish failed, spooling events",
+			slog.String("stream", stream), slog.Int("events", len(events)), slog.Any("error", err))
+	}
+
+	if err := p.queue.push(records); err != nil {
+		return fmt.Errorf("%w: failed to spool events: %w", eventbus.ErrPublishFailed, err)
+	}
+	p.reportDepth()
+
+	return nil
+}
+
+// Close stops the background worker and closes the spool.
+// Spooled events stay on disk and are published by the next Publisher opened on the directory.
+// It does not close the wrapped publisher.
+func (p *Publisher) Close() error {
+	p.once.Do(func() { close(p.closing) })
+	<-p.done
+
+	return p.queue.close()
+}
+
+// Health checks the health of the wrapped publisher.
+func (p *Publisher) Health(ctx context.Context) error {
+	return p.publisher.Health(ctx)
+}
+
+// Depth returns the number of spooled events and the bytes the spool uses on disk.
+func (p *Publisher) Depth() (events int, bytes int64) {
+	return p.queue.depth()
+}
+
+// run republishes spooled events while the wrapped publisher is healthy.
+func (p *Publisher) run() {
+	defer close(p.done)
+
+	ticker := time.NewTicker(p.config.RetryInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-p.closing:
+			return
+		case <-ticker.C:
+		}
+
+		if pending, _ := p.queue.depth(); pending > 0 && p.healthy() {
+			p.drain()
+		}
+	}
+}
+
+func (p *Publisher) healthy() bool {
+	ctx, cancel := context.WithTimeout(context.Background(), p.config.PublishTimeout)
+	defer cancel()
+
+	return p.publisher.Health(ctx) == nil
+}
+
+// drain publishes spooled events in order until the spool is empty or publishing fails.
+func (p *Publisher) drain() {
+	for {
+		select {
+		case <-p.closing:
+			return
+		default:
+		}
+
+		published, err := p.drainBatch()
+		if err != nil {
+			p.logger.Error("failed to publish spooled events", slog.Any("error", err))
+
+			return
+		}
+		if !published {
+			return
+		}
+	}
+}
+
+// drainBatch publishes the next spooled events of one stream, or rejects the first one if
+// it cannot be published. It reports whether any were published or rejected.
+func (p *Publisher) drainBatch() (bool, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	records, err := p.queue.peek(p.config.BatchSize)
+	if err != nil || len(records) == 0 {
+		return false, err
+	}
+
+	// Publish the leading records of the same stream together.
+	n := 1
+	for n < len(records) && records[n].Stream == records[0].Stream {
+		n++
+	}
+
+	events := make([]eventbus.Event, n)
+	for i, rec := range records[:n] {
+		events[i] = rec.Event
+	}
+
+	err = p.publishSpooled(records[0].Stream, events)
+	if err != nil && n > 1 && !p.config.Unavailable(err) {
+		// Publish the head alone, so that only the failing event is rejected.
+		n, events = 1, events[:1]
+		err = p.publishSpooled(records[0].Stream, events)
+	}
+	if err != nil {
+		if p.config.Unavailable(err) {
+			return false, err
+		}
+
+		if err := p.queue.reject(records[0], err); err != nil {
+			return false, err
+		}
+		p.logger.Error("rejected spooled event",
+			slog.String("stream", records[0].Stream), slog.String("event_id", records[0].Event.ID), slog.Any("error", err))
+	}
+
+	if err := p.queue.commit(records[n-1], n); err != nil {
+		return false, err
+	}
+	p.reportDepth()
+
+	return true, nil
+}
+
+func (p *Publisher) publishSpooled(stream string, events []eventbus.Event) error {
+	ctx, cancel := context.WithTimeout(context.Background(), p.config.PublishTimeout)
+	defer cancel()
+
+	return p.publisher.PublishBatch(ctx, stream, events)
+}
+
+// unavailable reports whether err is a network error, a timeout or eventbus.ErrConnectionClosed.
+func unavailable(err error) bool {
+	var netErr net.Error
+
+	return errors.As(err, &netErr) ||
+		errors.Is(err, eventbus.ErrConnectionClosed) ||
+		errors.Is(err, context.DeadlineExceeded) ||
+		errors.Is(err, io.EOF) ||
+		errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+func (p *Publisher) reportDepth() {
+	if p.config.Metrics == nil {
+		return
+	}
+
+	events, bytes := p.queue.depth()
+	p.config.Metrics.SpoolDepth(events, bytes)
+}
@@ -0,0 +1,233 @@
+package spool_test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tclavelloux/promy-event-bus/eventbus"
+	"github.com/tclavelloux/promy-event-bus/spool"
+	"github.com/tclavelloux/promy-event-bus/testutil"
+)
+
+// flakyPublisher is an EventPublisher that fails while down is set, and always fails
+// batches holding the event with ID broken.
+type flakyPublisher struct {
+	down   atomic.Bool
+	broken string
+
+	mu       sync.Mutex
+	ids      []string
+	versions []string
+	keys     []string
+}
+
+func (p *flakyPublisher) Publish(ctx context.Context, stream string, event eventbus.Event) error {
+	return p.PublishBatch(ctx, stream, []eventbus.Event{event})
+}
+
+func (p *flakyPublisher) PublishBatch(_ context.Context, _ string, events []eventbus.Event) error {
+	if p.down.Load() {
+		return fmt.Errorf("%w: %w", eventbus.ErrPublishFailed, eventbus.ErrConnectionClosed)
+	}
+	for _, event := range events {
+		if event.EventID() == p.broken {
+			return fmt.Errorf("%w: WRONGTYPE", eventbus.ErrPublishFailed)
+		}
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, event := range events {
+		p.ids = append(p.ids, event.EventID())
+		p.versions = append(p.versions, eventbus.EventVersion(event))
+		p.keys = append(p.keys, eventbus.PartitionKey(event))
+	}
+
+	return nil
+}
+
+func (p *flakyPublisher) Close() error { return nil }
+
+func (p *flakyPublisher) Health(context.Context) error {
+	if p.down.Load() {
+		return eventbus.ErrConnectionClosed
+	}
+
+	return nil
+}
+
+func (p *flakyPublisher) published() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return append([]string(nil), p.ids...)
+}
+
+// depthRecorder is a spool.Metrics keeping the last reported depth.
+type depthRecorder struct {
+	events atomic.Int64
+}
+
+func (r *depthRecorder) SpoolDepth(events int, _ int64) {
+	r.events.Store(int64(events))
+}
+
+func TestPublisher_SpoolsWhileDown(t *testing.T) {
+	inner := &flakyPublisher{}
+	metrics := &depthRecorder{}
+	publisher, err := spool.NewPublisher(inner, spool.Config{
+		Dir:           t.TempDir(),
+		RetryInterval: 10 * time.Millisecond,
+		Metrics:       metrics,
+	})
+	require.NoError(t, err)
+	defer publisher.Close()
+
+	ctx := context.Background()
+	inner.down.Store(true)
+
+	var ids []string
+	for i := 0; i < 5; i++ {
+		event := testutil.NewTestEvent("promotion.viewed", map[string]any{"promotion_id": "promo-1"})
+		ids = append(ids, event.EventID())
+		require.NoError(t, publisher.Publish(ctx, "events:promotions", event))
+	}
+
+	events, _ := publisher.Depth()
+	assert.Equal(t, 5, events)
+	assert.Equal(t, int64(5), metrics.events.Load())
+	assert.Empty(t, inner.published())
+
+	inner.down.Store(false)
+	require.Eventually(t, func() bool {
+		events, _ := publisher.Depth()
+
+		return events == 0
+	}, time.Second, 5*time.Millisecond)
+
+	assert.Equal(t, ids, inner.published())
+	assert.Zero(t, metrics.events.Load())
+}
+
+func TestPublisher_KeepsOrderBehindSpooledEvents(t *testing.T) {
+	inner := &flakyPublisher{}
+	publisher, err := spool.NewPublisher(inner, spool.Config{
+		Dir:           t.TempDir(),
+		RetryInterval: time.Hour,
+	})
+	require.NoError(t, err)
+	defer publisher.Close()
+
+	ctx := context.Background()
+	first := testutil.NewTestEvent("promotion.viewed", nil)
+	second := testutil.NewTestEvent("promotion.viewed", nil)
+
+	inner.down.Store(true)
+	require.NoError(t, publisher.Publish(ctx, "events:promotions", first))
+
+	// Redis is back, but first is still spooled: second must queue behind it.
+	inner.down.Store(false)
+	require.NoError(t, publisher.Publish(ctx, "events:promotions", second))
+
+	events, _ := publisher.Depth()
+	assert.Equal(t, 2, events)
+	assert.Empty(t, inner.published())
+}
+
+func TestPublisher_ResumesAfterRestart(t *testing.T) {
+	dir := t.TempDir()
+	inner := &flakyPublisher{}
+	inner.down.Store(true)
+
+	publisher, err := spool.NewPublisher(inner, spool.Config{Dir: dir, RetryInterval: time.Hour, Sync: true})
+	require.NoError(t, err)
+
+	event := testutil.NewTestEvent("promotion.viewed", nil)
+	event.Version = "2.0"
+	event.Key = "promo-42"
+	require.NoError(t, publisher.Publish(context.Background(), "events:promotions", event))
+	require.NoError(t, publisher.Close())
+
+	inner.down.Store(false)
+	publisher, err = spool.NewPublisher(inner, spool.Config{Dir: dir, RetryInterval: 10 * time.Millisecond})
+	require.NoError(t, err)
+	defer publisher.Close()
+
+	require.Eventually(t, func() bool { return len(inner.published()) == 1 }, time.Second, 5*time.Millisecond)
+	assert.Equal(t, []string{event.EventID()}, inner.published())
+
+	inner.mu.Lock()
+	defer inner.mu.Unlock()
+	assert.Equal(t, []string{"2.0"}, inner.versions, "the schema version is spooled")
+	assert.Equal(t, []string{"promo-42"}, inner.keys, "the partition key is spooled")
+}
+
+func TestPublisher_RejectsInvalidEvent(t *testing.T) {
+	publisher, err := spool.NewPublisher(&flakyPublisher{}, spool.Config{Dir: t.TempDir()})
+	require.NoError(t, err)
+	defer publisher.Close()
+
+	err = publisher.Publish(context.Background(), "events:dlq", &eventbus.DLQEntry{})
+	assert.ErrorIs(t, err, eventbus.ErrInvalidEvent)
+
+	events, _ := publisher.Depth()
+	assert.Zero(t, events)
+}
+
+func TestPublisher_ReturnsPermanentErrors(t *testing.T) {
+	event := testutil.NewTestEvent("promotion.viewed", nil)
+	publisher, err := spool.NewPublisher(&flakyPublisher{broken: event.EventID()}, spool.Config{Dir: t.TempDir()})
+	require.NoError(t, err)
+	defer publisher.Close()
+
+	err = publisher.Publish(context.Background(), "events:promotions", event)
+	assert.ErrorIs(t, err, eventbus.ErrPublishFailed)
+
+	events, _ := publisher.Depth()
+	assert.Zero(t, events)
+}
+
+func TestPublisher_RejectsFailingSpooledEvent(t *testing.T) {
+	dir := t.TempDir()
+	broken := testutil.NewTestEvent("promotion.viewed", nil)
+	inner := &flakyPublisher{broken: broken.EventID()}
+	publisher, err := spool.NewPublisher(inner, spool.Config{Dir: dir, RetryInterval: 10 * time.Millisecond})
+	require.NoError(t, err)
+	defer publisher.Close()
+
+	ctx := context.Background()
+	inner.down.Store(true)
+
+	var ids []string
+	for i := 0; i < 4; i++ {
+		event := testutil.NewTestEvent("promotion.viewed", nil)
+		if i == 1 {
+			event = broken
+		} else {
+			ids = append(ids, event.EventID())
+		}
+		require.NoError(t, publisher.Publish(ctx, "events:promotions", event))
+	}
+
+	// The broken event fails for good once Redis is back; it must not block the others.
+	inner.down.Store(false)
+	require.Eventually(t, func() bool {
+		events, _ := publisher.Depth()
+
+		return events == 0
+	}, time.Second, 5*time.Millisecond)
+	assert.Equal(t, ids, inner.published())
+
+	rejected, err := os.ReadFile(filepath.Join(dir, "rejected.jsonl"))
+	require.NoError(t, err)
+	assert.Contains(t, string(rejected), broken.EventID())
+	assert.Contains(t, string(rejected), "WRONGTYPE")
+}
@@ -2,6 +2,7 @@ package testutil
 
 import (
 	"context"
+	"time"
 
 	eventbus "github.com/tclavelloux/promy-event-bus/eventbus"
 
@@ -27,6 +28,34 @@ func (m *MockPublisher) PublishBatch(ctx context.Context, stream string, events
 	return args.Error(0)
 }
 
+// PublishMulti mocks the PublishMulti method.
+func (m *MockPublisher) PublishMulti(ctx context.Context, events []eventbus.StreamEvent) error {
+	args := m.Called(ctx, events)
+
+	return args.Error(0)
+}
+
+// PublishAt mocks the PublishAt method.
+func (m *MockPublisher) PublishAt(ctx context.Context, stream string, event eventbus.Event, at time.Time) error {
+	args := m.Called(ctx, stream, event, at)
+
+	return args.Error(0)
+}
+
+// PublishAfter mocks the PublishAfter method.
+func (m *MockPublisher) PublishAfter(ctx context.Context, stream string, event eventbus.Event, delay time.Duration) error {
+	args := m.Called(ctx, stream, event, delay)
+
+	return args.Error(0)
+}
+
+// CancelScheduled mocks the CancelScheduled method.
+func (m *MockPublisher) CancelScheduled(ctx context.Context, eventID string) (bool, error) {
+	args := m.Called(ctx, eventID)
+
+	return args.Bool(0), args.Error(1)
+}
+
 // Close mocks the Close method.
 func (m *MockPublisher) Close() error {
 	args := m.Called()
@@ -21,6 +21,9 @@ type TestEvent struct {
 	Version   string         `json:"version"`
 	Source    string         `json:"source"`
 	Payload   map[string]any `json:"-"`
+
+	// Key is the partition key of the event; the ID is used when empty.
+	Key string `json:"-"`
 }
 
 // NewTestEvent creates a test event with the given type and payload fields.
@@ -38,7 +41,10 @@ func NewTestEvent(eventType string, payload map[string]any) *TestEvent {
 func (e *TestEvent) EventType() string    { return e.Type }
 func (e *TestEvent) EventID() string      { return e.ID }
 func (e *TestEvent) EventTime() time.Time { return e.CreatedAt }
+func (e *TestEvent) EventVersion() string { return e.Version }
+func (e *TestEvent) EventSource() string  { return e.Source }
 func (e *TestEvent) Validate() error      { return nil }
+func (e *TestEvent) PartitionKey() string { return e.Key }
 
 func (e *TestEvent) Data() string {
 	b, _ := json.Marshal(e.Payload) //nolint:errchkjson // test helper